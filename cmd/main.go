@@ -1,37 +1,91 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"github.com/Fyefhqdishka/deadlock_v.2/internal/app"
 	"github.com/Fyefhqdishka/deadlock_v.2/internal/config"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 )
 
+const usage = `Usage:
+  app [serve] [flags]               run the server
+  app migrate [flags] up [N]        apply all pending migrations, or the next N
+  app migrate [flags] down N        revert the last N migrations
+  app migrate [flags] goto V        migrate up or down to version V
+  app migrate [flags] status        show the schema version and the migrations
+  app migrate [flags] force V       set version V after fixing a failed migration
+  app migrate create [-dir D] name  add the up and down files of a new migration
+
+Run app serve -h or app migrate -h for the flags.
+`
+
 func main() {
-	cfg, err := config.LoadFromEnv()
+	if err := run(os.Args[1:]); err != nil {
+		log.Print(err)
+		os.Exit(1)
+	}
+}
+
+// run dispatches to the command named by the first argument, serve when
+// there is none.
+func run(args []string) error {
+	cmd := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	switch cmd {
+	case "serve":
+		return serve(args)
+	case "migrate":
+		return migrate(args)
+	case "help":
+		fmt.Print(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", cmd, usage)
+	}
+}
+
+// loadConfig registers the config flags on fs, parses args and loads the
+// config with load. It also returns the loader.
+func loadConfig(fs *flag.FlagSet, args []string, load func(*config.Loader) (*config.Config, error)) (*config.Config, *config.Loader, error) {
+	loader := config.NewLoader(fs)
+	fs.Parse(args)
+
+	cfg, err := load(loader)
 	if err != nil {
-		log.Fatalf("can't load config, err: %v", err)
+		return nil, nil, fmt.Errorf("can't load config:\n%w", err)
 	}
+	return cfg, loader, nil
+}
 
-	app, err := app.New(cfg)
+// serve serves until SIGINT or SIGTERM and reports whether the app failed to
+// start, failed while running or failed to shut down cleanly.
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	printConfig := fs.Bool("print-config", false, "print the effective config without secrets and exit")
+	cfg, loader, err := loadConfig(fs, args, (*config.Loader).Load)
 	if err != nil {
-		log.Fatalf("can't load server, err: %v", err)
+		return err
 	}
+	if *printConfig {
+		return cfg.Dump(os.Stdout)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	go func() {
-		if err = app.Run(); err != nil {
-			log.Fatalf("server failed: %v", err)
-		}
-	}()
-	log.Println("shutting down...")
-
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
-	<-quit
-	if err := app.Stop(); err != nil {
-		fmt.Errorf("error during shutdown: %v", err)
+	app, err := app.New(cfg, loader)
+	if err != nil {
+		return fmt.Errorf("can't load server: %w", err)
 	}
+
+	return app.Run(ctx)
 }
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/app"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/config"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/migrator"
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+// migrate runs a migrate subcommand against the database of the config.
+func migrate(args []string) error {
+	if len(args) > 0 && args[0] == "create" {
+		return createMigration(args[1:])
+	}
+
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage, "\nFlags of migrate:\n")
+		fs.PrintDefaults()
+	}
+	cfg, _, err := loadConfig(fs, args, (*config.Loader).LoadDB)
+	if err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		return fmt.Errorf("migrate needs a subcommand\n\n%s", usage)
+	}
+
+	log := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	m, err := migrator.New(app.DSN(cfg), log)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch sub, args := args[0], args[1:]; sub {
+	case "up":
+		if len(args) == 0 {
+			return m.Up()
+		}
+		n, err := count(args)
+		if err != nil {
+			return err
+		}
+		return m.Steps(n)
+	case "down":
+		if len(args) == 0 {
+			return fmt.Errorf("migrate down needs the number of migrations to revert")
+		}
+		n, err := count(args)
+		if err != nil {
+			return err
+		}
+		return m.Steps(-n)
+	case "goto":
+		if len(args) != 1 {
+			return fmt.Errorf("migrate goto needs a version")
+		}
+		version, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		return m.Goto(uint(version))
+	case "force":
+		if len(args) != 1 {
+			return fmt.Errorf("migrate force needs a version")
+		}
+		version, err := strconv.Atoi(args[0])
+		if err != nil || version < -1 {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		return m.Force(version)
+	case "status":
+		return status(m)
+	default:
+		return fmt.Errorf("unknown migrate subcommand %q\n\n%s", sub, usage)
+	}
+}
+
+// count reads the positive number of migrations of up and down.
+func count(args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("want one number of migrations, got %d arguments", len(args))
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid number of migrations %q", args[0])
+	}
+	return n, nil
+}
+
+// status prints the schema version and every embedded migration with
+// whether it is applied.
+func status(m *migrator.Migrator) error {
+	version, dirty, err := m.Version()
+	if err != nil {
+		return err
+	}
+	list, err := m.Migrations()
+	if err != nil {
+		return err
+	}
+
+	state := ""
+	if dirty {
+		state = " (dirty: fix the schema, then migrate force)"
+	}
+	fmt.Printf("version %d%s\n", version, state)
+
+	pending := 0
+	for _, mig := range list {
+		applied := "applied"
+		if mig.Version > version {
+			applied = "pending"
+			pending++
+		}
+		fmt.Printf("  %06d  %-8s %s\n", mig.Version, applied, mig.Name)
+	}
+	fmt.Printf("%d pending\n", pending)
+
+	return nil
+}
+
+func createMigration(args []string) error {
+	fs := flag.NewFlagSet("migrate create", flag.ExitOnError)
+	dir := fs.String("dir", "migrations", "directory of the migration files")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("migrate create needs a name")
+	}
+
+	paths, err := migrator.Create(*dir, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		fmt.Println(path)
+	}
+	return nil
+}
@@ -2,136 +2,334 @@ package app
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"github.com/Fyefhqdishka/deadlock_v.2/internal/config"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/content"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/filter"
 	"github.com/Fyefhqdishka/deadlock_v.2/internal/handlers"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/health"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/logging"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/mail"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/metrics"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/migrator"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/notifications"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/ratelimit"
 	"github.com/Fyefhqdishka/deadlock_v.2/internal/service"
 	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage/postgres"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/tracing"
 	"github.com/Fyefhqdishka/deadlock_v.2/pkg/routes"
-	"github.com/golang-migrate/migrate/v4"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/joho/godotenv"
-	"github.com/rs/cors"
-	"log"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"time"
-
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
 type App struct {
-	db     *pgxpool.Pool
-	server *http.Server
+	// components are started by Run in order and stopped in reverse.
+	components []component
+	failures   chan error
+	// shutdownTimeout is the grace period of stopping all components.
+	shutdownTimeout time.Duration
+
+	log     *slog.Logger
+	logFile io.Closer
+	health  *health.Checker
 }
 
-func (s *App) Run() error {
-	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		return fmt.Errorf("server error: %v", err)
+// New creates new instance of application and sets the dependencies. It
+// applies pending migrations only with db.auto_migrate. With a loader, the
+// reloadable settings are reloaded from it on SIGHUP and when the config file
+// changes.
+func New(cfg *config.Config, loader *config.Loader) (*App, error) {
+	level := new(slog.LevelVar)
+	level.Set(cfg.Log.Level)
+	logCfg := cfg.Log.Logging()
+	logCfg.Level = level
+	log, logFile, err := logging.New(logCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log: %v", err)
 	}
-	return nil
-}
 
-func (s *App) Stop() error {
-	s.db.Close()
+	app := &App{
+		failures:        make(chan error, 1),
+		shutdownTimeout: cfg.Server.ShutdownTimeout,
+		log:             log,
+		logFile:         logFile,
+	}
+	// What was set up so far is released when New fails halfway.
+	fail := func(err error) (*App, error) {
+		app.stop(len(app.components))
+		logFile.Close()
+		return nil, err
+	}
 
-	err := s.server.Shutdown(context.Background())
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config(cfg.Tracing))
 	if err != nil {
-		return err
+		return fail(fmt.Errorf("failed to set up tracing: %v", err))
 	}
+	app.register(component{name: "tracing", stop: shutdownTracing})
 
-	return nil
-}
+	dbCfg := postgresConfig(cfg.DB, cfg.DB.Host, cfg.DB.Port)
+	db, err := postgres.ConnectDB(dbCfg)
+	if err != nil {
+		return fail(fmt.Errorf("failed to connect to database: %v", err))
+	}
+	app.register(component{name: "database", stop: func(context.Context) error {
+		db.Close()
+		return nil
+	}})
 
-// New creates new instance of application, sets the dependencies and applies migrations
-func New(cfg *config.Config) (*App, error) {
-	log := initLogging()
+	var replica *pgxpool.Pool
+	var read postgres.PgxPoolIface
+	if host := cfg.DB.Replica.Host; host != "" {
+		port := cfg.DB.Replica.Port
+		if port == "" {
+			port = cfg.DB.Port
+		}
+		// The replica is not waited for: while it is down, reads go to the
+		// primary.
+		replica, err = postgres.NewPool(postgresConfig(cfg.DB, host, port))
+		if err != nil {
+			return fail(fmt.Errorf("failed to create database replica pool: %v", err))
+		}
+		app.register(component{name: "database-replica", stop: func(context.Context) error {
+			replica.Close()
+			return nil
+		}})
+		read = replica
+	}
 
-	dsn := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=disable",
-		cfg.DB.User,
-		cfg.DB.Pass,
-		cfg.DB.Host,
-		cfg.DB.Port,
-		cfg.DB.Name,
-	)
+	if cfg.DB.AutoMigrate {
+		if err := migrator.UpLocked(context.Background(), db, dbCfg.DSN(), log); err != nil {
+			return fail(err)
+		}
+	}
+	latestMigration, err := migrator.Latest()
+	if err != nil {
+		return fail(err)
+	}
+
+	storage := postgres.NewStorage(db, read, log)
+
+	if cfg.Content.ImageProxyKey == "" {
+		log.Warn("IMAGE_PROXY_KEY is not set, proxied image URLs will not survive a restart")
+	}
+	images := content.NewImageProxy(cfg.Content.ImageProxyKey)
+	renderer := content.NewRenderer(images, cfg.Content.RenderCacheSize)
+
+	if cfg.Notifications.UnsubscribeKey == "" {
+		log.Warn("UNSUBSCRIBE_KEY is not set, unsubscribe links will not survive a restart")
+	}
+	tokens := notifications.NewUnsubscribeTokens(cfg.Notifications.UnsubscribeKey)
+	notifier := notifications.NewService(storage, tokens, log)
 
-	db, err := postgres.ConnectDB(dsn)
+	var mailer mail.Mailer = mail.NewLogMailer(log)
+	if smtp := cfg.Notifications.SMTP; smtp.Host != "" {
+		mailer = mail.NewSMTPMailer(mail.SMTPConfig(smtp))
+	}
+	digester := notifications.NewDigester(storage, mailer, tokens, cfg.Notifications.PublicURL, log)
+
+	contentFilter, err := filter.NewPipeline(cfg.Content.FilterFile, log)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %v", err)
+		return fail(fmt.Errorf("failed to load content filter: %v", err))
 	}
 
-	initMigrations(dsn)
+	appMetrics := metrics.New(db)
 
-	storage := postgres.NewStorage(db, log)
+	svc := service.NewService(storage, log, renderer, images, notifier, contentFilter, appMetrics, cfg.Moderation.ReportHideThreshold, cfg.Auth.BcryptCost)
 
-	service := service.NewService(storage, log)
+	handlers := handlers.NewHandlers(svc, notifier, appMetrics)
 
-	handlers := handlers.NewHandlers(service)
+	corsPolicy := newCORSPolicy(cfg.CORS.AllowedOrigins)
+
+	rateLimits, err := newRateLimitStore(cfg.RateLimit)
+	if err != nil {
+		return fail(fmt.Errorf("failed to create rate limit store: %v", err))
+	}
+	if closer, ok := rateLimits.(io.Closer); ok {
+		app.register(component{name: "rate-limit-store", stop: func(context.Context) error {
+			return closer.Close()
+		}})
+	}
+	limiter := newLimiter(cfg.RateLimit, rateLimits, svc, log)
+	var limit func(group string) mux.MiddlewareFunc
+	if limiter != nil {
+		limit = func(group string) mux.MiddlewareFunc {
+			return limiter.Middleware(group)
+		}
+	}
 
-	c := cors.New(cors.Options{
-		AllowedOrigins: []string{"http://localhost:5173"},
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
-		AllowedHeaders: []string{"Content-Type", "Authorization"},
-	})
 	r := mux.NewRouter()
-	routes.RegisterRoutes(r, handlers)
-	handler := c.Handler(r)
+	r.Use(logging.RouteMiddleware, tracing.RouteMiddleware)
+	routes.RegisterRoutes(r, handlers, limit)
+	handler := logging.Middleware(log)(appMetrics.Middleware(corsPolicy.Handler(r)))
+	handler = otelhttp.NewHandler(handler, "http.server")
 
-	addr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
+	checker := health.NewChecker(cfg.Server.HealthTimeout, cfg.Server.HealthCacheTTL, log)
+	checker.Add("database", health.Database(db))
+	checker.Add("migrations", health.Migrations(db, latestMigration))
+	if pinger, ok := rateLimits.(health.Pinger); ok {
+		checker.Add("redis", health.Ping(pinger))
+	}
 
-	log.Info("server starting in port", cfg.Server.Port)
+	// Probes bypass the middlewares, so they are neither rate limited nor
+	// written to the access log; the readiness checker caches its results
+	// instead.
+	root := http.NewServeMux()
+	root.HandleFunc("GET /healthz", checker.Liveness)
+	root.HandleFunc("GET /readyz", checker.Readiness)
+	root.Handle("/", handler)
 
-	app := &App{
-		db: db,
-		server: &http.Server{
-			Addr:           addr,
-			MaxHeaderBytes: 1 << 20,
-			Handler:        handler,
-			WriteTimeout:   cfg.Server.Timeout,
-			ReadTimeout:    cfg.Server.Timeout,
-			IdleTimeout:    cfg.Server.IdleTimeout,
-		},
+	app.health = checker
+
+	// Jobs use the database, so they are registered after it and stop
+	// before the pool closes.
+	for _, j := range []*job{
+		newJob("publisher", cfg.Content.PublishInterval, log, svc.PublishDuePosts),
+		newJob("digest", cfg.Notifications.DigestInterval, log, digester.SendDue),
+		newJob("content-filter", cfg.Content.FilterReloadInterval, log, contentFilter.ReloadIfChanged),
+	} {
+		app.register(component{name: "job:" + j.name, start: j.start, stop: j.stop})
 	}
 
-	return app, nil
-}
+	if loader != nil {
+		reload := &reloader{
+			loader:  loader,
+			log:     log,
+			level:   level,
+			cors:    corsPolicy,
+			filter:  contentFilter,
+			limiter: limiter,
+			current: cfg,
+		}
+		app.register(component{name: "config-reload", start: reload.start, stop: reload.stop})
 
-func initLogging() *slog.Logger {
-	logFileName := "logs/app-" + time.Now().Format("2006-01-02") + ".log"
-	logfile, err := os.OpenFile(logFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		log.Fatal("Не удалось открыть файл для логов: ", err)
+		if loader.File() != "" && cfg.Reload.WatchInterval > 0 {
+			if info, err := os.Stat(loader.File()); err == nil {
+				reload.modTime = info.ModTime()
+			}
+			j := newJob("config-watcher", cfg.Reload.WatchInterval, log, reload.ReloadIfChanged)
+			app.register(component{name: "job:" + j.name, start: j.start, stop: j.stop})
+		}
 	}
 
-	handler := slog.NewTextHandler(logfile, &slog.HandlerOptions{
-		Level: slog.LevelDebug,
-	})
+	if cfg.Metrics.Enabled {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", appMetrics.Handler())
+		app.register(app.serverComponent("metrics-server", &http.Server{
+			Addr:              cfg.Metrics.Addr,
+			Handler:           metricsMux,
+			ReadHeaderTimeout: cfg.Server.Timeout,
+		}))
+	}
 
-	return slog.New(handler)
-}
+	var tlsConfig *tls.Config
+	if cfg.Server.TLS.Enabled() {
+		cert, err := tls.LoadX509KeyPair(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
+		if err != nil {
+			return fail(fmt.Errorf("failed to load TLS certificate: %v", err))
+		}
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+			NextProtos:   []string{"h2", "http/1.1"},
+		}
+	}
 
-func initMigrations(dsn string) {
-	m, err := migrate.New(
-		"file://migrations",
-		dsn,
-	)
-	if err != nil {
-		log.Fatalf("Ошибка при создании объекта миграции: %v", err)
+	server := &http.Server{
+		Addr:           fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port),
+		MaxHeaderBytes: 1 << 20,
+		Handler:        root,
+		WriteTimeout:   cfg.Server.Timeout,
+		ReadTimeout:    cfg.Server.Timeout,
+		IdleTimeout:    cfg.Server.IdleTimeout,
+		TLSConfig:      tlsConfig,
+	}
+	// Notification streams never end on their own, so they are closed as soon
+	// as shutdown begins instead of holding it up.
+	server.RegisterOnShutdown(notifier.Close)
+
+	httpServer := app.serverComponent("http-server", server)
+	drain := httpServer.stop
+	httpServer.stop = func(ctx context.Context) error {
+		// Fail readiness first and keep serving for the delay, so load
+		// balancers stop sending new requests before the server drains.
+		checker.ShutDown()
+		log.Info("readiness failed, waiting before draining", "delay", cfg.Server.ShutdownDelay)
+		select {
+		case <-time.After(cfg.Server.ShutdownDelay):
+		case <-ctx.Done():
+		}
+		return drain(ctx)
 	}
+	app.register(httpServer)
+
+	return app, nil
+}
+
+// DSN is the URL of the primary database of cfg, the one migrations run on.
+func DSN(cfg *config.Config) string {
+	return postgresConfig(cfg.DB, cfg.DB.Host, cfg.DB.Port).DSN()
+}
 
-	err = m.Up()
-	if err != nil && err != migrate.ErrNoChange {
-		log.Fatalf("Ошибка при применении миграций: %v", err)
+// postgresConfig describes a pool to the database server at host and port.
+func postgresConfig(db config.DB, host, port string) postgres.Config {
+	return postgres.Config{
+		Host:              host,
+		Port:              port,
+		User:              db.User,
+		Password:          db.Pass,
+		Database:          db.Name,
+		SSLMode:           db.SSLMode,
+		SSLRootCert:       db.SSLRootCert,
+		SSLCert:           db.SSLCert,
+		SSLKey:            db.SSLKey,
+		MaxConns:          db.MaxConns,
+		MinConns:          db.MinConns,
+		MaxConnLifetime:   db.MaxConnLifetime,
+		MaxConnIdleTime:   db.MaxConnIdleTime,
+		HealthCheckPeriod: db.HealthCheckPeriod,
+		StatementTimeout:  db.StatementTimeout,
 	}
+}
 
-	fmt.Println("Миграции успешно применены!")
+func newRateLimitStore(cfg config.RateLimit) (ratelimit.Store, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.Store == "redis" {
+		return ratelimit.NewRedisStore(cfg.RedisURL)
+	}
+	return ratelimit.NewMemoryStore(), nil
 }
 
-func init() {
-	if err := godotenv.Load(); err != nil {
-		log.Fatalf("can't load env file, err=%v", err)
+// newLimiter builds the rate limiter of the route groups, or returns nil when
+// rate limiting is disabled.
+func newLimiter(cfg config.RateLimit, store ratelimit.Store, service *service.Service, log *slog.Logger) *ratelimit.Limiter {
+	if !cfg.Enabled {
+		log.Warn("rate limiting is disabled")
+		return nil
 	}
+
+	limiter := ratelimit.NewLimiter(store, cfg.Policies, cfg.Default, log)
+	limiter.TrustedProxies = cfg.TrustedProxies
+	limiter.User = func(r *http.Request) string {
+		sessionID := r.Header.Get("Authorization")
+		if sessionID == "" {
+			return ""
+		}
+		userID, err := service.GetUserByID(r.Context(), sessionID)
+		if err != nil {
+			return ""
+		}
+		logging.SetUserID(r.Context(), userID)
+		return userID
+	}
+
+	return limiter
 }
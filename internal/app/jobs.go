@@ -0,0 +1,93 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// job runs a background batch task every interval. A run calls the task until
+// it reports no more work, so a backlog is drained without waiting for the
+// next tick. Every replica runs its own jobs; tasks claim their rows with
+// SKIP LOCKED, so work is never done twice.
+type job struct {
+	name     string
+	task     func(ctx context.Context) (int, error)
+	log      *slog.Logger
+	interval time.Duration
+
+	cancel  context.CancelFunc
+	started atomic.Bool
+	done    chan struct{}
+}
+
+func newJob(name string, interval time.Duration, log *slog.Logger, task func(ctx context.Context) (int, error)) *job {
+	return &job{
+		name:     name,
+		task:     task,
+		log:      log,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+// start runs the job until ctx is canceled or stop is called. It has the
+// shape of a component start.
+func (j *job) start(ctx context.Context) error {
+	if j.started.CompareAndSwap(false, true) {
+		ctx, j.cancel = context.WithCancel(ctx)
+		go j.run(ctx)
+	}
+	return nil
+}
+
+// stop cancels the running batch and waits for the goroutine to exit, or
+// until ctx is done. A job that was never started stays stopped.
+func (j *job) stop(ctx context.Context) error {
+	if !j.started.Load() {
+		return nil
+	}
+
+	j.cancel()
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("job %s did not stop in time", j.name)
+	}
+}
+
+func (j *job) run(ctx context.Context) {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *job) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := j.task(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				j.log.ErrorContext(ctx, "background job failed", "job", j.name, "err", err)
+			}
+			return
+		}
+		if n == 0 {
+			return
+		}
+		j.log.InfoContext(ctx, "background job batch done", "job", j.name, "count", n)
+	}
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// component is a part of the app with a lifecycle. start must not block: a
+// component that keeps running does so in its own goroutine and reports a
+// failure through fail. Either func may be nil.
+type component struct {
+	name  string
+	start func(ctx context.Context) error
+	stop  func(ctx context.Context) error
+}
+
+// register adds a component. Components start in the order they are
+// registered and stop in reverse, so a component may rely on everything
+// registered before it.
+func (s *App) register(c component) {
+	s.components = append(s.components, c)
+}
+
+// fail reports that a running component broke, which shuts the app down.
+func (s *App) fail(name string, err error) {
+	select {
+	case s.failures <- fmt.Errorf("%s: %w", name, err):
+	default:
+	}
+}
+
+// Run starts the components and blocks until ctx is canceled or a component
+// fails, then stops what was started within the shutdown grace period. It
+// returns the failure that ended the run joined with the errors of shutdown.
+func (s *App) Run(ctx context.Context) error {
+	defer s.logFile.Close()
+
+	var runErr error
+	started := 0
+	for _, c := range s.components {
+		if c.start != nil {
+			if err := c.start(ctx); err != nil {
+				runErr = fmt.Errorf("failed to start %s: %w", c.name, err)
+				break
+			}
+		}
+		started++
+		s.log.Info("component started", "component", c.name)
+	}
+
+	if runErr == nil {
+		select {
+		case <-ctx.Done():
+			s.log.Info("shutting down", "grace", s.shutdownTimeout)
+		case runErr = <-s.failures:
+			s.log.Error("component failed, shutting down", "err", runErr)
+		}
+	}
+
+	stopErr := s.stop(started)
+	if stopErr != nil {
+		s.log.Error("shutdown failed", "err", stopErr)
+	} else {
+		s.log.Info("shutdown complete")
+	}
+
+	return errors.Join(runErr, stopErr)
+}
+
+// stop stops the first n components in reverse order. They share one
+// deadline; a component that misses it does not keep the rest from stopping.
+func (s *App) stop(n int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := n - 1; i >= 0; i-- {
+		c := s.components[i]
+		if c.stop == nil {
+			continue
+		}
+		if err := c.stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", c.name, err))
+			continue
+		}
+		s.log.Info("component stopped", "component", c.name)
+	}
+
+	return errors.Join(errs...)
+}
+
+// serverComponent listens on the address of srv when started, so a port in
+// use fails the start, and drains the server when stopped. It serves HTTPS
+// when srv has a TLS config.
+func (s *App) serverComponent(name string, srv *http.Server) component {
+	return component{
+		name: name,
+		start: func(ctx context.Context) error {
+			ln, err := net.Listen("tcp", srv.Addr)
+			if err != nil {
+				return err
+			}
+			if srv.TLSConfig != nil {
+				ln = tls.NewListener(ln, srv.TLSConfig)
+			}
+			s.log.Info("listening", "component", name, "addr", ln.Addr().String())
+
+			go func() {
+				if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					s.fail(name, err)
+				}
+			}()
+			return nil
+		},
+		stop: srv.Shutdown,
+	}
+}
@@ -0,0 +1,175 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/config"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/filter"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/ratelimit"
+	"github.com/rs/cors"
+)
+
+// reloader applies the settings config.Reloadable lists to the running app
+// when it gets SIGHUP or the config file changes. A config that fails to load
+// or validate is rejected as a whole and the running one is kept.
+type reloader struct {
+	loader  *config.Loader
+	log     *slog.Logger
+	level   *slog.LevelVar
+	cors    *corsPolicy
+	filter  *filter.Pipeline
+	limiter *ratelimit.Limiter // nil when rate limiting is disabled
+
+	mu sync.Mutex
+	// current is the config the app runs with: the one it started with and
+	// the reloadable settings applied since.
+	current *config.Config
+	modTime time.Time
+
+	signals chan os.Signal
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+func (r *reloader) reload(ctx context.Context, trigger string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.loader.Load()
+	if err != nil {
+		r.log.ErrorContext(ctx, "config reload rejected, keeping the current config", "trigger", trigger, "err", err)
+		return err
+	}
+
+	var changed, restart []string
+	for _, path := range r.current.Changes(next) {
+		if config.Reloadable(path) {
+			changed = append(changed, path)
+		} else {
+			restart = append(restart, path)
+		}
+	}
+	if len(restart) > 0 {
+		r.log.WarnContext(ctx, "config changes need a restart and were not applied", "trigger", trigger, "settings", restart)
+	}
+
+	// The filter file is the only part that can still fail, so it goes first
+	// and a broken file leaves everything else untouched. It is read even if
+	// the path did not change, so SIGHUP also reloads the lists.
+	if err := r.filter.Reload(ctx, next.Content.FilterFile); err != nil {
+		r.log.ErrorContext(ctx, "config reload rejected, keeping the current config", "trigger", trigger, "err", err)
+		return err
+	}
+	r.level.Set(next.Log.Level)
+	r.cors.set(next.CORS.AllowedOrigins)
+	if r.limiter != nil {
+		r.limiter.SetPolicies(next.RateLimit.Policies, next.RateLimit.Default)
+	}
+
+	applied := *r.current
+	applied.Log.Level = next.Log.Level
+	applied.CORS = next.CORS
+	applied.RateLimit.Default = next.RateLimit.Default
+	applied.RateLimit.Policies = next.RateLimit.Policies
+	applied.Content.FilterFile = next.Content.FilterFile
+	r.current = &applied
+
+	r.log.InfoContext(ctx, "config reloaded", "trigger", trigger, "changed", changed)
+
+	return nil
+}
+
+// ReloadIfChanged reloads when the config file was modified since it was last
+// seen and reports 1 if it did. It has the shape of a background job task; a
+// rejected config is logged by reload, not retried until the file changes
+// again.
+func (r *reloader) ReloadIfChanged(ctx context.Context) (int, error) {
+	info, err := os.Stat(r.loader.File())
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat config file: %v", err)
+	}
+
+	r.mu.Lock()
+	seen := info.ModTime().Equal(r.modTime)
+	r.modTime = info.ModTime()
+	r.mu.Unlock()
+	if seen {
+		return 0, nil
+	}
+
+	if err := r.reload(ctx, "file"); err != nil {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// start reloads on every SIGHUP until stopped. It has the shape of a
+// component start.
+func (r *reloader) start(ctx context.Context) error {
+	r.signals = make(chan os.Signal, 1)
+	r.quit = make(chan struct{})
+	r.done = make(chan struct{})
+	signal.Notify(r.signals, syscall.SIGHUP)
+
+	go func() {
+		defer close(r.done)
+		for {
+			select {
+			case <-r.signals:
+				r.reload(ctx, "SIGHUP")
+			case <-r.quit:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (r *reloader) stop(ctx context.Context) error {
+	signal.Stop(r.signals)
+	close(r.quit)
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("config reload did not stop in time")
+	}
+}
+
+// corsPolicy is the CORS middleware, rebuilt when the allowed origins change.
+type corsPolicy struct {
+	cors atomic.Pointer[cors.Cors]
+}
+
+func newCORSPolicy(origins []string) *corsPolicy {
+	p := &corsPolicy{}
+	p.set(origins)
+	return p
+}
+
+func (p *corsPolicy) set(origins []string) {
+	p.cors.Store(cors.New(cors.Options{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		ExposedHeaders: []string{"RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
+	}))
+}
+
+func (p *corsPolicy) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.cors.Load().ServeHTTP(w, r, next.ServeHTTP)
+	})
+}
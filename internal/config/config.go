@@ -1,73 +1,353 @@
+// Package config loads the configuration in layers: built-in defaults, then
+// an optional YAML file, then environment variables, then command line flags.
+// Every setting is described once by the tags of its field: yaml is its key
+// in the file, env its variable, from which the flag name is derived
+// (DB_HOST becomes -db-host), and secret marks values that are redacted when
+// the configuration is dumped.
 package config
 
 import (
-	"fmt"
-	"os"
+	"log/slog"
 	"time"
 
-	_ "github.com/jackc/pgx/v5/pgxpool"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/logging"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/ratelimit"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/tracing"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Config struct {
-	DB     DB
-	Server Server
+	DB            DB            `yaml:"db"`
+	Server        Server        `yaml:"server"`
+	CORS          CORS          `yaml:"cors"`
+	Auth          Auth          `yaml:"auth"`
+	Redis         Redis         `yaml:"redis"`
+	Content       Content       `yaml:"content"`
+	Notifications Notifications `yaml:"notifications"`
+	Moderation    Moderation    `yaml:"moderation"`
+	RateLimit     RateLimit     `yaml:"rate_limit"`
+	Log           Log           `yaml:"log"`
+	Metrics       Metrics       `yaml:"metrics"`
+	Tracing       Tracing       `yaml:"tracing"`
+	Reload        Reload        `yaml:"reload"`
 }
 
 type DB struct {
-	Host string
-	Port string
-	User string
-	Pass string
-	Name string
+	Host string `yaml:"host" env:"DB_HOST"`
+	Port string `yaml:"port" env:"DB_PORT"`
+	User string `yaml:"user" env:"DB_USER"`
+	Pass string `yaml:"pass" env:"DB_PASS" secret:"true"`
+	Name string `yaml:"name" env:"DB_NAME"`
+	// SSLMode is a libpq mode: disable, allow, prefer, require, verify-ca or
+	// verify-full. SSLRootCert is the CA of the server certificate; SSLCert
+	// and SSLKey authenticate the app with a client certificate.
+	SSLMode     string `yaml:"sslmode" env:"DB_SSLMODE"`
+	SSLRootCert string `yaml:"sslrootcert" env:"DB_SSLROOTCERT"`
+	SSLCert     string `yaml:"sslcert" env:"DB_SSLCERT"`
+	SSLKey      string `yaml:"sslkey" env:"DB_SSLKEY"`
+	// MaxConns and MinConns size each connection pool. Connections are
+	// closed after MaxConnLifetime, or MaxConnIdleTime unused, and idle ones
+	// are checked every HealthCheckPeriod.
+	MaxConns          int32         `yaml:"max_conns" env:"DB_MAX_CONNS"`
+	MinConns          int32         `yaml:"min_conns" env:"DB_MIN_CONNS"`
+	MaxConnLifetime   time.Duration `yaml:"max_conn_lifetime" env:"DB_MAX_CONN_LIFETIME"`
+	MaxConnIdleTime   time.Duration `yaml:"max_conn_idle_time" env:"DB_MAX_CONN_IDLE_TIME"`
+	HealthCheckPeriod time.Duration `yaml:"health_check_period" env:"DB_HEALTH_CHECK_PERIOD"`
+	// StatementTimeout cancels statements of the app running longer. It is
+	// set on every connection of the pools, not on migrations. Zero disables
+	// it.
+	StatementTimeout time.Duration `yaml:"statement_timeout" env:"DB_STATEMENT_TIMEOUT"`
+	// AutoMigrate applies pending migrations at startup. Otherwise they are
+	// applied with the migrate command and the app only checks the version.
+	AutoMigrate bool    `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE"`
+	Replica     Replica `yaml:"replica"`
+}
+
+// Replica is a read replica of the database, used by the anonymous listings
+// and search that tolerate replication lag, and skipped while it is down. It
+// shares the credentials and settings of the primary; without a host
+// everything goes to the primary.
+type Replica struct {
+	Host string `yaml:"host" env:"DB_REPLICA_HOST"`
+	// Port defaults to the port of the primary.
+	Port string `yaml:"port" env:"DB_REPLICA_PORT"`
 }
 
 type Server struct {
-	Host        string
-	Port        string
-	Timeout     time.Duration
-	IdleTimeout time.Duration
+	Host        string        `yaml:"host" env:"SRV_HOST"`
+	Port        string        `yaml:"port" env:"SRV_PORT"`
+	Timeout     time.Duration `yaml:"timeout" env:"SRV_TIMEOUT"`
+	IdleTimeout time.Duration `yaml:"idle_timeout" env:"SRV_IDLE_TIMEOUT"`
+	// HealthTimeout bounds each dependency check of the readiness probe.
+	HealthTimeout time.Duration `yaml:"health_timeout" env:"SRV_HEALTH_TIMEOUT"`
+	// HealthCacheTTL is how long a readiness result is served before the
+	// checks run again.
+	HealthCacheTTL time.Duration `yaml:"health_cache_ttl" env:"SRV_HEALTH_CACHE_TTL"`
+	// ShutdownTimeout is the grace period for draining requests and stopping
+	// background work once a shutdown begins.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SRV_SHUTDOWN_TIMEOUT"`
+	// ShutdownDelay is how long readiness fails before the server starts
+	// draining, so load balancers notice and stop sending new requests. It
+	// is part of the shutdown grace period.
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" env:"SRV_SHUTDOWN_DELAY"`
+	TLS           TLS           `yaml:"tls"`
+}
+
+// TLS makes the server speak HTTPS when both files are set.
+type TLS struct {
+	CertFile string `yaml:"cert_file" env:"SRV_TLS_CERT_FILE"`
+	KeyFile  string `yaml:"key_file" env:"SRV_TLS_KEY_FILE"`
+}
+
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// CORS lists the origins browsers may call the API from. "*" allows any.
+type CORS struct {
+	AllowedOrigins []string `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+}
+
+type Auth struct {
+	// BcryptCost is the work factor of password hashes. Raising it only
+	// affects passwords set from then on.
+	BcryptCost int `yaml:"bcrypt_cost" env:"AUTH_BCRYPT_COST"`
+}
+
+// Redis is the shared Redis of the replicas, used by the rate limiter
+// unless it has an URL of its own.
+type Redis struct {
+	URL string `yaml:"url" env:"REDIS_URL" secret:"url"`
+}
+
+// Content configures rendering of post content.
+type Content struct {
+	// ImageProxyKey signs the proxied URLs of images in rendered posts.
+	ImageProxyKey   string `yaml:"image_proxy_key" env:"IMAGE_PROXY_KEY" secret:"true"`
+	RenderCacheSize int    `yaml:"render_cache_size" env:"RENDER_CACHE_SIZE"`
+	// PublishInterval is how often scheduled posts are checked for publication.
+	PublishInterval time.Duration `yaml:"publish_interval" env:"PUBLISH_INTERVAL"`
+	// FilterFile is the JSON config of the content filter, checked for changes
+	// every FilterReloadInterval. Without it the built-in defaults are used.
+	FilterFile           string        `yaml:"filter_file" env:"CONTENT_FILTER_FILE"`
+	FilterReloadInterval time.Duration `yaml:"filter_reload_interval" env:"CONTENT_FILTER_RELOAD_INTERVAL"`
+}
+
+// Notifications configures the email digest. Without SMTP.Host digests are
+// only logged.
+type Notifications struct {
+	// PublicURL is the base URL of the site used in links inside emails.
+	PublicURL      string        `yaml:"public_url" env:"PUBLIC_URL"`
+	UnsubscribeKey string        `yaml:"unsubscribe_key" env:"UNSUBSCRIBE_KEY" secret:"true"`
+	DigestInterval time.Duration `yaml:"digest_interval" env:"DIGEST_INTERVAL"`
+	SMTP           SMTP          `yaml:"smtp"`
+}
+
+// Moderation configures the report queue.
+type Moderation struct {
+	// ReportHideThreshold is the number of distinct reports that hides a post
+	// or comment until a moderator reviews it. Zero disables hiding.
+	ReportHideThreshold int `yaml:"report_hide_threshold" env:"REPORT_HIDE_THRESHOLD"`
+}
+
+// RateLimit configures the per route group request limits. Groups are the
+// route groups of pkg/routes; those without a policy use Default. Policies
+// are written as ratelimit.ParsePolicy reads them, and RATE_LIMIT_<GROUP>
+// overrides the policy of a group.
+type RateLimit struct {
+	Enabled bool `yaml:"enabled" env:"RATE_LIMIT_ENABLED"`
+	// Store is memory or redis. Redis shares the limits between replicas.
+	Store string `yaml:"store" env:"RATE_LIMIT_STORE"`
+	// RedisURL defaults to Redis.URL.
+	RedisURL       string           `yaml:"redis_url" env:"RATE_LIMIT_REDIS_URL" secret:"url"`
+	TrustedProxies int              `yaml:"trusted_proxies" env:"RATE_LIMIT_TRUSTED_PROXIES"`
+	Default        ratelimit.Policy `yaml:"default" env:"RATE_LIMIT_DEFAULT"`
+	Policies       RatePolicies     `yaml:"policies"`
+}
+
+// Log configures the application log. File output goes to Dir and is rotated
+// by size and time; rotated files older than MaxAge or beyond MaxFiles are
+// removed. Containers should log to stdout instead.
+type Log struct {
+	Level slog.Level `yaml:"level" env:"LOG_LEVEL"`
+	// Format is text or json.
+	Format string `yaml:"format" env:"LOG_FORMAT"`
+	// Output is file or stdout.
+	Output      string        `yaml:"output" env:"LOG_OUTPUT"`
+	Dir         string        `yaml:"dir" env:"LOG_DIR"`
+	MaxSizeMB   int64         `yaml:"max_size_mb" env:"LOG_MAX_SIZE_MB"`
+	RotateEvery time.Duration `yaml:"rotate_every" env:"LOG_ROTATE_EVERY"`
+	MaxAge      time.Duration `yaml:"max_age" env:"LOG_MAX_AGE"`
+	MaxFiles    int           `yaml:"max_files" env:"LOG_MAX_FILES"`
+}
+
+// Logging is the logger config of l.
+func (l Log) Logging() logging.Config {
+	return logging.Config{
+		Level:       l.Level,
+		Format:      l.Format,
+		Output:      l.Output,
+		Dir:         l.Dir,
+		MaxSize:     l.MaxSizeMB << 20,
+		RotateEvery: l.RotateEvery,
+		MaxAge:      l.MaxAge,
+		MaxFiles:    l.MaxFiles,
+	}
+}
+
+// Metrics configures the Prometheus endpoint. It is served on Addr, apart
+// from the public server, so it can be kept off the internet.
+type Metrics struct {
+	Enabled bool   `yaml:"enabled" env:"METRICS_ENABLED"`
+	Addr    string `yaml:"addr" env:"METRICS_ADDR"`
+}
+
+// Tracing configures OpenTelemetry. Exporter is none, stdout or otlp; with
+// otlp spans are sent to Endpoint, an OTLP/HTTP traces URL. Headers are
+// written as name=value pairs separated by commas, e.g. an API key of the
+// collector.
+type Tracing struct {
+	Exporter    string            `yaml:"exporter" env:"TRACING_EXPORTER"`
+	Endpoint    string            `yaml:"endpoint" env:"TRACING_ENDPOINT"`
+	Headers     map[string]string `yaml:"headers" env:"TRACING_HEADERS" secret:"true"`
+	ServiceName string            `yaml:"service_name" env:"TRACING_SERVICE_NAME"`
+	SampleRatio float64           `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+// Reload configures hot reloading of the settings Reloadable lists, on SIGHUP
+// and when the config file changes. The file is checked every WatchInterval;
+// zero leaves only SIGHUP.
+type Reload struct {
+	WatchInterval time.Duration `yaml:"watch_interval" env:"CONFIG_WATCH_INTERVAL"`
+}
+
+type SMTP struct {
+	Host string `yaml:"host" env:"SMTP_HOST"`
+	Port string `yaml:"port" env:"SMTP_PORT"`
+	User string `yaml:"user" env:"SMTP_USER"`
+	Pass string `yaml:"pass" env:"SMTP_PASS" secret:"true"`
+	From string `yaml:"from" env:"SMTP_FROM"`
 }
 
 const (
-	DefaultTimeout     = 10 * time.Second
-	DefaultIdleTimeout = 60 * time.Second
+	DefaultServerPort      = "8000"
+	DefaultTimeout         = 10 * time.Second
+	DefaultIdleTimeout     = 60 * time.Second
+	DefaultHealthTimeout   = 2 * time.Second
+	DefaultHealthCacheTTL  = time.Second
+	DefaultShutdownTimeout = 30 * time.Second
+	DefaultShutdownDelay   = 5 * time.Second
+	DefaultDBMaxConns      = 10
+	DefaultDBSSLMode       = "disable"
+	DefaultConnLifetime    = time.Hour
+	DefaultConnIdleTime    = 30 * time.Minute
+	DefaultDBHealthCheck   = time.Minute
+	DefaultStmtTimeout     = 30 * time.Second
+	DefaultRenderCacheSize = 1000
+	DefaultPublishInterval = 30 * time.Second
+	DefaultDigestInterval  = time.Hour
+	DefaultSMTPPort        = "587"
+	DefaultReportThreshold = 5
+	DefaultFilterReload    = 10 * time.Second
+	DefaultLogDir          = "logs"
+	DefaultLogMaxSizeMB    = 100
+	DefaultLogRotateEvery  = 24 * time.Hour
+	DefaultLogMaxAge       = 7 * 24 * time.Hour
+	DefaultLogMaxFiles     = 30
+	DefaultMetricsAddr     = "localhost:9090"
+	DefaultTracingEndpoint = "http://localhost:4318/v1/traces"
+	DefaultServiceName     = "deadlock"
+	DefaultCORSOrigin      = "http://localhost:5173"
+	DefaultWatchInterval   = 10 * time.Second
 )
 
-func LoadFromEnv() (*Config, error) {
-	cfg := &Config{
+// DefaultRatePolicies are the built-in limits: strict on login and
+// registration, per user on the groups that create content.
+var DefaultRatePolicies = map[string]ratelimit.Policy{
+	"auth":       {Requests: 10, Period: time.Minute, Algorithm: ratelimit.SlidingWindow, Key: ratelimit.KeyIP},
+	"posts":      {Requests: 120, Period: time.Minute, Burst: 30, Algorithm: ratelimit.TokenBucket, Key: ratelimit.KeyUser},
+	"comments":   {Requests: 120, Period: time.Minute, Burst: 30, Algorithm: ratelimit.TokenBucket, Key: ratelimit.KeyUser},
+	"dialogs":    {Requests: 30, Period: time.Minute, Algorithm: ratelimit.TokenBucket, Key: ratelimit.KeyUser},
+	"search":     {Requests: 60, Period: time.Minute, Algorithm: ratelimit.TokenBucket, Key: ratelimit.KeyIP},
+	"moderation": {Requests: 30, Period: time.Minute, Algorithm: ratelimit.SlidingWindow, Key: ratelimit.KeyUser},
+}
+
+var DefaultRatePolicy = ratelimit.Policy{Requests: 300, Period: time.Minute, Algorithm: ratelimit.TokenBucket, Key: ratelimit.KeyIP}
+
+// Default is the configuration before any layer is applied. The database
+// connection has no defaults and must be configured.
+func Default() *Config {
+	policies := make(RatePolicies, len(DefaultRatePolicies))
+	for group, p := range DefaultRatePolicies {
+		policies[group] = p
+	}
+
+	return &Config{
 		DB: DB{
-			Host: os.Getenv("DB_HOST"),
-			Port: os.Getenv("DB_PORT"),
-			User: os.Getenv("DB_USER"),
-			Pass: os.Getenv("DB_PASS"),
-			Name: os.Getenv("DB_NAME"),
+			SSLMode:           DefaultDBSSLMode,
+			MaxConns:          DefaultDBMaxConns,
+			MaxConnLifetime:   DefaultConnLifetime,
+			MaxConnIdleTime:   DefaultConnIdleTime,
+			HealthCheckPeriod: DefaultDBHealthCheck,
+			StatementTimeout:  DefaultStmtTimeout,
 		},
 		Server: Server{
-			Host: os.Getenv("SRV_HOST"),
-			Port: os.Getenv("SRV_PORT"),
+			Port:            DefaultServerPort,
+			Timeout:         DefaultTimeout,
+			IdleTimeout:     DefaultIdleTimeout,
+			HealthTimeout:   DefaultHealthTimeout,
+			HealthCacheTTL:  DefaultHealthCacheTTL,
+			ShutdownTimeout: DefaultShutdownTimeout,
+			ShutdownDelay:   DefaultShutdownDelay,
+		},
+		CORS: CORS{
+			AllowedOrigins: []string{DefaultCORSOrigin},
+		},
+		Auth: Auth{
+			BcryptCost: bcrypt.DefaultCost,
+		},
+		Content: Content{
+			RenderCacheSize:      DefaultRenderCacheSize,
+			PublishInterval:      DefaultPublishInterval,
+			FilterReloadInterval: DefaultFilterReload,
+		},
+		Notifications: Notifications{
+			DigestInterval: DefaultDigestInterval,
+			SMTP: SMTP{
+				Port: DefaultSMTPPort,
+			},
+		},
+		Moderation: Moderation{
+			ReportHideThreshold: DefaultReportThreshold,
+		},
+		RateLimit: RateLimit{
+			Enabled:  true,
+			Store:    "memory",
+			Default:  DefaultRatePolicy,
+			Policies: policies,
+		},
+		Log: Log{
+			Level:       slog.LevelDebug,
+			Format:      logging.FormatText,
+			Output:      logging.OutputFile,
+			Dir:         DefaultLogDir,
+			MaxSizeMB:   DefaultLogMaxSizeMB,
+			RotateEvery: DefaultLogRotateEvery,
+			MaxAge:      DefaultLogMaxAge,
+			MaxFiles:    DefaultLogMaxFiles,
+		},
+		Metrics: Metrics{
+			Enabled: true,
+			Addr:    DefaultMetricsAddr,
+		},
+		Tracing: Tracing{
+			Exporter:    tracing.ExporterNone,
+			Endpoint:    DefaultTracingEndpoint,
+			ServiceName: DefaultServiceName,
+			SampleRatio: 1,
+		},
+		Reload: Reload{
+			WatchInterval: DefaultWatchInterval,
 		},
 	}
-
-	timeout := DefaultTimeout
-	if val := os.Getenv("SRV_TIMEOUT"); val != "" {
-		parsed, err := time.ParseDuration(val)
-		if err != nil {
-			return nil, fmt.Errorf("invalid SRV_TIMEOUT: %v", err)
-		}
-		timeout = parsed
-	}
-
-	idleTimeout := DefaultIdleTimeout
-	if val := os.Getenv("SRV_IDLE_TIMEOUT"); val != "" {
-		parsed, err := time.ParseDuration(val)
-		if err != nil {
-			return nil, fmt.Errorf("invalid SRV_IDLE_TIMEOUT: %v", err)
-		}
-		idleTimeout = parsed
-	}
-
-	cfg.Server.Timeout = timeout
-	cfg.Server.IdleTimeout = idleTimeout
-
-	return cfg, nil
 }
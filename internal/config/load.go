@@ -0,0 +1,314 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/ratelimit"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvFile is read into the environment when present. Variables that are
+// already set take precedence over it.
+const EnvFile = ".env"
+
+// rateLimitEnvPrefix starts the variables that override the policy of one
+// route group, e.g. RATE_LIMIT_POSTS.
+const rateLimitEnvPrefix = "RATE_LIMIT_"
+
+// Loader builds the configuration from its layers. It keeps the flags it
+// registered, so the configuration can be loaded again later with the same
+// command line.
+type Loader struct {
+	file     string
+	flags    map[string]string
+	policies []string
+}
+
+// NewLoader registers the flags of the configuration on fs: -config, the
+// path of the YAML file, one flag for each setting with an env variable and
+// the repeatable -rate-limit group=policy. Load must be called after fs is
+// parsed.
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{flags: make(map[string]string)}
+
+	fs.StringVar(&l.file, "config", os.Getenv("CONFIG_FILE"), "path of the YAML config file (env CONFIG_FILE)")
+	for _, f := range fields(Default()) {
+		if f.env == "" {
+			continue
+		}
+		env := f.env
+		fs.Func(flagName(env), fmt.Sprintf("sets %s (env %s)", f.path, env), func(val string) error {
+			l.flags[env] = val
+			return nil
+		})
+	}
+	fs.Func("rate-limit", "sets the policy of a route group as group=policy, may be repeated", func(val string) error {
+		l.policies = append(l.policies, val)
+		return nil
+	})
+
+	return l
+}
+
+// File is the path of the YAML file, empty without one.
+func (l *Loader) File() string {
+	return l.file
+}
+
+// Load applies the layers over the defaults in order: the YAML file, the
+// environment and the flags. It does not stop at the first bad value; the
+// errors of all layers and of validation are reported together.
+func (l *Loader) Load() (*Config, error) {
+	return l.load((*Config).Validate)
+}
+
+// LoadDB is Load for commands that only talk to the database, such as
+// migrate: it validates the db section only, so settings of the server the
+// command does not run can't stop it.
+func (l *Loader) LoadDB() (*Config, error) {
+	return l.load((*Config).ValidateDB)
+}
+
+func (l *Loader) load(validate func(*Config) error) (*Config, error) {
+	var errs []error
+
+	if err := godotenv.Load(EnvFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		errs = append(errs, fmt.Errorf("%s: %w", EnvFile, err))
+	}
+
+	cfg := Default()
+	if l.file != "" {
+		if err := loadFile(cfg, l.file); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	fieldsByEnv := make(map[string]field)
+	for _, f := range fields(cfg) {
+		if f.env == "" {
+			continue
+		}
+		fieldsByEnv[f.env] = f
+		if val, ok := os.LookupEnv(f.env); ok {
+			if err := setString(f.value, val); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.env, err))
+			}
+		}
+	}
+	for _, kv := range os.Environ() {
+		name, val, _ := strings.Cut(kv, "=")
+		if _, ok := fieldsByEnv[name]; ok || !strings.HasPrefix(name, rateLimitEnvPrefix) {
+			continue
+		}
+		group := strings.ToLower(strings.TrimPrefix(name, rateLimitEnvPrefix))
+		if err := cfg.RateLimit.Policies.set(group, val); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	for _, f := range fields(cfg) {
+		val, ok := l.flags[f.env]
+		if f.env == "" || !ok {
+			continue
+		}
+		if err := setString(f.value, val); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", flagName(f.env), err))
+		}
+	}
+	for _, val := range l.policies {
+		group, spec, ok := strings.Cut(val, "=")
+		if !ok {
+			errs = append(errs, fmt.Errorf("-rate-limit: want group=policy, got %q", val))
+			continue
+		}
+		if err := cfg.RateLimit.Policies.set(group, spec); err != nil {
+			errs = append(errs, fmt.Errorf("-rate-limit %s: %w", group, err))
+		}
+	}
+
+	if cfg.RateLimit.RedisURL == "" {
+		cfg.RateLimit.RedisURL = cfg.Redis.URL
+	}
+
+	if err := validate(cfg); err != nil {
+		errs = append(errs, err)
+	}
+
+	return cfg, errors.Join(errs...)
+}
+
+// loadFile decodes the YAML file at path over cfg. Keys it does not know are
+// errors, so that typos do not go unnoticed.
+func loadFile(cfg *Config, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// RatePolicies are the rate limit policies by route group.
+type RatePolicies map[string]ratelimit.Policy
+
+// set parses spec over the current policy of group, or over
+// DefaultRatePolicy for a group without one.
+func (p RatePolicies) set(group, spec string) error {
+	base, ok := p[group]
+	if !ok {
+		base = DefaultRatePolicy
+	}
+	parsed, err := ratelimit.ParsePolicy(spec, base)
+	if err != nil {
+		return err
+	}
+	p[group] = parsed
+	return nil
+}
+
+// UnmarshalYAML reads a mapping of group to policy. Groups that are not in
+// it keep their policies.
+func (p *RatePolicies) UnmarshalYAML(node *yaml.Node) error {
+	var specs map[string]string
+	if err := node.Decode(&specs); err != nil {
+		return err
+	}
+	if *p == nil {
+		*p = make(RatePolicies, len(specs))
+	}
+
+	var errs []error
+	for group, spec := range specs {
+		if err := p.set(group, spec); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: policy of %s: %w", node.Line, group, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// field is a setting of the configuration.
+type field struct {
+	// path is the key of the setting in the YAML file, e.g. db.host.
+	path   string
+	env    string
+	secret string
+	value  reflect.Value
+}
+
+var textUnmarshaler = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// fields lists the settings of cfg. Structs are walked into, except those
+// that parse themselves from text.
+func fields(cfg *Config) []field {
+	var out []field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			path := prefix + sf.Tag.Get("yaml")
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Struct && !reflect.PointerTo(sf.Type).Implements(textUnmarshaler) {
+				walk(fv, path+".")
+				continue
+			}
+			out = append(out, field{
+				path:   path,
+				env:    sf.Tag.Get("env"),
+				secret: sf.Tag.Get("secret"),
+				value:  fv,
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return out
+}
+
+// flagName derives the flag of a setting from its env variable: DB_HOST is
+// set by -db-host.
+func flagName(env string) string {
+	return strings.ReplaceAll(strings.ToLower(env), "_", "-")
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// setString parses s into v. Lists are separated by commas and maps are
+// written as key=value pairs separated by commas.
+func setString(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		list := []string{}
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	case reflect.Map:
+		m := make(map[string]string)
+		for _, pair := range strings.Split(s, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			key, val, ok := strings.Cut(pair, "=")
+			if !ok || strings.TrimSpace(key) == "" {
+				return fmt.Errorf("want key=value, got %q", pair)
+			}
+			m[strings.TrimSpace(key)] = strings.TrimSpace(val)
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("unsupported setting of type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"io"
+	"net/url"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets that are set.
+const redacted = "REDACTED"
+
+// Redacted returns a copy of c without its secrets, safe to log or print.
+// Settings tagged secret:"url" only lose the password of the URL.
+func (c *Config) Redacted() *Config {
+	cp := *c
+	for _, f := range fields(&cp) {
+		if f.secret == "" || f.value.IsZero() {
+			continue
+		}
+
+		switch f.value.Kind() {
+		case reflect.String:
+			f.value.SetString(redact(f.secret, f.value.String()))
+		case reflect.Map:
+			// The map is shared with c, so the copy gets a new one.
+			m := reflect.MakeMapWithSize(f.value.Type(), f.value.Len())
+			for iter := f.value.MapRange(); iter.Next(); {
+				m.SetMapIndex(iter.Key(), reflect.ValueOf(redacted))
+			}
+			f.value.Set(m)
+		}
+	}
+	return &cp
+}
+
+func redact(kind, val string) string {
+	if kind == "url" {
+		if u, err := url.Parse(val); err == nil {
+			return u.Redacted()
+		}
+	}
+	return redacted
+}
+
+// Dump writes the configuration without its secrets as YAML, in the form
+// the config file is read.
+func (c *Config) Dump(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// reloadable are the settings that apply without a restart, by YAML key.
+// Keys ending in a dot cover a whole section.
+var reloadable = []string{
+	"log.level",
+	"cors.",
+	"rate_limit.default",
+	"rate_limit.policies",
+	"content.filter_file",
+}
+
+// Reloadable reports whether the setting at path, a YAML key such as
+// log.level, applies to the running app on reload. Everything else takes a
+// restart.
+func Reloadable(path string) bool {
+	for _, key := range reloadable {
+		if path == key || strings.HasSuffix(key, ".") && strings.HasPrefix(path, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Changes lists the YAML keys of the settings that differ in next.
+func (c *Config) Changes(next *Config) []string {
+	var changed []string
+	nextFields := fields(next)
+	for i, f := range fields(c) {
+		if !reflect.DeepEqual(f.value.Interface(), nextFields[i].value.Interface()) {
+			changed = append(changed, f.path)
+		}
+	}
+	return changed
+}
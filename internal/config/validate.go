@@ -0,0 +1,186 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/logging"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/tracing"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/routes"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Validate checks the whole configuration and reports every problem, not
+// only the first. Settings are named by their YAML keys.
+func (c *Config) Validate() error {
+	var v validator
+
+	c.validateDB(&v)
+
+	v.required("server.port", c.Server.Port)
+	v.positive("server.timeout", c.Server.Timeout)
+	v.positive("server.idle_timeout", c.Server.IdleTimeout)
+	v.positive("server.health_timeout", c.Server.HealthTimeout)
+	v.check(c.Server.HealthCacheTTL >= 0, "server.health_cache_ttl must not be negative")
+	v.positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+	v.check(c.Server.ShutdownDelay >= 0 && c.Server.ShutdownDelay < c.Server.ShutdownTimeout,
+		"server.shutdown_delay must be between 0 and server.shutdown_timeout")
+	v.check((c.Server.TLS.CertFile == "") == (c.Server.TLS.KeyFile == ""),
+		"server.tls.cert_file and server.tls.key_file must be set together")
+
+	v.check(len(c.CORS.AllowedOrigins) > 0, "cors.allowed_origins must not be empty")
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && (u.Path == "" || u.Path == "/"),
+			"cors.allowed_origins: %q is not an origin such as https://example.com", origin)
+	}
+
+	v.check(c.Auth.BcryptCost >= bcrypt.MinCost && c.Auth.BcryptCost <= bcrypt.MaxCost,
+		"auth.bcrypt_cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+
+	if c.Redis.URL != "" {
+		v.redisURL("redis.url", c.Redis.URL)
+	}
+
+	v.check(c.Content.RenderCacheSize >= 0, "content.render_cache_size must not be negative")
+	v.positive("content.publish_interval", c.Content.PublishInterval)
+	v.positive("content.filter_reload_interval", c.Content.FilterReloadInterval)
+
+	if c.Notifications.PublicURL != "" {
+		u, err := url.Parse(c.Notifications.PublicURL)
+		v.check(err == nil && u.Scheme != "" && u.Host != "", "notifications.public_url must be an absolute URL")
+	}
+	v.positive("notifications.digest_interval", c.Notifications.DigestInterval)
+	if c.Notifications.SMTP.Host != "" {
+		v.required("notifications.smtp.port", c.Notifications.SMTP.Port)
+		v.required("notifications.smtp.from", c.Notifications.SMTP.From)
+	}
+
+	v.check(c.Moderation.ReportHideThreshold >= 0, "moderation.report_hide_threshold must not be negative")
+
+	switch c.RateLimit.Store {
+	case "memory":
+	case "redis":
+		if c.RateLimit.RedisURL == "" {
+			v.add("rate_limit.redis_url or redis.url is required with the redis store")
+		} else {
+			v.redisURL("rate_limit.redis_url", c.RateLimit.RedisURL)
+		}
+	default:
+		v.add("rate_limit.store must be memory or redis, got %q", c.RateLimit.Store)
+	}
+	v.check(c.RateLimit.TrustedProxies >= 0, "rate_limit.trusted_proxies must not be negative")
+	if err := c.RateLimit.Default.Validate(); err != nil {
+		v.add("rate_limit.default: %v", err)
+	}
+	for group, p := range c.RateLimit.Policies {
+		if !slices.Contains(routes.Groups(), group) {
+			v.add("rate_limit.policies: unknown route group %q, want one of %s", group, strings.Join(routes.Groups(), ", "))
+			continue
+		}
+		if err := p.Validate(); err != nil {
+			v.add("rate_limit.policies.%s: %v", group, err)
+		}
+	}
+
+	v.check(c.Log.Format == logging.FormatText || c.Log.Format == logging.FormatJSON,
+		"log.format must be text or json, got %q", c.Log.Format)
+	switch c.Log.Output {
+	case logging.OutputStdout:
+	case logging.OutputFile:
+		v.required("log.dir", c.Log.Dir)
+	default:
+		v.add("log.output must be file or stdout, got %q", c.Log.Output)
+	}
+	v.check(c.Log.MaxSizeMB >= 0, "log.max_size_mb must not be negative")
+	v.check(c.Log.RotateEvery >= 0, "log.rotate_every must not be negative")
+	v.check(c.Log.MaxAge >= 0, "log.max_age must not be negative")
+	v.check(c.Log.MaxFiles >= 0, "log.max_files must not be negative")
+
+	if c.Metrics.Enabled {
+		v.required("metrics.addr", c.Metrics.Addr)
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterOTLP:
+		u, err := url.Parse(c.Tracing.Endpoint)
+		v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"tracing.endpoint must be an http or https URL")
+	default:
+		v.add("tracing.exporter must be none, stdout or otlp, got %q", c.Tracing.Exporter)
+	}
+	v.check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+
+	v.check(c.Reload.WatchInterval >= 0, "reload.watch_interval must not be negative")
+
+	return v.err()
+}
+
+// ValidateDB checks the db section only, for the commands that need nothing
+// else.
+func (c *Config) ValidateDB() error {
+	var v validator
+	c.validateDB(&v)
+	return v.err()
+}
+
+func (c *Config) validateDB(v *validator) {
+	v.required("db.host", c.DB.Host)
+	v.required("db.port", c.DB.Port)
+	v.required("db.user", c.DB.User)
+	v.required("db.name", c.DB.Name)
+	v.check(c.DB.MaxConns > 0, "db.max_conns must be positive")
+	v.check(c.DB.MinConns >= 0 && c.DB.MinConns <= c.DB.MaxConns, "db.min_conns must be between 0 and db.max_conns")
+	switch c.DB.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		v.add("db.sslmode must be disable, allow, prefer, require, verify-ca or verify-full, got %q", c.DB.SSLMode)
+	}
+	v.check((c.DB.SSLCert == "") == (c.DB.SSLKey == ""), "db.sslcert and db.sslkey must be set together")
+	v.check(c.DB.SSLMode != "disable" || c.DB.SSLRootCert == "" && c.DB.SSLCert == "",
+		"db.sslmode must not be disable with certificates set")
+	v.positive("db.max_conn_lifetime", c.DB.MaxConnLifetime)
+	v.positive("db.max_conn_idle_time", c.DB.MaxConnIdleTime)
+	v.positive("db.health_check_period", c.DB.HealthCheckPeriod)
+	v.check(c.DB.StatementTimeout >= 0, "db.statement_timeout must not be negative")
+}
+
+// validator collects the problems found by Validate.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) add(format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf(format, args...))
+}
+
+func (v *validator) check(ok bool, format string, args ...any) {
+	if !ok {
+		v.add(format, args...)
+	}
+}
+
+func (v *validator) required(name, val string) {
+	v.check(val != "", "%s is required", name)
+}
+
+func (v *validator) positive(name string, d time.Duration) {
+	v.check(d > 0, "%s must be positive", name)
+}
+
+func (v *validator) redisURL(name, val string) {
+	u, err := url.Parse(val)
+	v.check(err == nil && (u.Scheme == "redis" || u.Scheme == "rediss"), "%s must be a redis:// or rediss:// URL", name)
+}
+
+func (v *validator) err() error {
+	return errors.Join(v.errs...)
+}
@@ -0,0 +1,80 @@
+package content
+
+import (
+	"container/list"
+	"sync"
+)
+
+// renderCache is a fixed-size LRU of rendered posts. An entry is only valid for
+// the version of the post it was rendered from.
+type renderCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[int]*list.Element
+}
+
+type cacheEntry struct {
+	postID  int
+	version string
+	html    string
+}
+
+func newRenderCache(size int) *renderCache {
+	return &renderCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[int]*list.Element),
+	}
+}
+
+func (c *renderCache) get(postID int, version string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[postID]
+	if !ok {
+		return "", false
+	}
+
+	entry := el.Value.(*cacheEntry)
+	if entry.version != version {
+		return "", false
+	}
+
+	c.order.MoveToFront(el)
+	return entry.html, true
+}
+
+func (c *renderCache) put(postID int, version, html string) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[postID]; ok {
+		el.Value = &cacheEntry{postID: postID, version: version, html: html}
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[postID] = c.order.PushFront(&cacheEntry{postID: postID, version: version, html: html})
+
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).postID)
+	}
+}
+
+func (c *renderCache) remove(postID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[postID]; ok {
+		c.order.Remove(el)
+		delete(c.entries, postID)
+	}
+}
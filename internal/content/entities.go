@@ -0,0 +1,124 @@
+package content
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+)
+
+const (
+	maxHashtagLength  = 64
+	maxUsernameLength = 255
+)
+
+// ParseEntities finds #hashtags and @mentions in text. A marker only starts an
+// entity at the beginning of the text or after a character that cannot be
+// part of a word, so e-mail addresses and "C#" are not matched.
+func ParseEntities(text string) []models.Entity {
+	entities := []models.Entity{}
+
+	offset := 0 // in UTF-16 code units
+	prev := ' '
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+
+		if (r == '#' || r == '@') && !isWordRune(prev) {
+			body := scanWord(text[i+size:], r == '@')
+			if e, ok := newEntity(r, body, offset); ok {
+				entities = append(entities, e)
+
+				consumed := size + len(body)
+				offset += e.Length
+				prev, _ = utf8.DecodeLastRuneInString(text[:i+consumed])
+				i += consumed
+				continue
+			}
+		}
+
+		offset += utf16.RuneLen(r)
+		prev = r
+		i += size
+	}
+
+	return entities
+}
+
+// Hashtags returns the distinct hashtags of text in order of appearance.
+func Hashtags(text string) []string {
+	return distinctValues(text, models.EntityHashtag)
+}
+
+// Mentions returns the distinct mentioned usernames of text in order of appearance.
+func Mentions(text string) []string {
+	return distinctValues(text, models.EntityMention)
+}
+
+func distinctValues(text, entityType string) []string {
+	seen := map[string]bool{}
+	values := []string{}
+
+	for _, e := range ParseEntities(text) {
+		if e.Type == entityType && !seen[e.Value] {
+			seen[e.Value] = true
+			values = append(values, e.Value)
+		}
+	}
+
+	return values
+}
+
+func newEntity(marker rune, body string, offset int) (models.Entity, bool) {
+	if body == "" {
+		return models.Entity{}, false
+	}
+
+	e := models.Entity{Offset: offset, Length: 1 + utf16Len(body)}
+
+	if marker == '#' {
+		if !strings.ContainsFunc(body, unicode.IsLetter) || utf8.RuneCountInString(body) > maxHashtagLength {
+			return models.Entity{}, false
+		}
+		e.Type, e.Value = models.EntityHashtag, strings.ToLower(body)
+		return e, true
+	}
+
+	if utf8.RuneCountInString(body) > maxUsernameLength {
+		return models.Entity{}, false
+	}
+	e.Type, e.Value = models.EntityMention, body
+	return e, true
+}
+
+// scanWord returns the longest prefix of s made of word runes. Usernames may
+// also contain dots, but not at the end, so "@ivan." mentions "ivan".
+func scanWord(s string, username bool) string {
+	end := 0
+	for i, r := range s {
+		if !isWordRune(r) && !(username && r == '.') {
+			break
+		}
+		end = i + utf8.RuneLen(r)
+	}
+
+	word := s[:end]
+	if username {
+		word = strings.TrimRight(word, ".")
+	}
+	return word
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// utf16Len is the length of s in UTF-16 code units.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
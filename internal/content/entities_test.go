@@ -0,0 +1,67 @@
+package content
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+)
+
+func TestParseEntities(t *testing.T) {
+	hashtag := func(offset, length int, value string) models.Entity {
+		return models.Entity{Type: models.EntityHashtag, Offset: offset, Length: length, Value: value}
+	}
+	mention := func(offset, length int, value string) models.Entity {
+		return models.Entity{Type: models.EntityMention, Offset: offset, Length: length, Value: value}
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want []models.Entity
+	}{
+		{name: "empty", text: "", want: []models.Entity{}},
+		{name: "hashtag and mention", text: "hello #World and @ivan", want: []models.Entity{hashtag(6, 6, "world"), mention(17, 5, "ivan")}},
+		{name: "inside words", text: "mail a@b.com, C# rocks", want: []models.Entity{}},
+		{name: "digits only", text: "#123", want: []models.Entity{}},
+		{name: "bare markers", text: "# @ #!", want: []models.Entity{}},
+		{name: "trailing dot", text: "@ivan.", want: []models.Entity{mention(0, 5, "ivan")}},
+		{name: "dotted username", text: "@ivan.petrov", want: []models.Entity{mention(0, 12, "ivan.petrov")}},
+		{name: "punctuation", text: "#a_b1, #Go!", want: []models.Entity{hashtag(0, 5, "a_b1"), hashtag(7, 3, "go")}},
+		{name: "doubled marker", text: "##tag", want: []models.Entity{hashtag(1, 4, "tag")}},
+		{name: "UTF-16 offsets", text: "😀 #тег", want: []models.Entity{hashtag(3, 4, "тег")}},
+		{name: "hashtag too long", text: "#" + strings.Repeat("a", maxHashtagLength+1), want: []models.Entity{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseEntities(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistinctEntities(t *testing.T) {
+	tests := []struct {
+		text         string
+		wantHashtags []string
+		wantMentions []string
+	}{
+		{text: "", wantHashtags: []string{}, wantMentions: []string{}},
+		{text: "#Go #go @a @a @b", wantHashtags: []string{"go"}, wantMentions: []string{"a", "b"}},
+		{text: "@b #x @a #y", wantHashtags: []string{"x", "y"}, wantMentions: []string{"b", "a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := Hashtags(tt.text); !reflect.DeepEqual(got, tt.wantHashtags) {
+				t.Errorf("Hashtags = %q, want %q", got, tt.wantHashtags)
+			}
+			if got := Mentions(tt.text); !reflect.DeepEqual(got, tt.wantMentions) {
+				t.Errorf("Mentions = %q, want %q", got, tt.wantMentions)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package content
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+const (
+	ImageProxyPath = "/api/images/proxy"
+
+	// MaxProxiedImageSize limits how much of a remote image is streamed to clients.
+	MaxProxiedImageSize = 10 << 20
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid image signature")
+	ErrNotAnImage       = errors.New("remote resource is not an image")
+	ErrForbiddenAddress = errors.New("remote address is not allowed")
+)
+
+// ImageProxy signs the URLs of images embedded in posts and fetches them on
+// behalf of clients, so readers never connect to third-party hosts and the
+// proxy cannot be used for arbitrary URLs.
+type ImageProxy struct {
+	key    []byte
+	client *http.Client
+}
+
+// NewImageProxy creates a proxy signing with key. An empty key is replaced by
+// a random one, which only keeps signatures valid until the process restarts.
+func NewImageProxy(key string) *ImageProxy {
+	secret := []byte(key)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+
+	return &ImageProxy{
+		key:    secret,
+		client: PublicHTTPClient(15 * time.Second),
+	}
+}
+
+// PublicHTTPClient returns a client for user-supplied URLs. It only connects
+// to public addresses, so it cannot be pointed at internal services.
+func PublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, Proxy: nil},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return errors.New("too many redirects")
+			}
+			return nil
+		},
+	}
+}
+
+func (p *ImageProxy) sign(src string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(src))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// URL returns the proxied address of the image at src.
+func (p *ImageProxy) URL(src string) string {
+	return ImageProxyPath + "?" + url.Values{"url": {src}, "sig": {p.sign(src)}}.Encode()
+}
+
+func (p *ImageProxy) Verify(src, sig string) bool {
+	return hmac.Equal([]byte(p.sign(src)), []byte(sig))
+}
+
+// Fetch requests a signed image. The caller must close the response body.
+func (p *ImageProxy) Fetch(ctx context.Context, src, sig string) (*http.Response, error) {
+	if !p.Verify(src, sig) {
+		return nil, ErrInvalidSignature
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image url: %v", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch image: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || !isRasterImage(resp.Header.Get("Content-Type")) {
+		resp.Body.Close()
+		return nil, ErrNotAnImage
+	}
+
+	return resp, nil
+}
+
+// isRasterImage rejects SVG, which can carry scripts.
+func isRasterImage(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch mediaType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp", "image/avif":
+		return true
+	default:
+		return false
+	}
+}
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, internal to
+// the provider network like the private ranges.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip))
+}
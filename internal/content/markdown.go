@@ -0,0 +1,101 @@
+package content
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Renderer turns post content into sanitized HTML. Markdown is rendered with
+// GitHub-flavoured extensions, raw HTML in the source is dropped, and the
+// result goes through an allowlist policy: links get rel="nofollow" and images
+// are loaded through the image proxy.
+type Renderer struct {
+	md     goldmark.Markdown
+	policy *bluemonday.Policy
+	cache  *renderCache
+}
+
+func NewRenderer(proxy *ImageProxy, cacheSize int) *Renderer {
+	policy := bluemonday.UGCPolicy()
+	policy.AllowURLSchemes("http", "https", "mailto")
+	policy.RequireNoFollowOnLinks(true)
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+	policy.RewriteSrc(func(u *url.URL) {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return
+		}
+		proxied, err := url.Parse(proxy.URL(u.String()))
+		if err == nil {
+			*u = *proxied
+		}
+	})
+
+	return &Renderer{
+		md:     goldmark.New(goldmark.WithExtensions(extension.GFM)),
+		policy: policy,
+		cache:  newRenderCache(cacheSize),
+	}
+}
+
+// Render returns the sanitized HTML of source in the given format.
+func (r *Renderer) Render(format, source string) string {
+	if format != models.FormatMarkdown {
+		return renderPlain(source)
+	}
+
+	var buf bytes.Buffer
+	if err := r.md.Convert([]byte(source), &buf); err != nil {
+		return renderPlain(source)
+	}
+
+	return r.policy.Sanitize(buf.String())
+}
+
+// RenderPost sets post.ContentHTML, reusing the cached rendering as long as
+// the content and format of the post are the ones it was rendered from. Other
+// replicas edit posts without invalidating this cache, so the key is a hash
+// of what is rendered rather than the time of the last update.
+func (r *Renderer) RenderPost(post *models.Post) {
+	version := contentVersion(post.Format, post.Content)
+	if cached, ok := r.cache.get(post.ID, version); ok {
+		post.ContentHTML = cached
+		return
+	}
+
+	post.ContentHTML = r.Render(post.Format, post.Content)
+	r.cache.put(post.ID, version, post.ContentHTML)
+}
+
+func contentVersion(format, source string) string {
+	sum := sha256.Sum256([]byte(format + "\x00" + source))
+	return string(sum[:])
+}
+
+// Invalidate drops the cached rendering of a post after it was edited or deleted.
+func (r *Renderer) Invalidate(postID int) {
+	r.cache.remove(postID)
+}
+
+// renderPlain escapes plain text and keeps its paragraphs and line breaks.
+func renderPlain(source string) string {
+	var b strings.Builder
+
+	for _, para := range strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n\n") {
+		if strings.TrimSpace(para) == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(para), "\n", "<br>"))
+		b.WriteString("</p>\n")
+	}
+
+	return b.String()
+}
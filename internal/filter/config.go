@@ -0,0 +1,127 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config is the JSON file the pipeline is built from. Zero limits turn a
+// filter off. Example:
+//
+//	{
+//	  "words": {"reject": ["спам"], "flag": ["дурак"]},
+//	  "links": {"flag": 3, "reject": 10},
+//	  "duplicate": {"window": "10m", "flag": 1, "reject": 3},
+//	  "flood": {"window": "1m", "max": 10}
+//	}
+type Config struct {
+	Words struct {
+		Reject []string `json:"reject"`
+		Flag   []string `json:"flag"`
+	} `json:"words"`
+	Links struct {
+		Flag   int `json:"flag"`
+		Reject int `json:"reject"`
+	} `json:"links"`
+	Duplicate struct {
+		Window Duration `json:"window"`
+		Flag   int      `json:"flag"`
+		Reject int      `json:"reject"`
+	} `json:"duplicate"`
+	Flood struct {
+		Window Duration `json:"window"`
+		Max    int      `json:"max"`
+	} `json:"flood"`
+}
+
+// Duration is a time.Duration written as "10m" in the config file.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// DefaultConfig is used when no config file is set: no word list and
+// conservative spam limits.
+func DefaultConfig() Config {
+	var cfg Config
+	cfg.Links.Flag = 3
+	cfg.Links.Reject = 10
+	cfg.Duplicate.Window = Duration(10 * time.Minute)
+	cfg.Duplicate.Flag = 1
+	cfg.Duplicate.Reject = 3
+	cfg.Flood.Window = Duration(time.Minute)
+	cfg.Flood.Max = 10
+	return cfg
+}
+
+// LoadConfig reads and validates the config file at path. Unknown fields are
+// errors, so a typo does not silently turn a filter off.
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read filter config: %v", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("invalid filter config %s: %v", path, err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return cfg, fmt.Errorf("invalid filter config %s: %v", path, err)
+	}
+
+	return cfg, nil
+}
+
+func (c Config) validate() error {
+	var errs []error
+	if c.Links.Flag < 0 || c.Links.Reject < 0 {
+		errs = append(errs, errors.New("links limits must not be negative"))
+	}
+	if c.Duplicate.Flag < 0 || c.Duplicate.Reject < 0 {
+		errs = append(errs, errors.New("duplicate limits must not be negative"))
+	}
+	if (c.Duplicate.Flag > 0 || c.Duplicate.Reject > 0) && c.Duplicate.Window <= 0 {
+		errs = append(errs, errors.New("duplicate window must be positive"))
+	}
+	if c.Flood.Max < 0 {
+		errs = append(errs, errors.New("flood max must not be negative"))
+	}
+	if c.Flood.Max > 0 && c.Flood.Window <= 0 {
+		errs = append(errs, errors.New("flood window must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+func (c Config) filters() []Filter {
+	return []Filter{
+		NewFlood(time.Duration(c.Flood.Window), c.Flood.Max),
+		NewWordList(c.Words.Reject, c.Words.Flag),
+		NewLinks(c.Links.Flag, c.Links.Reject),
+		NewDuplicate(time.Duration(c.Duplicate.Window), c.Duplicate.Flag, c.Duplicate.Reject),
+	}
+}
+
+// retention is how long the activity history has to be kept for the
+// duplicate and flood windows.
+func (c Config) retention() time.Duration {
+	return max(time.Duration(c.Duplicate.Window), time.Duration(c.Flood.Window))
+}
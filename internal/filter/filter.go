@@ -0,0 +1,282 @@
+// Package filter checks user content for spam and banned words before it is
+// stored. A Pipeline runs the built-in filters in order and is rebuilt from
+// its config file when the file changes.
+package filter
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Verdict is the outcome of a filter, ordered from the mildest to the strictest.
+type Verdict int
+
+const (
+	Allow Verdict = iota
+	// Flag lets the content be stored but hides it until a moderator
+	// reviews it.
+	Flag
+	Reject
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Flag:
+		return "flag"
+	case Reject:
+		return "reject"
+	default:
+		return "allow"
+	}
+}
+
+// Kinds of content the pipeline checks. Each kind has its own activity history.
+const (
+	KindPost    = "post"
+	KindComment = "comment"
+)
+
+// Result is the verdict of a filter and why it was given. Filter and Reason
+// are empty for Allow.
+type Result struct {
+	Verdict Verdict
+	Filter  string
+	Reason  string
+}
+
+// Input is the content being checked. The pipeline fills in Hash and Recent,
+// the earlier submissions of the same user and kind, newest last.
+type Input struct {
+	UserID string
+	Kind   string
+	Text   string
+
+	At     time.Time
+	Hash   uint64
+	Recent []Submission
+}
+
+// Submission is an earlier piece of content of a user, remembered by the hash
+// of its normalized text.
+type Submission struct {
+	At   time.Time
+	Hash uint64
+}
+
+// Filter is a single check of the pipeline.
+type Filter interface {
+	Name() string
+	Check(ctx context.Context, in Input) Result
+}
+
+// Checker is what the service runs content through.
+type Checker interface {
+	Check(ctx context.Context, in Input) Result
+}
+
+// Pipeline runs its filters in order and returns the strictest verdict. The
+// first Reject stops the pipeline.
+type Pipeline struct {
+	log *slog.Logger
+
+	filters  atomic.Pointer[[]Filter]
+	activity *activity
+
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+}
+
+// NewPipeline builds a pipeline from the config file at path, or from
+// DefaultConfig when path is empty.
+func NewPipeline(path string, log *slog.Logger) (*Pipeline, error) {
+	p := &Pipeline{path: path, log: log, activity: newActivity()}
+	if path == "" {
+		p.apply(DefaultConfig())
+		return p, nil
+	}
+
+	if _, err := p.ReloadIfChanged(context.Background()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Pipeline) apply(cfg Config) {
+	filters := cfg.filters()
+	p.filters.Store(&filters)
+	p.activity.setRetention(cfg.retention())
+}
+
+// ReloadIfChanged rebuilds the filters when the config file was modified
+// since the last load and reports 1 if it did. A broken file keeps the current
+// filters in place. It has the shape of a background job task.
+func (p *Pipeline) ReloadIfChanged(ctx context.Context) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.path == "" {
+		return 0, nil
+	}
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat filter config: %v", err)
+	}
+	if info.ModTime().Equal(p.modTime) {
+		return 0, nil
+	}
+
+	cfg, err := LoadConfig(p.path)
+	if err != nil {
+		return 0, err
+	}
+	p.apply(cfg)
+	p.modTime = info.ModTime()
+
+	p.log.InfoContext(ctx, "content filter config loaded", "path", p.path)
+
+	return 1, nil
+}
+
+// Reload switches the pipeline to the config file at path, or to
+// DefaultConfig when path is empty, even if the file did not change. A broken
+// file keeps the current filters and path.
+func (p *Pipeline) Reload(ctx context.Context, path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cfg := DefaultConfig()
+	var modTime time.Time
+	if path != "" {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to stat filter config: %v", err)
+		}
+		if cfg, err = LoadConfig(path); err != nil {
+			return err
+		}
+		modTime = info.ModTime()
+	}
+	p.apply(cfg)
+	p.path, p.modTime = path, modTime
+
+	p.log.InfoContext(ctx, "content filter config loaded", "path", path)
+
+	return nil
+}
+
+func (p *Pipeline) Check(ctx context.Context, in Input) Result {
+	if in.At.IsZero() {
+		in.At = time.Now()
+	}
+	in.Hash = textHash(in.Text)
+	key := in.Kind + ":" + in.UserID
+	in.Recent = p.activity.recent(key, in.At)
+
+	var res Result
+	for _, f := range *p.filters.Load() {
+		r := f.Check(ctx, in)
+		if r.Verdict > res.Verdict {
+			r.Filter = f.Name()
+			res = r
+		}
+		if res.Verdict == Reject {
+			break
+		}
+	}
+
+	// Rejected content is never stored, so it does not count towards the
+	// duplicate and flood limits of the next submissions.
+	if res.Verdict != Reject {
+		p.activity.add(key, Submission{At: in.At, Hash: in.Hash})
+	}
+
+	if res.Verdict != Allow {
+		p.log.InfoContext(ctx, "content filtered", "kind", in.Kind, "userID", in.UserID,
+			"verdict", res.Verdict, "filter", res.Filter, "reason", res.Reason)
+	}
+
+	return res
+}
+
+// textHash identifies a text regardless of case, spacing and punctuation.
+func textHash(text string) uint64 {
+	h := fnv.New64a()
+	for _, word := range words(text) {
+		h.Write([]byte(word))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// activity remembers recent submissions per user for the duplicate and flood
+// filters. It survives config reloads.
+type activity struct {
+	mu        sync.Mutex
+	retention time.Duration
+	entries   map[string][]Submission
+	lastSweep time.Time
+}
+
+func newActivity() *activity {
+	return &activity{entries: make(map[string][]Submission)}
+}
+
+func (a *activity) setRetention(d time.Duration) {
+	a.mu.Lock()
+	a.retention = d
+	a.mu.Unlock()
+}
+
+// recent returns the submissions recorded under key that are still within
+// the retention at the time at.
+func (a *activity) recent(key string, at time.Time) []Submission {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.retention <= 0 {
+		return nil
+	}
+
+	subs := a.entries[key]
+	return append([]Submission(nil), subs[expired(subs, at.Add(-a.retention)):]...)
+}
+
+// add records sub under key, dropping what fell out of the retention.
+func (a *activity) add(key string, sub Submission) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.retention <= 0 {
+		return
+	}
+
+	cutoff := sub.At.Add(-a.retention)
+	if sub.At.Sub(a.lastSweep) > a.retention {
+		for k, subs := range a.entries {
+			if len(subs) == 0 || subs[len(subs)-1].At.Before(cutoff) {
+				delete(a.entries, k)
+			}
+		}
+		a.lastSweep = sub.At
+	}
+
+	subs := a.entries[key]
+	a.entries[key] = append(subs[expired(subs, cutoff):], sub)
+}
+
+// expired counts the submissions of subs made before cutoff.
+func expired(subs []Submission, cutoff time.Time) int {
+	i := 0
+	for i < len(subs) && subs[i].At.Before(cutoff) {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,213 @@
+package filter
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "punctuation and case", text: "Привет, МИР!", want: []string{"привет", "мир"}},
+		{name: "yo folded", text: "ёлка", want: []string{"елка"}},
+		{name: "repeated letters", text: "дурааааак", want: []string{"дурак"}},
+		{name: "latin lookalikes in cyrillic word", text: "дyрaк", want: []string{"дурак"}},
+		{name: "digit lookalikes", text: "3апрет", want: []string{"запрет"}},
+		{name: "latin word kept", text: "hello", want: []string{"helo"}},
+		{name: "empty", text: " ... ", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := words(tt.text)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("words(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{word: "дурак", want: "дурак"},
+		{word: "дурака", want: "дурак"},
+		{word: "дураками", want: "дурак"},
+		{word: "спамить", want: "спам"},
+		{word: "дом", want: "дом"},
+		{word: "дома", want: "дом"},
+		{word: "спам", want: "спам"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			if got := stem(tt.word); got != tt.want {
+				t.Errorf("stem(%q) = %q, want %q", tt.word, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWordList(t *testing.T) {
+	f := NewWordList([]string{"спам"}, []string{"дурак"})
+
+	tests := []struct {
+		name string
+		text string
+		want Verdict
+	}{
+		{name: "clean", text: "хорошая погода", want: Allow},
+		{name: "flagged word", text: "какой дурак", want: Flag},
+		{name: "flagged inflected", text: "с дураками", want: Flag},
+		{name: "flagged disguised", text: "ДуРаААк", want: Flag},
+		{name: "banned word", text: "купите спам", want: Reject},
+		{name: "banned inflected", text: "хватит спамить", want: Reject},
+		{name: "banned beats flagged", text: "дурак прислал спам", want: Reject},
+		{name: "longer word with listed prefix", text: "спамер", want: Allow},
+		{name: "diminutive", text: "дурачок", want: Allow},
+		{name: "split letters", text: "д у р а к", want: Allow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.Check(context.Background(), Input{Text: tt.text}); got.Verdict != tt.want {
+				t.Errorf("Check(%q) = %v, want %v", tt.text, got.Verdict, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinks(t *testing.T) {
+	tests := []struct {
+		name   string
+		flag   int
+		reject int
+		text   string
+		want   Verdict
+	}{
+		{name: "no links", flag: 2, reject: 4, text: "просто текст", want: Allow},
+		{name: "below flag", flag: 2, reject: 4, text: "see https://example.com", want: Allow},
+		{name: "flag", flag: 2, reject: 4, text: "https://a.com and www.b.org", want: Flag},
+		{name: "reject", flag: 2, reject: 4, text: "http://a.com www.b.org https://c.net WWW.d.io", want: Reject},
+		{name: "off", text: "http://a.com www.b.org https://c.net www.d.io", want: Allow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewLinks(tt.flag, tt.reject)
+			if got := f.Check(context.Background(), Input{Text: tt.text}); got.Verdict != tt.want {
+				t.Errorf("Check(%q) = %v, want %v", tt.text, got.Verdict, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	same := func(ago ...time.Duration) []Submission {
+		var subs []Submission
+		for _, d := range ago {
+			subs = append(subs, Submission{At: now.Add(-d), Hash: 1})
+		}
+		return subs
+	}
+
+	tests := []struct {
+		name   string
+		recent []Submission
+		want   Verdict
+	}{
+		{name: "first", want: Allow},
+		{name: "repeated once", recent: same(time.Minute), want: Flag},
+		{name: "repeated twice", recent: same(2*time.Minute, time.Minute), want: Flag},
+		{name: "repeated three times", recent: same(3*time.Minute, 2*time.Minute, time.Minute), want: Reject},
+		{name: "outside window", recent: same(time.Hour), want: Allow},
+		{name: "different content", recent: []Submission{{At: now, Hash: 2}}, want: Allow},
+	}
+
+	f := NewDuplicate(10*time.Minute, 1, 3)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := Input{At: now, Hash: 1, Recent: tt.recent}
+			if got := f.Check(context.Background(), in); got.Verdict != tt.want {
+				t.Errorf("Check = %v, want %v", got.Verdict, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlood(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(ago ...time.Duration) []Submission {
+		var subs []Submission
+		for i, d := range ago {
+			subs = append(subs, Submission{At: now.Add(-d), Hash: uint64(i)})
+		}
+		return subs
+	}
+
+	tests := []struct {
+		name   string
+		max    int
+		recent []Submission
+		want   Verdict
+	}{
+		{name: "below max", max: 3, recent: at(20*time.Second, 10*time.Second), want: Allow},
+		{name: "at max", max: 3, recent: at(30*time.Second, 20*time.Second, 10*time.Second), want: Reject},
+		{name: "old submissions ignored", max: 3, recent: at(2*time.Minute, 20*time.Second, 10*time.Second), want: Allow},
+		{name: "off", recent: at(30*time.Second, 20*time.Second, 10*time.Second), want: Allow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFlood(time.Minute, tt.max)
+			in := Input{At: now, Recent: tt.recent}
+			if got := f.Check(context.Background(), in); got.Verdict != tt.want {
+				t.Errorf("Check = %v, want %v", got.Verdict, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineCheck(t *testing.T) {
+	p, err := NewPipeline("", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewPipeline: %v", err)
+	}
+
+	// The default config flags the first repeat and rejects the third.
+	// Rejected content is not remembered, so the fifth is rejected as well.
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	texts := []string{"Привет всем", "привет, ВСЕМ!", "привет всем", "Привет всем", "привет всем"}
+	want := []Verdict{Allow, Flag, Flag, Reject, Reject}
+
+	for i, text := range texts {
+		in := Input{UserID: "alice", Kind: KindPost, Text: text, At: start.Add(time.Duration(i) * time.Second)}
+		got := p.Check(context.Background(), in)
+		if got.Verdict != want[i] {
+			t.Errorf("submission %d: verdict %v, want %v", i+1, got.Verdict, want[i])
+		}
+		if got.Verdict != Allow && got.Filter != "duplicate" {
+			t.Errorf("submission %d: filter %q, want duplicate", i+1, got.Filter)
+		}
+	}
+
+	// Other users and kinds have their own history.
+	for _, in := range []Input{
+		{UserID: "bob", Kind: KindPost, Text: "привет всем", At: start},
+		{UserID: "alice", Kind: KindComment, Text: "привет всем", At: start},
+	} {
+		if got := p.Check(context.Background(), in); got.Verdict != Allow {
+			t.Errorf("%s %s: verdict %v, want allow", in.UserID, in.Kind, got.Verdict)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// Links flags or rejects content with too many links. A zero limit is off.
+type Links struct {
+	flag   int
+	reject int
+}
+
+func NewLinks(flag, reject int) *Links {
+	return &Links{flag: flag, reject: reject}
+}
+
+func (f *Links) Name() string {
+	return "links"
+}
+
+func (f *Links) Check(_ context.Context, in Input) Result {
+	n := len(linkPattern.FindAllStringIndex(in.Text, -1))
+	switch {
+	case f.reject > 0 && n >= f.reject:
+		return Result{Verdict: Reject, Reason: fmt.Sprintf("too many links (%d)", n)}
+	case f.flag > 0 && n >= f.flag:
+		return Result{Verdict: Flag, Reason: fmt.Sprintf("many links (%d)", n)}
+	}
+	return Result{}
+}
+
+// Duplicate flags or rejects content the user already submitted flag or
+// reject times within the window. A zero limit is off.
+type Duplicate struct {
+	window time.Duration
+	flag   int
+	reject int
+}
+
+func NewDuplicate(window time.Duration, flag, reject int) *Duplicate {
+	return &Duplicate{window: window, flag: flag, reject: reject}
+}
+
+func (f *Duplicate) Name() string {
+	return "duplicate"
+}
+
+func (f *Duplicate) Check(_ context.Context, in Input) Result {
+	n := 0
+	for _, sub := range in.Recent {
+		if sub.Hash == in.Hash && in.At.Sub(sub.At) <= f.window {
+			n++
+		}
+	}
+
+	switch {
+	case f.reject > 0 && n >= f.reject:
+		return Result{Verdict: Reject, Reason: "the same content was already sent several times"}
+	case f.flag > 0 && n >= f.flag:
+		return Result{Verdict: Flag, Reason: "repeated content"}
+	}
+	return Result{}
+}
+
+// Flood rejects content from users who submitted max or more items within
+// the window.
+type Flood struct {
+	window time.Duration
+	max    int
+}
+
+func NewFlood(window time.Duration, max int) *Flood {
+	return &Flood{window: window, max: max}
+}
+
+func (f *Flood) Name() string {
+	return "flood"
+}
+
+func (f *Flood) Check(_ context.Context, in Input) Result {
+	if f.max <= 0 {
+		return Result{}
+	}
+
+	n := 0
+	for _, sub := range in.Recent {
+		if in.At.Sub(sub.At) <= f.window {
+			n++
+		}
+	}
+	if n >= f.max {
+		return Result{Verdict: Reject, Reason: fmt.Sprintf("more than %d submissions in %s", f.max, f.window)}
+	}
+	return Result{}
+}
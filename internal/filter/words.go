@@ -0,0 +1,124 @@
+package filter
+
+import (
+	"context"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// endings are the Russian inflection endings a listed word may take, so
+// listing one form of a word catches the others.
+var endings = []string{
+	"ами", "ями", "ого", "его", "ому", "ему", "ыми", "ими", "ать", "ять", "ить", "еть", "ешь", "ете", "ите", "ишь",
+	"ом", "ем", "ой", "ей", "ою", "ею", "ам", "ям", "ах", "ях", "ов", "ев", "ий", "ый", "ая", "яя", "ое", "ее",
+	"ые", "ие", "ым", "им", "ых", "их", "ую", "юю", "ет", "ут", "ют", "ит", "ат", "ят", "ал", "ил", "ла", "ли", "ло",
+	"а", "я", "о", "е", "и", "ы", "у", "ю", "ь", "й",
+}
+
+var endingSet = func() map[string]bool {
+	set := map[string]bool{"": true}
+	for _, e := range endings {
+		set[e] = true
+	}
+	return set
+}()
+
+// minStem keeps short words from being reduced to a stem that matches
+// everything.
+const minStem = 3
+
+// stem strips the longest inflection ending off a normalized word.
+func stem(word string) string {
+	for _, e := range endings {
+		if s, ok := strings.CutSuffix(word, e); ok && utf8.RuneCountInString(s) >= minStem {
+			return s
+		}
+	}
+	return word
+}
+
+// lookalikes are the Latin letters and digits used to disguise Cyrillic ones.
+var lookalikes = strings.NewReplacer(
+	"a", "а", "b", "в", "c", "с", "e", "е", "h", "н", "k", "к", "m", "м", "o", "о",
+	"p", "р", "t", "т", "x", "х", "y", "у", "0", "о", "3", "з", "4", "ч", "6", "б",
+)
+
+// words splits text into normalized words: lower case, ё folded into е,
+// repeated letters collapsed and, in words with Cyrillic letters, look-alike
+// Latin letters and digits replaced by Cyrillic ones.
+func words(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for i, w := range fields {
+		w = strings.ReplaceAll(w, "ё", "е")
+		if strings.ContainsFunc(w, func(r rune) bool { return unicode.Is(unicode.Cyrillic, r) }) {
+			w = lookalikes.Replace(w)
+		}
+		fields[i] = collapse(w)
+	}
+
+	return fields
+}
+
+// collapse squeezes runs of the same letter, so "дураааак" reads as "дурак".
+func collapse(word string) string {
+	var b strings.Builder
+	var prev rune
+	for _, r := range word {
+		if r != prev {
+			b.WriteRune(r)
+		}
+		prev = r
+	}
+	return b.String()
+}
+
+// WordList flags or rejects content containing listed words in any of their
+// inflected forms.
+type WordList struct {
+	reject []string
+	flag   []string
+}
+
+func NewWordList(reject, flag []string) *WordList {
+	return &WordList{reject: stems(reject), flag: stems(flag)}
+}
+
+func stems(list []string) []string {
+	var out []string
+	for _, entry := range list {
+		for _, w := range words(entry) {
+			out = append(out, stem(w))
+		}
+	}
+	return out
+}
+
+func (f *WordList) Name() string {
+	return "words"
+}
+
+func (f *WordList) Check(_ context.Context, in Input) Result {
+	res := Result{}
+	for _, w := range words(in.Text) {
+		if matchStem(w, f.reject) {
+			return Result{Verdict: Reject, Reason: "contains a banned word"}
+		}
+		if res.Verdict == Allow && matchStem(w, f.flag) {
+			res = Result{Verdict: Flag, Reason: "contains a flagged word"}
+		}
+	}
+	return res
+}
+
+func matchStem(word string, stems []string) bool {
+	for _, s := range stems {
+		if rest, ok := strings.CutPrefix(word, s); ok && endingSet[rest] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/service"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+	"github.com/gorilla/mux"
+)
+
+// defaultCommentDepth is how many reply levels are returned when ?depth= is not set.
+const defaultCommentDepth = 3
+
+func (h *Handlers) CreateComment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	postID, ok := h.postID(w, r)
+	if !ok {
+		return
+	}
+
+	var comment models.Comment
+	if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+		h.response(w, SendError("Invalid input data"), http.StatusBadRequest)
+		return
+	}
+	comment.PostID = postID
+
+	if comment.Content == "" {
+		h.response(w, SendError("Content is required"), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.CreateComment(r.Context(), &comment, userID); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't create comment: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess(comment), http.StatusCreated)
+}
+
+func (h *Handlers) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	postID, ok := h.postID(w, r)
+	if !ok {
+		return
+	}
+
+	commentID, ok := h.commentID(w, r)
+	if !ok {
+		return
+	}
+
+	var comment models.Comment
+	if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+		h.response(w, SendError("Invalid input data"), http.StatusBadRequest)
+		return
+	}
+	comment.ID, comment.PostID = commentID, postID
+
+	if comment.Content == "" {
+		h.response(w, SendError("Content is required"), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.UpdateComment(r.Context(), &comment, userID); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't update comment: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess(comment), http.StatusOK)
+}
+
+func (h *Handlers) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	postID, ok := h.postID(w, r)
+	if !ok {
+		return
+	}
+
+	commentID, ok := h.commentID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.DeleteComment(r.Context(), postID, commentID, userID); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't delete comment: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess("comment deleted"), http.StatusOK)
+}
+
+// GetComments returns a page of threads. ?view=flat lists comments in display
+// order with their depth, the default ?view=tree nests replies under parents.
+// ?depth= limits how many reply levels are returned below each root. A root
+// with more replies than are returned has more_replies set.
+func (h *Handlers) GetComments(w http.ResponseWriter, r *http.Request) {
+	postID, ok := h.postID(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := pagination.FromRequest(r, storage.CommentSorts)
+	if err != nil {
+		h.response(w, SendError(err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	query := storage.CommentQuery{Page: page, ViewerID: h.viewer(r), Depth: defaultCommentDepth}
+	if val := r.URL.Query().Get("depth"); val != "" {
+		depth, err := strconv.Atoi(val)
+		if err != nil || depth < 0 || depth > models.MaxCommentDepth {
+			h.response(w, SendError(fmt.Sprintf("depth must be between 0 and %d", models.MaxCommentDepth)), http.StatusBadRequest)
+			return
+		}
+		query.Depth = depth
+	}
+
+	comments, next, err := h.Service.GetPostComments(r.Context(), postID, query)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error retrieving comments: %v", err)), errorStatus(err))
+		return
+	}
+
+	switch r.URL.Query().Get("view") {
+	case "flat":
+		h.response(w, SendPage(comments, next), http.StatusOK)
+	case "", "tree":
+		h.response(w, SendPage(service.BuildCommentTree(comments), next), http.StatusOK)
+	default:
+		h.response(w, SendError("view must be tree or flat"), http.StatusBadRequest)
+	}
+}
+
+// commentID reads the {commentID} path variable.
+func (h *Handlers) commentID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	commentID, err := strconv.Atoi(mux.Vars(r)["commentID"])
+	if err != nil {
+		h.response(w, SendError("Invalid comment ID"), http.StatusBadRequest)
+		return 0, false
+	}
+
+	return commentID, true
+}
@@ -2,21 +2,37 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/logging"
 	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/notifications"
 	"github.com/Fyefhqdishka/deadlock_v.2/internal/service"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
 	"github.com/gorilla/mux"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type Handlers struct {
-	Service service.ServiceIface
+	Service       service.ServiceIface
+	Notifications notifications.Inbox
+	Conns         ConnMetrics
 }
 
-func NewHandlers(service service.ServiceIface) Handlers {
+// ConnMetrics counts the open long-lived connections.
+type ConnMetrics interface {
+	ConnOpened(kind string)
+	ConnClosed(kind string)
+}
+
+func NewHandlers(service service.ServiceIface, notifications notifications.Inbox, conns ConnMetrics) Handlers {
 	return Handlers{
-		Service: service,
+		Service:       service,
+		Notifications: notifications,
+		Conns:         conns,
 	}
 }
 
@@ -24,7 +40,6 @@ func (h *Handlers) Create(w http.ResponseWriter, r *http.Request) {
 	var user models.User
 
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		fmt.Printf("Error decoding JSON: %v\n", err)
 		h.response(w, SendError(fmt.Sprintf("Can't decode json body: %v", err)), http.StatusBadRequest)
 		return
 	}
@@ -61,32 +76,30 @@ func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handlers) GetUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.Service.GetAllUsers()
+	page, err := pagination.FromRequest(r, storage.UserSorts)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error retrieving users: %v", err), http.StatusInternalServerError)
+		h.response(w, SendError(err.Error()), http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	query := storage.UserQuery{Page: page, ViewerID: h.viewer(r), Gender: r.URL.Query().Get("gender")}
+	if query.RegisteredAfter, err = parseTimeParam(r, "registered_after"); err != nil {
+		h.response(w, SendError(err.Error()), http.StatusBadRequest)
+		return
+	}
 
-	err = json.NewEncoder(w).Encode(users)
+	users, next, err := h.Service.GetAllUsers(r.Context(), query)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error encoding users to JSON: %v", err), http.StatusInternalServerError)
+		h.response(w, SendError(fmt.Sprintf("Error retrieving users: %v", err)), http.StatusInternalServerError)
 		return
 	}
+
+	h.response(w, SendPage(users, next), http.StatusOK)
 }
 
 func (h *Handlers) CreateDialog(w http.ResponseWriter, r *http.Request) {
-	sessionID := r.Header.Get("Authorization")
-	if sessionID == "" {
-		fmt.Println("Session ID is missing in the headers")
-		h.response(w, SendError("Missing session ID in request headers"), http.StatusBadRequest)
-		return
-	}
-
-	userID, err := h.Service.GetUserByID(r.Context(), sessionID)
-	if err != nil {
-		h.response(w, SendError(fmt.Sprintf("Error retrieving user for session: %v", err)), http.StatusUnauthorized)
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -96,14 +109,13 @@ func (h *Handlers) CreateDialog(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&dialog); err != nil {
-		fmt.Println("Error decoding JSON:", err)
 		h.response(w, SendError("Can't decode json body"), http.StatusBadRequest)
 		return
 	}
 
 	dialogID, err := h.Service.CreateDialog(r.Context(), userID, dialog.UserIDTwo)
 	if err != nil {
-		h.response(w, SendError("Can't create dialog"), http.StatusInternalServerError)
+		h.response(w, SendError("Can't create dialog"), errorStatus(err))
 		return
 	}
 
@@ -111,41 +123,33 @@ func (h *Handlers) CreateDialog(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handlers) GetDialogs(w http.ResponseWriter, r *http.Request) {
-	sessionID := r.Header.Get("Authorization")
-	if sessionID == "" {
-		fmt.Println("Session ID is missing in the headers")
-		h.response(w, SendError("Missing session ID in request headers"), http.StatusBadRequest)
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
 		return
 	}
 
-	userID, err := h.Service.GetUserByID(r.Context(), sessionID)
+	page, err := pagination.FromRequest(r, storage.DialogSorts)
 	if err != nil {
-		h.response(w, SendError(fmt.Sprintf("Error retrieving user for session: %v", err)), http.StatusUnauthorized)
+		h.response(w, SendError(err.Error()), http.StatusBadRequest)
 		return
 	}
 
-	dialogs, err := h.Service.GetUserDialogs(r.Context(), userID)
+	query := storage.DialogQuery{Page: page, With: r.URL.Query().Get("with")}
+
+	dialogs, next, err := h.Service.GetUserDialogs(r.Context(), userID, query)
 	if err != nil {
 		http.Error(w, "Failed to fetch dialogs", http.StatusInternalServerError)
 		return
 	}
 
-	h.response(w, SendSuccess(dialogs), http.StatusOK)
+	h.response(w, SendPage(dialogs, next), http.StatusOK)
 }
 
 func (h *Handlers) CreatePost(w http.ResponseWriter, r *http.Request) {
 	var post models.Post
 
-	sessionID := r.Header.Get("Authorization")
-	if sessionID == "" {
-		fmt.Println("Session ID is missing in the headers")
-		h.response(w, SendError("Missing session ID in request headers"), http.StatusBadRequest)
-		return
-	}
-
-	userID, err := h.Service.GetUserByID(r.Context(), sessionID)
-	if err != nil {
-		h.response(w, SendError(fmt.Sprintf("Error retrieving user for session: %v", err)), http.StatusUnauthorized)
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -154,54 +158,154 @@ func (h *Handlers) CreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.Service.CreatePost(r.Context(), &post, userID)
+	err := h.Service.CreatePost(r.Context(), &post, userID)
 	if err != nil {
-		http.Error(w, "Failed to fetch dialogs", http.StatusInternalServerError)
-		h.response(w, SendError(fmt.Sprintf("Failed to fetch dialogs, err: %v", err)), http.StatusUnauthorized)
+		h.response(w, SendError(fmt.Sprintf("Can't create post: %v", err)), errorStatus(err))
 		return
 	}
 
-	h.response(w, SendSuccess("dialog created"), http.StatusOK)
+	h.response(w, SendSuccess(post), http.StatusOK)
 }
 
 func (h *Handlers) GetAllPosts(w http.ResponseWriter, r *http.Request) {
-	posts, err := h.Service.GetAllPosts(r.Context())
+	page, err := pagination.FromRequest(r, storage.PostSorts)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error retrieving users: %v", err), http.StatusInternalServerError)
+		h.response(w, SendError(err.Error()), http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	query := storage.PostQuery{Page: page, ViewerID: h.viewer(r), Author: r.URL.Query().Get("author")}
+	if query.CreatedAfter, err = parseTimeParam(r, "created_after"); err != nil {
+		h.response(w, SendError(err.Error()), http.StatusBadRequest)
+		return
+	}
+	if query.CreatedBefore, err = parseTimeParam(r, "created_before"); err != nil {
+		h.response(w, SendError(err.Error()), http.StatusBadRequest)
+		return
+	}
 
-	err = json.NewEncoder(w).Encode(posts)
+	posts, next, err := h.Service.GetAllPosts(r.Context(), query)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error encoding users to JSON: %v", err), http.StatusInternalServerError)
+		h.response(w, SendError(fmt.Sprintf("Error retrieving posts: %v", err)), http.StatusInternalServerError)
 		return
 	}
+
+	h.response(w, SendPage(posts, next), http.StatusOK)
 }
 
 func (h *Handlers) GetPost(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	postIDStr := vars["id"]
-	if postIDStr == "" {
-		h.response(w, SendError("Missing post ID in request"), http.StatusBadRequest)
-		return
-	}
-
-	postID, err := strconv.Atoi(postIDStr)
-	if err != nil {
-		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+	postID, ok := h.postID(w, r)
+	if !ok {
 		return
 	}
 
 	var post models.Post
 	post.ID = postID
 
-	err = h.Service.GetPost(r.Context(), &post)
+	err := h.Service.GetPost(r.Context(), &post, h.viewer(r))
 	if err != nil {
-		h.response(w, SendError(fmt.Sprintf("Error retrieving post: %v", err)), http.StatusInternalServerError)
+		h.response(w, SendError(fmt.Sprintf("Error retrieving post: %v", err)), errorStatus(err))
 		return
 	}
 
 	h.response(w, SendSuccess(post), http.StatusOK)
 }
+
+// parseTimeParam reads an optional RFC 3339 timestamp or YYYY-MM-DD date from the query string.
+func parseTimeParam(r *http.Request, name string) (time.Time, error) {
+	val := r.URL.Query().Get(name)
+	if val == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, val); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, val)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: expected RFC 3339 timestamp or YYYY-MM-DD date", name)
+	}
+
+	return t, nil
+}
+
+// sessionUser resolves the session from the Authorization header to a user ID.
+// On failure it writes the error response and returns false.
+func (h *Handlers) sessionUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	sessionID := r.Header.Get("Authorization")
+	if sessionID == "" {
+		h.response(w, SendError("Missing session ID in request headers"), http.StatusBadRequest)
+		return "", false
+	}
+
+	userID, err := h.Service.GetUserByID(r.Context(), sessionID)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error retrieving user for session: %v", err)), http.StatusUnauthorized)
+		return "", false
+	}
+	logging.SetUserID(r.Context(), userID)
+
+	return userID, true
+}
+
+// viewer returns the user of the session for endpoints that also serve
+// anonymous requests, or an empty string when there is no valid session.
+func (h *Handlers) viewer(r *http.Request) string {
+	sessionID := r.Header.Get("Authorization")
+	if sessionID == "" {
+		return ""
+	}
+
+	userID, err := h.Service.GetUserByID(r.Context(), sessionID)
+	if err != nil {
+		return ""
+	}
+	logging.SetUserID(r.Context(), userID)
+
+	return userID
+}
+
+// postID reads the {id} path variable of the /api/posts/{id} routes.
+func (h *Handlers) postID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	postIDStr := mux.Vars(r)["id"]
+	if postIDStr == "" {
+		h.response(w, SendError("Missing post ID in request"), http.StatusBadRequest)
+		return 0, false
+	}
+
+	postID, err := strconv.Atoi(postIDStr)
+	if err != nil {
+		h.response(w, SendError("Invalid post ID"), http.StatusBadRequest)
+		return 0, false
+	}
+
+	return postID, true
+}
+
+// errorStatus maps service and storage errors to HTTP status codes.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrForbidden), errors.Is(err, notifications.ErrInvalidToken),
+		errors.Is(err, service.ErrBlocked):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrAlreadyReposted), errors.Is(err, storage.ErrAlreadyVoted),
+		errors.Is(err, storage.ErrPollClosed), errors.Is(err, service.ErrAlreadyReported),
+		errors.Is(err, storage.ErrCaseClaimed), errors.Is(err, storage.ErrCaseResolved):
+		return http.StatusConflict
+	case errors.Is(err, storage.ErrTooDeep), errors.Is(err, service.ErrUnknownReaction),
+		errors.Is(err, service.ErrInvalidSearch), errors.Is(err, service.ErrInvalidFormat),
+		errors.Is(err, service.ErrInvalidStatus), errors.Is(err, service.ErrQuoteTooLong),
+		errors.Is(err, service.ErrInvalidPoll), errors.Is(err, storage.ErrInvalidVote),
+		errors.Is(err, notifications.ErrInvalidPreferences), errors.Is(err, service.ErrSelfRelation),
+		errors.Is(err, service.ErrInvalidReport), errors.Is(err, service.ErrInvalidCaseQuery),
+		errors.Is(err, storage.ErrInvalidResolution):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrContentRejected):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/content"
+)
+
+// ProxyImage streams an image embedded in a rendered post. Only URLs signed by
+// the renderer are served.
+func (h *Handlers) ProxyImage(w http.ResponseWriter, r *http.Request) {
+	src, sig := r.URL.Query().Get("url"), r.URL.Query().Get("sig")
+
+	resp, err := h.Service.FetchImage(r.Context(), src, sig)
+	if err != nil {
+		switch {
+		case errors.Is(err, content.ErrInvalidSignature):
+			h.response(w, SendError(err.Error()), http.StatusForbidden)
+		case errors.Is(err, content.ErrNotAnImage), errors.Is(err, content.ErrForbiddenAddress):
+			h.response(w, SendError(err.Error()), http.StatusUnprocessableEntity)
+		default:
+			h.response(w, SendError("Can't fetch image"), http.StatusBadGateway)
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	w.WriteHeader(http.StatusOK)
+
+	io.Copy(w, io.LimitReader(resp.Body, content.MaxProxiedImageSize))
+}
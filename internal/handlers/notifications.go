@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/logging"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/metrics"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+)
+
+// streamHeartbeat keeps idle streams alive through proxies.
+const streamHeartbeat = 25 * time.Second
+
+// GetNotifications handles GET /api/notifications[?unread=true]. The result
+// is a page of grouped notifications with the total unread count.
+func (h *Handlers) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := pagination.FromRequest(r, storage.NotificationSorts)
+	if err != nil {
+		h.response(w, SendError(err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	query := storage.NotificationQuery{Page: page, UnreadOnly: r.URL.Query().Get("unread") == "true"}
+
+	inbox, next, err := h.Notifications.List(r.Context(), userID, query)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error retrieving notifications: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendPage(inbox, next), http.StatusOK)
+}
+
+type unreadResponse struct {
+	Unread int `json:"unread"`
+}
+
+func (h *Handlers) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	unread, err := h.Notifications.UnreadCount(r.Context(), userID)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error counting notifications: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess(unreadResponse{Unread: unread}), http.StatusOK)
+}
+
+type markReadRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+type markReadResponse struct {
+	Marked int `json:"marked"`
+}
+
+// MarkNotificationsRead handles POST /api/notifications/read with the ids of
+// the notifications, e.g. the ids of a group.
+func (h *Handlers) MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req markReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.response(w, SendError("Invalid input data"), http.StatusBadRequest)
+		return
+	}
+
+	marked, err := h.Notifications.MarkRead(r.Context(), userID, req.IDs)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't mark notifications read: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess(markReadResponse{Marked: marked}), http.StatusOK)
+}
+
+func (h *Handlers) MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	marked, err := h.Notifications.MarkAllRead(r.Context(), userID)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't mark notifications read: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess(markReadResponse{Marked: marked}), http.StatusOK)
+}
+
+type streamTicketResponse struct {
+	Ticket string `json:"ticket"`
+}
+
+// CreateStreamTicket handles POST /api/notifications/stream/ticket. The
+// ticket opens one stream within a short time and is spent by it.
+func (h *Handlers) CreateStreamTicket(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	ticket, err := h.Notifications.StreamTicket(r.Context(), userID)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't create stream ticket: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess(streamTicketResponse{Ticket: ticket}), http.StatusCreated)
+}
+
+// StreamNotifications handles GET /api/notifications/stream, pushing new
+// notifications as Server-Sent Events. EventSource cannot set headers, so
+// browsers authenticate with ?ticket= from CreateStreamTicket instead of the
+// session header.
+func (h *Handlers) StreamNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.streamUser(w, r)
+	if !ok {
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The server write timeout is meant for regular requests, not for streams.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.response(w, SendError("Streaming is not supported"), http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := h.Notifications.Subscribe(userID)
+	defer unsubscribe()
+
+	h.Conns.ConnOpened(metrics.ConnStream)
+	defer h.Conns.ConnClosed(metrics.ConnStream)
+
+	unread, err := h.Notifications.UnreadCount(r.Context(), userID)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error counting notifications: %v", err)), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	writeEvent(w, "unread", "", unreadResponse{Unread: unread})
+	rc.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case n, ok := <-events:
+			if !ok {
+				return
+			}
+			writeEvent(w, "notification", fmt.Sprint(n.ID), n)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// streamUser resolves the user of a stream from its ticket, or from the
+// session header when there is no ticket.
+func (h *Handlers) streamUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	ticket := r.URL.Query().Get("ticket")
+	if ticket == "" {
+		return h.sessionUser(w, r)
+	}
+
+	userID, err := h.Notifications.RedeemStreamTicket(r.Context(), ticket)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Invalid stream ticket: %v", err)), http.StatusUnauthorized)
+		return "", false
+	}
+	logging.SetUserID(r.Context(), userID)
+
+	return userID, true
+}
+
+func writeEvent(w http.ResponseWriter, event, id string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+func (h *Handlers) GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	prefs, err := h.Notifications.Preferences(r.Context(), userID)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error retrieving preferences: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess(prefs), http.StatusOK)
+}
+
+// UpdateNotificationPreferences replaces the preferences of the user. Types
+// and channels missing from the body get their defaults.
+func (h *Handlers) UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	var prefs models.NotificationPreferences
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		h.response(w, SendError("Invalid input data"), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Notifications.UpdatePreferences(r.Context(), userID, &prefs); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't update preferences: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess(prefs), http.StatusOK)
+}
+
+// unsubscribePage is what people following the unsubscribe link of a digest
+// see: a confirmation, so that link scanners opening every URL of an email
+// unsubscribe nobody, and then the outcome. The form posts to the same URL.
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; color: #222;">
+  {{- if .}}
+  <p>You will no longer get email digests of your notifications.</p>
+  {{- else}}
+  <p>Stop getting email digests of your notifications?</p>
+  <form method="post">
+    <input type="hidden" name="List-Unsubscribe" value="One-Click">
+    <button type="submit">Unsubscribe</button>
+  </form>
+  {{- end}}
+</body>
+</html>
+`))
+
+// ConfirmUnsubscribe handles GET on the unsubscribe link of digest emails. It
+// changes nothing and only asks to confirm.
+func (h *Handlers) ConfirmUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("token") == "" {
+		h.response(w, SendError("Missing token in request"), http.StatusBadRequest)
+		return
+	}
+
+	writeUnsubscribePage(w, false)
+}
+
+// Unsubscribe handles POST on the unsubscribe link of digest emails, sent by
+// mail clients as the one-click unsubscribe of RFC 8058 or by the
+// confirmation page. It needs no session: the token identifies the user.
+func (h *Handlers) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		h.response(w, SendError("Missing token in request"), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Notifications.Unsubscribe(r.Context(), token); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't unsubscribe: %v", err)), errorStatus(err))
+		return
+	}
+
+	writeUnsubscribePage(w, true)
+}
+
+func writeUnsubscribePage(w http.ResponseWriter, done bool) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	unsubscribePage.Execute(w, done)
+}
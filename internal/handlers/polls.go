@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type voteRequest struct {
+	OptionIDs []int `json:"option_ids"`
+}
+
+// Vote handles POST /api/posts/{id}/poll/votes. The response is the poll with
+// its results, which become visible once the user has voted.
+func (h *Handlers) Vote(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	postID, ok := h.postID(w, r)
+	if !ok {
+		return
+	}
+
+	var req voteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.response(w, SendError("Invalid input data"), http.StatusBadRequest)
+		return
+	}
+
+	poll, err := h.Service.Vote(r.Context(), postID, userID, req.OptionIDs)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't vote: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess(poll), http.StatusOK)
+}
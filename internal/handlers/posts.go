@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+)
+
+func (h *Handlers) UpdatePost(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	postID, ok := h.postID(w, r)
+	if !ok {
+		return
+	}
+
+	var post models.Post
+	if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
+		h.response(w, SendError("Invalid input data"), http.StatusBadRequest)
+		return
+	}
+	post.ID = postID
+
+	if post.Title == "" || post.Content == "" {
+		h.response(w, SendError("Title and content are required"), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.UpdatePost(r.Context(), &post, userID); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't update post: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess(post), http.StatusOK)
+}
+
+func (h *Handlers) DeletePost(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	postID, ok := h.postID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.DeletePost(r.Context(), postID, userID); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't delete post: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess("post deleted"), http.StatusOK)
+}
+
+func (h *Handlers) GetPostRevisions(w http.ResponseWriter, r *http.Request) {
+	postID, ok := h.postID(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.Service.GetPostRevisions(r.Context(), postID, h.viewer(r))
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error retrieving revisions: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess(revisions), http.StatusOK)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+	"github.com/gorilla/mux"
+)
+
+// The reaction routes are /api/reactions/{target}/{targetID}[/{emoji}[/users]],
+// where target is post or comment.
+
+func (h *Handlers) AddReaction(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	target, targetID, ok := h.reactionTarget(w, r)
+	if !ok {
+		return
+	}
+
+	emoji := mux.Vars(r)["emoji"]
+	if err := h.Service.AddReaction(r.Context(), target, targetID, userID, emoji); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't add reaction: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.writeReactionCounts(w, r, target, targetID, userID)
+}
+
+func (h *Handlers) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	target, targetID, ok := h.reactionTarget(w, r)
+	if !ok {
+		return
+	}
+
+	emoji := mux.Vars(r)["emoji"]
+	if err := h.Service.RemoveReaction(r.Context(), target, targetID, userID, emoji); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't remove reaction: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.writeReactionCounts(w, r, target, targetID, userID)
+}
+
+func (h *Handlers) GetReactions(w http.ResponseWriter, r *http.Request) {
+	target, targetID, ok := h.reactionTarget(w, r)
+	if !ok {
+		return
+	}
+
+	h.writeReactionCounts(w, r, target, targetID, h.viewer(r))
+}
+
+func (h *Handlers) GetReactors(w http.ResponseWriter, r *http.Request) {
+	target, targetID, ok := h.reactionTarget(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := pagination.FromRequest(r, storage.ReactorSorts)
+	if err != nil {
+		h.response(w, SendError(err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	reactors, next, err := h.Service.GetReactors(r.Context(), target, targetID, mux.Vars(r)["emoji"], h.viewer(r), page)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error retrieving reactions: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendPage(reactors, next), http.StatusOK)
+}
+
+func (h *Handlers) writeReactionCounts(w http.ResponseWriter, r *http.Request, target string, targetID int, viewerID string) {
+	counts, err := h.Service.GetReactionCounts(r.Context(), target, targetID, viewerID)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error retrieving reactions: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess(counts), http.StatusOK)
+}
+
+// reactionTarget reads the {target} and {targetID} path variables. The route
+// pattern already restricts target to the known target types.
+func (h *Handlers) reactionTarget(w http.ResponseWriter, r *http.Request) (string, int, bool) {
+	vars := mux.Vars(r)
+
+	targetID, err := strconv.Atoi(vars["targetID"])
+	if err != nil {
+		h.response(w, SendError("Invalid target ID"), http.StatusBadRequest)
+		return "", 0, false
+	}
+
+	return vars["target"], targetID, true
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+	"github.com/gorilla/mux"
+)
+
+func (h *Handlers) Block(w http.ResponseWriter, r *http.Request) {
+	h.changeRelation(w, r, h.Service.Block, "user blocked")
+}
+
+func (h *Handlers) Unblock(w http.ResponseWriter, r *http.Request) {
+	h.changeRelation(w, r, h.Service.Unblock, "user unblocked")
+}
+
+func (h *Handlers) Mute(w http.ResponseWriter, r *http.Request) {
+	h.changeRelation(w, r, h.Service.Mute, "user muted")
+}
+
+func (h *Handlers) Unmute(w http.ResponseWriter, r *http.Request) {
+	h.changeRelation(w, r, h.Service.Unmute, "user unmuted")
+}
+
+// changeRelation applies change to the session user and the {userID} of the
+// route. Repeating a change is not an error.
+func (h *Handlers) changeRelation(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, userID, targetID string) error, done string) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := change(r.Context(), userID, mux.Vars(r)["userID"]); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't change relation: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess(done), http.StatusOK)
+}
+
+// GetBlockedUsers handles GET /api/me/blocks.
+func (h *Handlers) GetBlockedUsers(w http.ResponseWriter, r *http.Request) {
+	h.writeRelatedUsers(w, r, h.Service.GetBlockedUsers)
+}
+
+// GetMutedUsers handles GET /api/me/mutes.
+func (h *Handlers) GetMutedUsers(w http.ResponseWriter, r *http.Request) {
+	h.writeRelatedUsers(w, r, h.Service.GetMutedUsers)
+}
+
+func (h *Handlers) writeRelatedUsers(w http.ResponseWriter, r *http.Request, list func(ctx context.Context, userID string, page pagination.Page) ([]models.RelatedUser, string, error)) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := pagination.FromRequest(r, storage.RelationSorts)
+	if err != nil {
+		h.response(w, SendError(err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	users, next, err := list(r.Context(), userID, page)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error retrieving users: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendPage(users, next), http.StatusOK)
+}
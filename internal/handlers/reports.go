@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+	"github.com/gorilla/mux"
+)
+
+// reportRequest accepts target_id both as a number and as a string, since
+// posts are reported by number and users by UUID.
+type reportRequest struct {
+	TargetType string          `json:"target_type"`
+	TargetID   json.RawMessage `json:"target_id"`
+	Reason     string          `json:"reason"`
+	Details    string          `json:"details"`
+}
+
+// CreateReport handles POST /api/reports.
+func (h *Handlers) CreateReport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req reportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.response(w, SendError("Invalid input data"), http.StatusBadRequest)
+		return
+	}
+
+	report := models.Report{
+		TargetType: req.TargetType,
+		TargetID:   strings.Trim(string(req.TargetID), `"`),
+		Reason:     req.Reason,
+		Details:    req.Details,
+	}
+	if err := h.Service.CreateReport(r.Context(), &report, userID); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't create report: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess(report), http.StatusCreated)
+}
+
+// GetModerationCases handles GET /api/moderation/cases. Filters are status,
+// target_type, reason and assigned=me for the cases claimed by the caller.
+func (h *Handlers) GetModerationCases(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := pagination.FromRequest(r, storage.CaseSorts)
+	if err != nil {
+		h.response(w, SendError(err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	params := r.URL.Query()
+	query := storage.CaseQuery{
+		Page:       page,
+		Status:     params.Get("status"),
+		TargetType: params.Get("target_type"),
+		Reason:     params.Get("reason"),
+	}
+	if params.Get("assigned") == "me" {
+		query.ModeratorID = userID
+	}
+
+	cases, next, err := h.Service.GetModerationCases(r.Context(), query, userID)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error retrieving cases: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendPage(cases, next), http.StatusOK)
+}
+
+func (h *Handlers) GetModerationCase(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	caseID, ok := h.caseID(w, r)
+	if !ok {
+		return
+	}
+
+	c, err := h.Service.GetModerationCase(r.Context(), caseID, userID)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error retrieving case: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess(c), http.StatusOK)
+}
+
+func (h *Handlers) ClaimCase(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	caseID, ok := h.caseID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.ClaimCase(r.Context(), caseID, userID); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't claim case: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess("case claimed"), http.StatusOK)
+}
+
+type resolveRequest struct {
+	Action string `json:"action"`
+	Note   string `json:"note"`
+}
+
+// ResolveCase handles POST /api/moderation/cases/{caseID}/resolve with an
+// action of dismiss, remove_content or suspend_user.
+func (h *Handlers) ResolveCase(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	caseID, ok := h.caseID(w, r)
+	if !ok {
+		return
+	}
+
+	var req resolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.response(w, SendError("Invalid input data"), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.ResolveCase(r.Context(), caseID, userID, req.Action, req.Note); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't resolve case: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess("case resolved"), http.StatusOK)
+}
+
+// GetModerationActions handles GET /api/moderation/actions, the audit trail
+// of all cases.
+func (h *Handlers) GetModerationActions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := pagination.FromRequest(r, storage.ModerationActionSorts)
+	if err != nil {
+		h.response(w, SendError(err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	actions, next, err := h.Service.GetModerationActions(r.Context(), page, userID)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error retrieving actions: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendPage(actions, next), http.StatusOK)
+}
+
+// caseID reads the {caseID} path variable of the moderation routes.
+func (h *Handlers) caseID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	caseID, err := strconv.ParseInt(mux.Vars(r)["caseID"], 10, 64)
+	if err != nil {
+		h.response(w, SendError("Invalid case ID"), http.StatusBadRequest)
+		return 0, false
+	}
+
+	return caseID, true
+}
@@ -11,9 +11,10 @@ const (
 )
 
 type Response struct {
-	Status  string `json:"status"`
-	Message string `json:"message,omitempty"`
-	Result  any    `json:"result,omitempty"`
+	Status     string `json:"status"`
+	Message    string `json:"message,omitempty"`
+	Result     any    `json:"result,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 func SendSuccess(result any) Response {
@@ -23,6 +24,15 @@ func SendSuccess(result any) Response {
 	}
 }
 
+// SendPage wraps one page of a list endpoint. nextCursor is empty on the last page.
+func SendPage(result any, nextCursor string) Response {
+	return Response{
+		Status:     statusOK,
+		Result:     result,
+		NextCursor: nextCursor,
+	}
+}
+
 func SendError(msg string) Response {
 	return Response{
 		Status:  statusErr,
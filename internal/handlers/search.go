@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+)
+
+// Search handles GET /api/search?q=&type=posts|users. Results are ordered by
+// relevance and paged with the usual cursor.
+func (h *Handlers) Search(w http.ResponseWriter, r *http.Request) {
+	page, err := pagination.FromRequest(r, storage.SearchSorts)
+	if err != nil {
+		h.response(w, SendError(err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	query := storage.SearchQuery{Page: page, ViewerID: h.viewer(r), Text: r.URL.Query().Get("q")}
+
+	var (
+		results any
+		next    string
+	)
+
+	switch r.URL.Query().Get("type") {
+	case "", models.SearchPosts:
+		results, next, err = h.Service.SearchPosts(r.Context(), query)
+	case models.SearchUsers:
+		results, next, err = h.Service.SearchUsers(r.Context(), query)
+	default:
+		h.response(w, SendError("type must be posts or users"), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Search failed: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendPage(results, next), http.StatusOK)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+)
+
+func (h *Handlers) AddBookmark(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	postID, ok := h.postID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.AddBookmark(r.Context(), userID, postID); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't bookmark post: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess("post bookmarked"), http.StatusOK)
+}
+
+func (h *Handlers) RemoveBookmark(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	postID, ok := h.postID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.RemoveBookmark(r.Context(), userID, postID); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't remove bookmark: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess("bookmark removed"), http.StatusOK)
+}
+
+// GetBookmarks handles GET /api/me/bookmarks. Bookmarks are private, so there
+// is no way to list those of another user.
+func (h *Handlers) GetBookmarks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := pagination.FromRequest(r, storage.BookmarkSorts)
+	if err != nil {
+		h.response(w, SendError(err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	posts, next, err := h.Service.GetBookmarks(r.Context(), userID, page)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error retrieving bookmarks: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendPage(posts, next), http.StatusOK)
+}
+
+// CreateRepost handles POST /api/posts/{id}/reposts with an optional
+// {"quote": "..."} body.
+func (h *Handlers) CreateRepost(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	postID, ok := h.postID(w, r)
+	if !ok {
+		return
+	}
+
+	var repost models.Repost
+	if err := json.NewDecoder(r.Body).Decode(&repost); err != nil && err != io.EOF {
+		h.response(w, SendError("Invalid input data"), http.StatusBadRequest)
+		return
+	}
+	repost.Post = models.Post{ID: postID}
+
+	if err := h.Service.CreateRepost(r.Context(), &repost, userID); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't repost: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess(repost), http.StatusCreated)
+}
+
+func (h *Handlers) DeleteRepost(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	postID, ok := h.postID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.DeleteRepost(r.Context(), userID, postID); err != nil {
+		h.response(w, SendError(fmt.Sprintf("Can't undo repost: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendSuccess("repost removed"), http.StatusOK)
+}
+
+// GetFeed handles GET /api/feed, the timeline of posts and reposts. With
+// ?author= it is the timeline of a single user.
+func (h *Handlers) GetFeed(w http.ResponseWriter, r *http.Request) {
+	page, err := pagination.FromRequest(r, storage.FeedSorts)
+	if err != nil {
+		h.response(w, SendError(err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	query := storage.FeedQuery{Page: page, ViewerID: h.viewer(r), Author: r.URL.Query().Get("author")}
+
+	items, next, err := h.Service.GetFeed(r.Context(), query)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error retrieving feed: %v", err)), errorStatus(err))
+		return
+	}
+
+	h.response(w, SendPage(items, next), http.StatusOK)
+}
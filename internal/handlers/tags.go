@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/service"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+	"github.com/gorilla/mux"
+)
+
+const defaultTrendingLimit = 10
+
+func (h *Handlers) GetTagPosts(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(strings.TrimPrefix(mux.Vars(r)["tag"], "#"))
+	if tag == "" {
+		h.response(w, SendError("Missing tag in request"), http.StatusBadRequest)
+		return
+	}
+
+	page, err := pagination.FromRequest(r, storage.PostSorts)
+	if err != nil {
+		h.response(w, SendError(err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	query := storage.PostQuery{Page: page, ViewerID: h.viewer(r), Tag: tag}
+
+	posts, next, err := h.Service.GetAllPosts(r.Context(), query)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error retrieving posts: %v", err)), http.StatusInternalServerError)
+		return
+	}
+
+	h.response(w, SendPage(posts, next), http.StatusOK)
+}
+
+// GetTrendingTags handles GET /api/tags/trending?window=24h&limit=10.
+func (h *Handlers) GetTrendingTags(w http.ResponseWriter, r *http.Request) {
+	window := service.DefaultTrendingWindow
+	if val := r.URL.Query().Get("window"); val != "" {
+		parsed, err := time.ParseDuration(val)
+		if err != nil || parsed <= 0 {
+			h.response(w, SendError("invalid window, expected a duration such as 24h"), http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	limit := defaultTrendingLimit
+	if val := r.URL.Query().Get("limit"); val != "" {
+		parsed, err := strconv.Atoi(val)
+		if err != nil || parsed < 1 {
+			h.response(w, SendError("invalid limit"), http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	tags, err := h.Service.GetTrendingTags(r.Context(), window, limit)
+	if err != nil {
+		h.response(w, SendError(fmt.Sprintf("Error retrieving trending tags: %v", err)), http.StatusInternalServerError)
+		return
+	}
+
+	h.response(w, SendSuccess(tags), http.StatusOK)
+}
@@ -0,0 +1,46 @@
+package health
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Database checks that the pool can reach the database.
+func Database(db *pgxpool.Pool) Check {
+	return func(ctx context.Context) error {
+		return db.Ping(ctx)
+	}
+}
+
+// Migrations checks that the schema is at least at version want and that no
+// migration failed halfway.
+func Migrations(db *pgxpool.Pool, want uint) Check {
+	return func(ctx context.Context) error {
+		var version int64
+		var dirty bool
+		err := db.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+		if err != nil {
+			return fmt.Errorf("failed to read schema version: %v", err)
+		}
+
+		switch {
+		case dirty:
+			return fmt.Errorf("migration %d is dirty", version)
+		case version < int64(want):
+			return fmt.Errorf("schema is at version %d, want %d", version, want)
+		}
+		return nil
+	}
+}
+
+// Pinger is a dependency that can be pinged, such as the Redis client of the
+// rate limiter.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+func Ping(p Pinger) Check {
+	return p.Ping
+}
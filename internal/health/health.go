@@ -0,0 +1,178 @@
+// Package health serves the liveness and readiness probes of the
+// application. Liveness only tells that the process serves requests;
+// readiness runs the dependency checks and fails as soon as shutdown begins,
+// so load balancers stop sending traffic before the server drains.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check reports whether a dependency is usable.
+type Check func(ctx context.Context) error
+
+// Statuses of the probes and their checks.
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Checker runs the readiness checks, each bounded by timeout. A readiness
+// result is reused for ttl, so probes hitting the endpoint often, or anyone
+// else, do not turn into a query per request.
+type Checker struct {
+	timeout time.Duration
+	ttl     time.Duration
+	log     *slog.Logger
+
+	mu     sync.Mutex
+	names  []string
+	checks map[string]Check
+
+	// runMu makes concurrent probes wait for one run and share its result.
+	runMu     sync.Mutex
+	last      Report
+	checkedAt time.Time
+
+	shuttingDown atomic.Bool
+}
+
+func NewChecker(timeout, ttl time.Duration, log *slog.Logger) *Checker {
+	return &Checker{timeout: timeout, ttl: ttl, log: log, checks: make(map[string]Check)}
+}
+
+// Add registers a readiness check under name.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+// ShutDown makes readiness fail from now on.
+func (c *Checker) ShutDown() {
+	c.shuttingDown.Store(true)
+}
+
+// CheckResult is the outcome of one check.
+type CheckResult struct {
+	Status  string
+	Latency time.Duration
+	Err     error
+}
+
+// Report is the body of the probe responses. Checks only carries the status
+// of each check: the errors may describe the infrastructure, so they are
+// logged instead.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Run runs the checks concurrently. The overall status is ok only if all of
+// them pass.
+func (c *Checker) Run(ctx context.Context) (string, map[string]CheckResult) {
+	c.mu.Lock()
+	names := append([]string(nil), c.names...)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = c.checks[name]
+	}
+	c.mu.Unlock()
+
+	results := make([]CheckResult, len(names))
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = c.run(ctx, checks[i])
+		}(i)
+	}
+	wg.Wait()
+
+	status := StatusOK
+	byName := make(map[string]CheckResult, len(names))
+	for i, name := range names {
+		byName[name] = results[i]
+		if results[i].Status != StatusOK {
+			status = StatusFail
+		}
+	}
+	return status, byName
+}
+
+func (c *Checker) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	res := CheckResult{Status: StatusOK, Latency: time.Since(start), Err: err}
+	if err != nil {
+		res.Status = StatusFail
+	}
+	return res
+}
+
+// readiness returns the last report while it is fresh, or runs the checks and
+// logs those that failed. The run does not depend on the probe that started
+// it, whose result is shared.
+func (c *Checker) readiness(ctx context.Context) Report {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.ttl {
+		return c.last
+	}
+
+	status, results := c.Run(context.WithoutCancel(ctx))
+	report := Report{Status: status, Checks: make(map[string]string, len(results))}
+	for name, res := range results {
+		report.Checks[name] = res.Status
+		if res.Err != nil {
+			c.log.WarnContext(ctx, "readiness check failed", "check", name, "latency", res.Latency, "err", res.Err)
+		}
+	}
+
+	c.last, c.checkedAt = report, time.Now()
+	return report
+}
+
+// Liveness handles /healthz. It does not look at dependencies, so a
+// database outage does not get the process restarted.
+func (c *Checker) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, Report{Status: StatusOK}, http.StatusOK)
+}
+
+// Readiness handles /readyz.
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	if c.shuttingDown.Load() {
+		writeReport(w, Report{Status: StatusShuttingDown}, http.StatusServiceUnavailable)
+		return
+	}
+
+	report := c.readiness(r.Context())
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeReport(w, report, status)
+}
+
+func writeReport(w http.ResponseWriter, report Report, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
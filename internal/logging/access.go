@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds incoming request IDs so clients cannot flood the logs.
+const maxRequestIDLen = 128
+
+// unmatchedRoute is the route of requests no route matched.
+const unmatchedRoute = "unmatched"
+
+// Middleware assigns every request an ID, taken from X-Request-ID when the
+// client sent a usable one, returns it in the response and writes an access
+// log record once the request is served. Requests are grouped by the route
+// template RouteMiddleware records rather than by path.
+func Middleware(log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			ctx := WithRequestID(r.Context(), id)
+			req := ctx.Value(requestKey{}).(*request)
+
+			next.ServeHTTP(&responseWriter{ResponseWriter: w, req: req}, r.WithContext(ctx))
+
+			level := slog.LevelInfo
+			if req.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			log.LogAttrs(ctx, level, "request",
+				slog.String("method", r.Method),
+				slog.String("route", req.route),
+				slog.Int("status", req.status),
+				slog.Duration("latency", time.Since(start)),
+				slog.Int64("bytes", req.bytes),
+				slog.String("userID", req.userID),
+			)
+		})
+	}
+}
+
+// RouteMiddleware records the template of the matched route for Middleware.
+// It is installed on the router, which has already matched the route.
+func RouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if req, ok := r.Context().Value(requestKey{}).(*request); ok {
+			if route := mux.CurrentRoute(r); route != nil {
+				if tmpl, err := route.GetPathTemplate(); err == nil {
+					req.route = tmpl
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// responseWriter records the status and size of a response in the request.
+type responseWriter struct {
+	http.ResponseWriter
+	req         *request
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.req.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.req.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the flusher of the notification
+// stream.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,146 @@
+// Package logging builds the application logger and the access log. Every
+// record logged with a request context carries the ID of that request.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Outputs.
+const (
+	OutputFile   = "file"
+	OutputStdout = "stdout"
+)
+
+// Config configures the logger. File output is rotated when the file grows
+// past MaxSize or every RotateEvery, whichever comes first; rotated files are
+// removed once older than MaxAge or beyond the newest MaxFiles.
+type Config struct {
+	// Level is a *slog.LevelVar when the level changes at runtime.
+	Level  slog.Leveler
+	Format string
+	Output string
+
+	Dir         string
+	MaxSize     int64
+	RotateEvery time.Duration
+	MaxAge      time.Duration
+	MaxFiles    int
+}
+
+// New creates the logger described by cfg. The returned closer flushes and
+// closes the log file; it is a no-op for stdout.
+func New(cfg Config) (*slog.Logger, io.Closer, error) {
+	var out io.WriteCloser = nopCloser{os.Stdout}
+	if cfg.Output != OutputStdout {
+		file, err := OpenRotatingFile(cfg.Dir, "app", cfg.MaxSize, cfg.RotateEvery, cfg.MaxAge, cfg.MaxFiles)
+		if err != nil {
+			return nil, nil, err
+		}
+		out = file
+	}
+
+	opts := &slog.HandlerOptions{Level: cfg.Level}
+
+	var handler slog.Handler
+	if cfg.Format == FormatJSON {
+		handler = slog.NewJSONHandler(out, opts)
+	} else {
+		handler = slog.NewTextHandler(out, opts)
+	}
+
+	return slog.New(&contextHandler{Handler: handler}), out, nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// contextHandler adds the request ID and the W3C trace context of the
+// context to records, so log lines can be found from a trace and back.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+type requestKey struct{}
+
+// request is what the access log learns about a request while it is served.
+type request struct {
+	id     string
+	userID string
+	route  string
+	status int
+	bytes  int64
+}
+
+// WithRequestID returns a context carrying the request ID id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestKey{}, &request{id: id, route: unmatchedRoute, status: http.StatusOK})
+}
+
+// RequestID returns the request ID of ctx, or an empty string.
+func RequestID(ctx context.Context) string {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		return req.id
+	}
+	return ""
+}
+
+// Served returns the route template and response status of the request of
+// ctx, for middleware inside Middleware to read once the handler returned
+// instead of wrapping the response and matching the route again. Requests
+// that matched no route have the "unmatched" route.
+func Served(ctx context.Context) (route string, status int) {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		return req.route, req.status
+	}
+	return unmatchedRoute, http.StatusOK
+}
+
+// SetUserID records the user a request was made by for the access log and
+// on the current span. It does nothing outside a request.
+func SetUserID(ctx context.Context, userID string) {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		req.userID = userID
+	}
+	trace.SpanFromContext(ctx).SetAttributes(semconv.EnduserID(userID))
+}
@@ -0,0 +1,177 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedFormat is the timestamp rotated files are named with.
+const rotatedFormat = "2006-01-02T15-04-05"
+
+// RotatingFile writes to dir/name.log and moves it aside to
+// dir/name-<time>.log when it grows past maxSize bytes or at every multiple
+// of every. Zero maxSize or every disables that kind of rotation.
+type RotatingFile struct {
+	dir      string
+	name     string
+	maxSize  int64
+	every    time.Duration
+	maxAge   time.Duration
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	next time.Time
+}
+
+// OpenRotatingFile opens the log file, appending to it if it exists. A file
+// left from an earlier period is rotated right away.
+func OpenRotatingFile(dir, name string, maxSize int64, every, maxAge time.Duration, maxFiles int) (*RotatingFile, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create log dir: %v", err)
+	}
+
+	f := &RotatingFile{dir: dir, name: name, maxSize: maxSize, every: every, maxAge: maxAge, maxFiles: maxFiles}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	if !f.next.IsZero() && !time.Now().Before(f.next) {
+		if err := f.rotate(time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+func (f *RotatingFile) path() string {
+	return filepath.Join(f.dir, f.name+".log")
+}
+
+// open opens the current file and schedules its rotation by the time it was
+// last written to.
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %v", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.next = time.Time{}
+	if f.every > 0 {
+		f.next = info.ModTime().Truncate(f.every).Add(f.every)
+	}
+
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	sizeExceeded := f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize
+	if sizeExceeded || (!f.next.IsZero() && !now.Before(f.next)) {
+		if err := f.rotate(now); err != nil {
+			// Keep logging into the current file rather than losing records.
+			fmt.Fprintf(os.Stderr, "log rotation failed, retrying on the next write: %v\n", err)
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate moves the current file aside and opens a new one. The old handle is
+// closed only once the new file is open: on failure records keep going to
+// it, and the next write tries again.
+func (f *RotatingFile) rotate(now time.Time) error {
+	rotated := filepath.Join(f.dir, f.name+"-"+now.Format(rotatedFormat)+".log")
+	// Two rotations within a second must not overwrite each other.
+	for i := 1; fileExists(rotated); i++ {
+		rotated = filepath.Join(f.dir, fmt.Sprintf("%s-%s.%d.log", f.name, now.Format(rotatedFormat), i))
+	}
+	// After a failed open the file was already moved on the previous try.
+	if err := os.Rename(f.path(), rotated); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to rename log file: %v", err)
+	}
+
+	old := f.file
+	if err := f.open(); err != nil {
+		return err
+	}
+	old.Close()
+
+	f.removeOld(now)
+	return nil
+}
+
+// removeOld applies the retention to the rotated files.
+func (f *RotatingFile) removeOld(now time.Time) {
+	if f.maxAge <= 0 && f.maxFiles <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(filepath.Join(f.dir, f.name+"-*.log"))
+	if err != nil {
+		return
+	}
+
+	type rotatedFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []rotatedFile
+	for _, path := range matches {
+		if strings.HasSuffix(path, string(filepath.Separator)+f.name+".log") {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		files = append(files, rotatedFile{path: path, modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+
+	for i, file := range files {
+		tooOld := f.maxAge > 0 && now.Sub(file.modTime) > f.maxAge
+		tooMany := f.maxFiles > 0 && i >= f.maxFiles
+		if tooOld || tooMany {
+			os.Remove(file.path)
+		}
+	}
+}
+
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,157 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileSize(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxSize     int64
+		maxFiles    int
+		writes      []string
+		wantCurrent string
+		wantRotated int
+	}{
+		{name: "no limit", writes: []string{"one\n", "two\n", "three\n"}, wantCurrent: "one\ntwo\nthree\n"},
+		{name: "within limit", maxSize: 100, writes: []string{"one\n", "two\n"}, wantCurrent: "one\ntwo\n"},
+		{name: "rotates past limit", maxSize: 10, writes: []string{"first\n", "second\n", "third\n"}, wantCurrent: "third\n", wantRotated: 2},
+		{name: "oversized record kept whole", maxSize: 4, writes: []string{"too long\n"}, wantCurrent: "too long\n"},
+		{name: "keeps max files", maxSize: 10, maxFiles: 1, writes: []string{"first\n", "second\n", "third\n"}, wantCurrent: "third\n", wantRotated: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f, err := OpenRotatingFile(dir, "app", tt.maxSize, 0, 0, tt.maxFiles)
+			if err != nil {
+				t.Fatalf("OpenRotatingFile: %v", err)
+			}
+			defer f.Close()
+
+			for _, w := range tt.writes {
+				if _, err := f.Write([]byte(w)); err != nil {
+					t.Fatalf("Write: %v", err)
+				}
+			}
+
+			if got := readFile(t, filepath.Join(dir, "app.log")); got != tt.wantCurrent {
+				t.Errorf("current file = %q, want %q", got, tt.wantCurrent)
+			}
+			if got := rotatedFiles(t, dir, "app"); len(got) != tt.wantRotated {
+				t.Errorf("rotated files = %q, want %d", got, tt.wantRotated)
+			}
+		})
+	}
+}
+
+func TestRotatingFileMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "app-2020-01-01T00-00-00.log")
+	other := filepath.Join(dir, "other-2020-01-01T00-00-00.log")
+	for _, path := range []string{old, other} {
+		writeFile(t, path, "old\n", 48*time.Hour)
+	}
+
+	f, err := OpenRotatingFile(dir, "app", 10, 0, 24*time.Hour, 0)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile: %v", err)
+	}
+	defer f.Close()
+
+	for _, w := range []string{"first\n", "second\n"} {
+		if _, err := f.Write([]byte(w)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	if fileExists(old) {
+		t.Error("expired rotated file kept")
+	}
+	if !fileExists(other) {
+		t.Error("file of another log removed")
+	}
+	if got := rotatedFiles(t, dir, "app"); len(got) != 1 {
+		t.Errorf("rotated files = %q, want the one just rotated", got)
+	}
+}
+
+func TestOpenRotatingFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		age         time.Duration
+		wantCurrent string
+		wantRotated int
+	}{
+		{name: "appends to current period", age: 0, wantCurrent: "earlier\nlater\n"},
+		{name: "rotates earlier period", age: 2 * time.Hour, wantCurrent: "later\n", wantRotated: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "app.log")
+			writeFile(t, path, "earlier\n", tt.age)
+
+			f, err := OpenRotatingFile(dir, "app", 0, time.Hour, 0, 0)
+			if err != nil {
+				t.Fatalf("OpenRotatingFile: %v", err)
+			}
+			defer f.Close()
+
+			if _, err := f.Write([]byte("later\n")); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+
+			if got := readFile(t, path); got != tt.wantCurrent {
+				t.Errorf("current file = %q, want %q", got, tt.wantCurrent)
+			}
+			rotated := rotatedFiles(t, dir, "app")
+			if len(rotated) != tt.wantRotated {
+				t.Fatalf("rotated files = %q, want %d", rotated, tt.wantRotated)
+			}
+			for _, r := range rotated {
+				if got := readFile(t, r); got != "earlier\n" {
+					t.Errorf("rotated file = %q, want %q", got, "earlier\n")
+				}
+			}
+		})
+	}
+}
+
+// rotatedFiles lists the rotated files of the log name in dir.
+func rotatedFiles(t *testing.T, dir, name string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, name+"-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// writeFile creates path with content, last modified age ago.
+func writeFile(t *testing.T, path, content string, age time.Duration) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if age == 0 {
+		return
+	}
+	modTime := time.Now().Add(-age)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
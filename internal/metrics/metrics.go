@@ -0,0 +1,147 @@
+// Package metrics exposes the Prometheus metrics of the application: HTTP
+// requests by route, database pool statistics and counters of user activity.
+// They are served by Handler on a listener of their own, away from the public
+// port.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/logging"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "deadlock"
+
+// Metrics holds the collectors of the application in a registry of its own.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+
+	logins        *prometheus.CounterVec
+	registrations prometheus.Counter
+	posts         prometheus.Counter
+
+	connections *prometheus.GaugeVec
+}
+
+// New registers the collectors, including the Go runtime and process
+// collectors and the statistics of db.
+func New(db *pgxpool.Pool) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route template, method and status.",
+		}, []string{"route", "method", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by route template, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Login attempts by result.",
+		}, []string{"result"}),
+		registrations: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "registrations_total",
+			Help:      "Registered users.",
+		}),
+		posts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "posts_created_total",
+			Help:      "Created posts.",
+		}),
+		connections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active_connections",
+			Help:      "Open long-lived client connections by kind.",
+		}, []string{"kind"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		newPoolCollector(db),
+		m.requests,
+		m.latency,
+		m.logins,
+		m.registrations,
+		m.posts,
+		m.connections,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Login counts a login attempt.
+func (m *Metrics) Login(ok bool) {
+	result := "success"
+	if !ok {
+		result = "failure"
+	}
+	m.logins.WithLabelValues(result).Inc()
+}
+
+func (m *Metrics) Registration() {
+	m.registrations.Inc()
+}
+
+func (m *Metrics) PostCreated() {
+	m.posts.Inc()
+}
+
+// ConnStream is the kind of notification streams.
+const ConnStream = "notification_stream"
+
+// ConnOpened and ConnClosed track long-lived connections by kind.
+func (m *Metrics) ConnOpened(kind string) {
+	m.connections.WithLabelValues(kind).Inc()
+}
+
+func (m *Metrics) ConnClosed(kind string) {
+	m.connections.WithLabelValues(kind).Dec()
+}
+
+// methods are the request methods counted under their own label; the rest
+// share "OTHER", so clients cannot grow the label values.
+var methods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodConnect: true,
+	http.MethodOptions: true, http.MethodTrace: true,
+}
+
+// Middleware counts requests and their latency by route template, which
+// keeps the label values bounded. It must run inside logging.Middleware,
+// which records the route and status it reads.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+
+		method := r.Method
+		if !methods[method] {
+			method = "OTHER"
+		}
+		route, code := logging.Served(r.Context())
+		status := strconv.Itoa(code)
+		m.requests.WithLabelValues(route, method, status).Inc()
+		m.latency.WithLabelValues(route, method, status).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,236 @@
+// Package migrator manages the database schema with the migrations embedded
+// in the binary.
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/migrations"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+)
+
+// lockID is the key of the advisory lock that serializes migrations started
+// by replicas at the same time.
+const lockID int64 = 0x646561646c6f636b // "deadlock"
+
+// Migrator applies the embedded migrations to one database.
+type Migrator struct {
+	m      *migrate.Migrate
+	source source.Driver
+	log    *slog.Logger
+}
+
+// New opens the database at dsn, a postgres:// URL. Migrations run through
+// pgx, so they support the same sslmode values as the app.
+func New(dsn string, log *slog.Logger) (*Migrator, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database URL: %v", err)
+	}
+	u.Scheme = "pgx5"
+
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %v", err)
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", src, u.String())
+	if err != nil {
+		src.Close()
+		// The error may quote the URL, which must not leak the password.
+		msg := strings.ReplaceAll(err.Error(), u.String(), u.Redacted())
+		return nil, fmt.Errorf("failed to create migrate instance: %s", msg)
+	}
+
+	return &Migrator{m: m, source: src, log: log}, nil
+}
+
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	return errors.Join(srcErr, dbErr)
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up() error {
+	return m.done(m.m.Up())
+}
+
+// Steps applies n migrations up, or reverts -n when n is negative.
+func (m *Migrator) Steps(n int) error {
+	return m.done(m.m.Steps(n))
+}
+
+// Goto migrates up or down to version.
+func (m *Migrator) Goto(version uint) error {
+	return m.done(m.m.Migrate(version))
+}
+
+// Force sets the version without running any migration and clears the dirty
+// flag. It is how a migration that failed halfway is recovered from, once
+// the schema was fixed by hand.
+func (m *Migrator) Force(version int) error {
+	if err := m.m.Force(version); err != nil {
+		return fmt.Errorf("failed to force version %d: %v", version, err)
+	}
+	m.log.Info("schema version forced", "version", version)
+	return nil
+}
+
+func (m *Migrator) done(err error) error {
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to migrate: %v", err)
+	}
+
+	version, dirty, err := m.Version()
+	if err != nil {
+		return err
+	}
+	m.log.Info("migrations applied", "version", version, "dirty", dirty)
+	return nil
+}
+
+// Version is the version the schema is at, zero before the first migration.
+// A dirty schema stopped halfway through that migration.
+func (m *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = m.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, fmt.Errorf("failed to read schema version: %v", err)
+	}
+	return version, dirty, nil
+}
+
+// Migration is an embedded migration.
+type Migration struct {
+	Version uint
+	Name    string
+}
+
+// Migrations lists the embedded migrations in order.
+func (m *Migrator) Migrations() ([]Migration, error) {
+	var list []Migration
+	version, err := m.source.First()
+	for err == nil {
+		var name string
+		if name, err = m.name(version); err != nil {
+			return nil, err
+		}
+		list = append(list, Migration{Version: version, Name: name})
+		version, err = m.source.Next(version)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list migrations: %v", err)
+	}
+	return list, nil
+}
+
+func (m *Migrator) name(version uint) (string, error) {
+	r, name, err := m.source.ReadUp(version)
+	if err != nil {
+		return "", fmt.Errorf("failed to read migration %d: %v", version, err)
+	}
+	r.Close()
+	return name, nil
+}
+
+// Latest is the version of the newest embedded migration, the one a fully
+// migrated schema is at.
+func Latest() (uint, error) {
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read migrations: %v", err)
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	for err == nil {
+		var next uint
+		if next, err = src.Next(version); err == nil {
+			version = next
+		}
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return 0, fmt.Errorf("failed to list migrations: %v", err)
+	}
+	return version, nil
+}
+
+// UpLocked applies the pending migrations while holding an advisory lock on
+// db, so replicas starting together migrate one after another; those that
+// get the lock later find nothing left to do.
+func UpLocked(ctx context.Context, db *pgxpool.Pool, dsn string, log *slog.Logger) error {
+	conn, err := db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %v", err)
+	}
+	defer conn.Release()
+
+	log.InfoContext(ctx, "waiting for the migration lock")
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("failed to take the migration lock: %v", err)
+	}
+	defer func() {
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+			log.ErrorContext(ctx, "failed to release the migration lock", "err", err)
+		}
+	}()
+
+	m, err := New(dsn, log)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return m.Up()
+}
+
+var (
+	migrationFile = regexp.MustCompile(`^(\d+)_.+\.(up|down)\.sql$`)
+	migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+// Create writes empty up and down files of a new migration named name to
+// dir, numbered after the last migration there, and returns their paths.
+func Create(dir, name string) ([]string, error) {
+	if !migrationName.MatchString(name) {
+		return nil, fmt.Errorf("invalid migration name %q: use lowercase letters, digits and underscores", name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations dir: %v", err)
+	}
+	var last uint64
+	for _, e := range entries {
+		if match := migrationFile.FindStringSubmatch(e.Name()); match != nil {
+			n, _ := strconv.ParseUint(match[1], 10, 64)
+			last = max(last, n)
+		}
+	}
+
+	var paths []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%06d_%s.%s.sql", last+1, name, direction))
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			return paths, fmt.Errorf("failed to create migration: %v", err)
+		}
+		f.Close()
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,84 @@
+package migrator
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		migName  string
+		want     []string
+		wantErr  string
+	}{
+		{
+			name:    "first migration",
+			migName: "create_users",
+			want:    []string{"000001_create_users.up.sql", "000001_create_users.down.sql"},
+		},
+		{
+			name:     "numbered after the last",
+			existing: []string{"000001_create_users.up.sql", "000001_create_users.down.sql", "000017_add_stream_tickets.up.sql", "000017_add_stream_tickets.down.sql"},
+			migName:  "add_index",
+			want:     []string{"000018_add_index.up.sql", "000018_add_index.down.sql"},
+		},
+		{
+			name:     "other files ignored",
+			existing: []string{"README.md", "99_notes.txt", "000003_x.up.sql"},
+			migName:  "add_index",
+			want:     []string{"000004_add_index.up.sql", "000004_add_index.down.sql"},
+		},
+		{name: "upper case", migName: "AddIndex", wantErr: "invalid migration name"},
+		{name: "spaces", migName: "add index", wantErr: "invalid migration name"},
+		{name: "path", migName: "../add_index", wantErr: "invalid migration name"},
+		{name: "empty", migName: "", wantErr: "invalid migration name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.existing {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			paths, err := Create(dir, tt.migName)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Create(%q) error = %v, want %q", tt.migName, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create(%q): %v", tt.migName, err)
+			}
+
+			var got []string
+			for _, path := range paths {
+				if filepath.Dir(path) != dir {
+					t.Errorf("%s is outside of %s", path, dir)
+				}
+				if _, err := os.Stat(path); err != nil {
+					t.Errorf("file not created: %v", err)
+				}
+				got = append(got, filepath.Base(path))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Create(%q) = %q, want %q", tt.migName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateMissingDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+	if _, err := Create(dir, "add_index"); err == nil {
+		t.Fatal("Create succeeded without a migrations dir")
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+const (
+	// MaxCommentDepth is the deepest level a reply can be created at, roots are depth 0.
+	MaxCommentDepth = 10
+	// MaxThreadReplies is how many replies are returned below each root
+	// comment. The root of a longer thread has MoreReplies set.
+	MaxThreadReplies = 100
+	// DeletedCommentPlaceholder replaces the content of deleted comments that
+	// are still shown because of their replies.
+	DeletedCommentPlaceholder = "[комментарий удалён]"
+	// HiddenCommentPlaceholder replaces the content of comments hidden
+	// pending moderator review.
+	HiddenCommentPlaceholder = "[комментарий скрыт до проверки]"
+)
+
+type Comment struct {
+	ID       int    `json:"id"`
+	PostID   int    `json:"post_id"`
+	ParentID *int   `json:"parent_id"`
+	Depth    int    `json:"depth"`
+	Content  string `json:"content"`
+	Username string `json:"username,omitempty"`
+	Avatar   string `json:"avatar,omitempty"`
+	CreateAt string `json:"create_at"`
+	EditedAt string `json:"edited_at,omitempty"`
+	Deleted  bool   `json:"deleted,omitempty"`
+	Hidden   bool   `json:"hidden,omitempty"`
+
+	MoreReplies bool `json:"more_replies,omitempty"`
+
+	Reactions []ReactionCount `json:"reactions"`
+	Replies   []*Comment      `json:"replies,omitempty"`
+}
@@ -0,0 +1,23 @@
+package models
+
+// Entity types found in post content.
+const (
+	EntityHashtag = "hashtag"
+	EntityMention = "mention"
+)
+
+// Entity marks a hashtag or mention in post content. Offset and Length are in
+// UTF-16 code units, the way JavaScript indexes strings. Value is the tag in
+// lower case without '#', or the username without '@'.
+type Entity struct {
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	Value  string `json:"value"`
+}
+
+// TrendingTag is a hashtag with the number of posts using it in the trending window.
+type TrendingTag struct {
+	Tag   string `json:"tag"`
+	Posts int    `json:"posts"`
+}
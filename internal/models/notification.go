@@ -0,0 +1,48 @@
+package models
+
+// Notification types.
+const (
+	NotificationMention  = "mention"
+	NotificationComment  = "comment"
+	NotificationReply    = "reply"
+	NotificationReaction = "reaction"
+	NotificationRepost   = "repost"
+	NotificationMessage  = "message"
+	NotificationFollow   = "follow"
+)
+
+type Notification struct {
+	ID            int64  `json:"id"`
+	UserID        string `json:"-"`
+	Type          string `json:"type"`
+	ActorID       string `json:"actor_id,omitempty"`
+	ActorUsername string `json:"actor_username,omitempty"`
+	TargetType    string `json:"target_type,omitempty"`
+	TargetID      int64  `json:"target_id,omitempty"`
+	CreateAt      string `json:"create_at"`
+	Read          bool   `json:"read"`
+}
+
+// NotificationGroup merges notifications of one type about the same target,
+// such as all reactions to a post, into a single inbox entry. ID is the id of
+// the latest notification, IDs lists all of them for marking the group read.
+type NotificationGroup struct {
+	ID          int64    `json:"id"`
+	IDs         []int64  `json:"ids"`
+	Type        string   `json:"type"`
+	TargetType  string   `json:"target_type,omitempty"`
+	TargetID    int64    `json:"target_id,omitempty"`
+	Actors      []string `json:"actors"`
+	ActorsCount int      `json:"actors_count"`
+	Count       int      `json:"count"`
+	Summary     string   `json:"summary"`
+	CreateAt    string   `json:"create_at"`
+	Read        bool     `json:"read"`
+}
+
+// NotificationInbox is a page of the inbox with the total number of unread
+// notifications.
+type NotificationInbox struct {
+	Unread int                 `json:"unread"`
+	Groups []NotificationGroup `json:"groups"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+const (
+	MinPollOptions      = 2
+	MaxPollOptions      = 10
+	MaxPollOptionLength = 200
+)
+
+// Poll is attached to a post. A poll is closed once ClosesAt has passed. The
+// vote counts are only filled in when the current user has voted or the poll
+// is closed.
+type Poll struct {
+	ID       int          `json:"id"`
+	Multiple bool         `json:"multiple"`
+	ClosesAt *time.Time   `json:"closes_at,omitempty"`
+	Closed   bool         `json:"closed"`
+	Voters   int          `json:"voters"`
+	Voted    bool         `json:"voted"`
+	Options  []PollOption `json:"options"`
+}
+
+// PollOption is one answer of a poll. Chosen marks the options the current
+// user voted for.
+type PollOption struct {
+	ID     int    `json:"id"`
+	Text   string `json:"text"`
+	Votes  *int   `json:"votes,omitempty"`
+	Chosen bool   `json:"chosen"`
+}
+
+// ResultsVisible reports whether the vote counts may be shown to the user
+// the poll was loaded for.
+func (p *Poll) ResultsVisible() bool {
+	return p.Voted || p.Closed
+}
@@ -1,10 +1,61 @@
 package models
 
+import "time"
+
+// Post content formats.
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+)
+
+// Post statuses. Drafts and scheduled posts are only visible to their author,
+// scheduled posts are published by the background publisher at PublishAt.
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusPublished = "published"
+)
+
+// Content is the source in Format. ContentHTML is rendered by the server and
+// safe to insert into a page as is.
 type Post struct {
-	ID       int    `json:"id"`
-	Title    string `json:"title"`
-	Content  string `json:"content"`
-	CreateAt string `json:"create_at"`
-	Username string `json:"username"`
-	Avatar   string `json:"avatar"`
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Content     string     `json:"content"`
+	Format      string     `json:"format"`
+	ContentHTML string     `json:"content_html"`
+	CreateAt    string     `json:"create_at"`
+	UpdatedAt   string     `json:"updated_at"`
+	EditedAt    string     `json:"edited_at,omitempty"`
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	AuthorID    string     `json:"-"`
+	Username    string     `json:"username"`
+	Avatar      string     `json:"avatar"`
+
+	CommentsCount  int             `json:"comments_count"`
+	RepostsCount   int             `json:"reposts_count"`
+	BookmarksCount int             `json:"bookmarks_count"`
+	Reposted       bool            `json:"reposted"`
+	Bookmarked     bool            `json:"bookmarked"`
+	Reactions      []ReactionCount `json:"reactions"`
+	Entities       []Entity        `json:"entities"`
+	Poll           *Poll           `json:"poll,omitempty"`
+}
+
+// PostVersion is the editable part of a post at some point in time.
+type PostVersion struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// PostRevision records a single edit as the versions before and after it, so
+// clients can diff them directly.
+type PostRevision struct {
+	ID             int         `json:"id"`
+	PostID         int         `json:"post_id"`
+	EditorUsername string      `json:"editor_username"`
+	Before         PostVersion `json:"before"`
+	After          PostVersion `json:"after"`
+	CreateAt       string      `json:"create_at"`
 }
@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+// Notification delivery channels.
+const (
+	ChannelInApp   = "in_app"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Digest frequencies.
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// NotificationTypes lists every notification type users can configure.
+var NotificationTypes = []string{
+	NotificationMention, NotificationComment, NotificationReply, NotificationReaction,
+	NotificationRepost, NotificationMessage, NotificationFollow,
+}
+
+// NotificationChannels lists every delivery channel.
+var NotificationChannels = []string{ChannelInApp, ChannelEmail, ChannelWebhook}
+
+// QuietHours is a daily window, in the user's time zone, during which nothing
+// is pushed in real time. Notifications still reach the inbox. Start and End
+// are "HH:MM"; a window where End is before Start spans midnight.
+type QuietHours struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone"`
+}
+
+// NotificationPreferences say, per notification type, which channels deliver
+// it. Email notifications are batched into the digest.
+type NotificationPreferences struct {
+	Channels   map[string]map[string]bool `json:"channels"`
+	WebhookURL string                     `json:"webhook_url,omitempty"`
+	QuietHours *QuietHours                `json:"quiet_hours,omitempty"`
+	Digest     string                     `json:"digest"`
+}
+
+// DefaultNotificationPreferences enables the inbox and email for every type.
+// Email still needs a digest frequency to be chosen, so nothing is mailed by default.
+func DefaultNotificationPreferences() NotificationPreferences {
+	prefs := NotificationPreferences{
+		Channels: make(map[string]map[string]bool, len(NotificationTypes)),
+		Digest:   DigestOff,
+	}
+	for _, typ := range NotificationTypes {
+		prefs.Channels[typ] = map[string]bool{ChannelInApp: true, ChannelEmail: true, ChannelWebhook: false}
+	}
+	return prefs
+}
+
+func (p NotificationPreferences) Enabled(typ, channel string) bool {
+	return p.Channels[typ][channel]
+}
+
+// TypesFor returns the notification types delivered through channel.
+func (p NotificationPreferences) TypesFor(channel string) []string {
+	types := []string{}
+	for _, typ := range NotificationTypes {
+		if p.Enabled(typ, channel) {
+			types = append(types, typ)
+		}
+	}
+	return types
+}
+
+// DigestRecipient is a user whose digest is due. Since is the end of the
+// period covered by the previous digest. LastDigestAt is when the previous
+// digest was sent, nil for the first one, kept to release a failed claim.
+type DigestRecipient struct {
+	UserID       string
+	Username     string
+	Email        string
+	Since        time.Time
+	LastDigestAt *time.Time
+	Preferences  NotificationPreferences
+}
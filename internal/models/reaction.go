@@ -0,0 +1,32 @@
+package models
+
+import "slices"
+
+// Reaction targets.
+const (
+	TargetPost    = "post"
+	TargetComment = "comment"
+)
+
+// ReactionEmojis is the set of emoji users can react with.
+var ReactionEmojis = []string{"👍", "👎", "❤️", "😂", "😮", "😢", "🔥"}
+
+func IsReactionEmoji(emoji string) bool {
+	return slices.Contains(ReactionEmojis, emoji)
+}
+
+// ReactionCount is the number of reactions with one emoji on a target and
+// whether the current user is among them.
+type ReactionCount struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	Reacted bool   `json:"reacted"`
+}
+
+// Reactor is a user who reacted to a target.
+type Reactor struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Avatar   string `json:"avatar"`
+	CreateAt string `json:"create_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+// Relation kinds a user can put another user in.
+const (
+	RelationBlock = "block"
+	RelationMute  = "mute"
+)
+
+// RelatedUser is a user the current user blocked or muted.
+type RelatedUser struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Avatar   string `json:"avatar"`
+	CreateAt string `json:"create_at"`
+}
+
+// Relation describes how one user relates to another. Blocked is set when
+// either of them blocked the other; Muted only when the first muted the second.
+type Relation struct {
+	Blocked bool
+	Muted   bool
+}
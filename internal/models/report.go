@@ -0,0 +1,88 @@
+package models
+
+import "slices"
+
+// MaxReportDetailsLength is the maximum length of the free-text details of a
+// report in characters.
+const MaxReportDetailsLength = 1000
+
+// TargetUser is reported on top of the reaction targets.
+const TargetUser = "user"
+
+// ReportTargets are the things that can be reported. Messages are not among
+// them: they live outside this database, so a report could not be checked
+// against its target.
+var ReportTargets = []string{TargetPost, TargetComment, TargetUser}
+
+// ReportReasons are the reasons a report can be filed for.
+var ReportReasons = []string{"spam", "harassment", "hate", "violence", "nudity", "misinformation", "other"}
+
+func IsReportTarget(target string) bool {
+	return slices.Contains(ReportTargets, target)
+}
+
+func IsReportReason(reason string) bool {
+	return slices.Contains(ReportReasons, reason)
+}
+
+// Moderation case statuses.
+const (
+	CaseOpen     = "open"
+	CaseClaimed  = "claimed"
+	CaseResolved = "resolved"
+)
+
+// Moderation actions. The resolutions are what a moderator can close a case
+// with; the rest only appear in the audit trail.
+const (
+	ActionDismiss       = "dismiss"
+	ActionRemoveContent = "remove_content"
+	ActionSuspendUser   = "suspend_user"
+	ActionClaim         = "claim"
+	ActionAutoHide      = "auto_hide"
+	ActionAutoFlag      = "auto_flag"
+)
+
+var CaseResolutions = []string{ActionDismiss, ActionRemoveContent, ActionSuspendUser}
+
+// Report is a single complaint about a target. A user can report a target once.
+type Report struct {
+	ID         int64  `json:"id"`
+	TargetType string `json:"target_type"`
+	TargetID   string `json:"target_id"`
+	Reason     string `json:"reason"`
+	Details    string `json:"details,omitempty"`
+	ReporterID string `json:"reporter_id,omitempty"`
+	CreateAt   string `json:"create_at"`
+}
+
+// ModerationCase groups the reports of one target in the moderator queue.
+// AuthorID is the user a suspension applies to.
+type ModerationCase struct {
+	ID           int64    `json:"id"`
+	TargetType   string   `json:"target_type"`
+	TargetID     string   `json:"target_id"`
+	AuthorID     string   `json:"author_id,omitempty"`
+	Status       string   `json:"status"`
+	ReportsCount int      `json:"reports_count"`
+	Reasons      []string `json:"reasons"`
+	Hidden       bool     `json:"hidden"`
+	ModeratorID  string   `json:"moderator_id,omitempty"`
+	Resolution   string   `json:"resolution,omitempty"`
+	CreateAt     string   `json:"create_at"`
+	UpdateAt     string   `json:"update_at"`
+
+	Reports []Report           `json:"reports,omitempty"`
+	Actions []ModerationAction `json:"actions,omitempty"`
+}
+
+// ModerationAction is an entry of the audit trail of a case. ModeratorID is
+// empty for automatic actions.
+type ModerationAction struct {
+	ID          int64  `json:"id"`
+	CaseID      int64  `json:"case_id"`
+	ModeratorID string `json:"moderator_id,omitempty"`
+	Action      string `json:"action"`
+	Note        string `json:"note,omitempty"`
+	CreateAt    string `json:"create_at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+// MaxQuoteLength is the maximum length of a repost quote in characters.
+const MaxQuoteLength = 1000
+
+// Feed item types.
+const (
+	FeedPost   = "post"
+	FeedRepost = "repost"
+)
+
+// Repost is a post shared by another user, optionally with a quote. Username
+// and Avatar belong to the user who reposted, Post is the original.
+type Repost struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Avatar   string `json:"avatar"`
+	Quote    string `json:"quote,omitempty"`
+	CreateAt string `json:"create_at"`
+	Post     Post   `json:"post"`
+}
+
+// FeedItem is an entry of the feed: either a post or a repost, depending on Type.
+type FeedItem struct {
+	Type   string  `json:"type"`
+	Post   *Post   `json:"post,omitempty"`
+	Repost *Repost `json:"repost,omitempty"`
+}
+
+// ShareCounts are the repost and bookmark counters of a post and whether the
+// current user reposted or bookmarked it.
+type ShareCounts struct {
+	Reposts    int
+	Bookmarks  int
+	Reposted   bool
+	Bookmarked bool
+}
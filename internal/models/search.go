@@ -0,0 +1,27 @@
+package models
+
+// Search types accepted by GET /api/search?type=.
+const (
+	SearchPosts = "posts"
+	SearchUsers = "users"
+)
+
+// PostSearchResult is a post matching a search query. The highlight fields are
+// HTML-escaped fragments with the matched words wrapped in <mark>.
+type PostSearchResult struct {
+	Post
+	Rank             float32 `json:"rank"`
+	TitleHighlight   string  `json:"title_highlight"`
+	ContentHighlight string  `json:"content_highlight"`
+}
+
+// UserSearchResult is a user matching a search query, ranked by trigram similarity.
+type UserSearchResult struct {
+	UserID            string  `json:"user_id"`
+	Name              string  `json:"name"`
+	Username          string  `json:"username"`
+	Avatar            string  `json:"avatar"`
+	Rank              float32 `json:"rank"`
+	NameHighlight     string  `json:"name_highlight"`
+	UsernameHighlight string  `json:"username_highlight"`
+}
@@ -10,16 +10,23 @@ type User struct {
 	UserID            string    `json:"user_id"`
 	Name              string    `json:"name"`
 	Username          string    `json:"username"`
-	Email             string    `json:"email"`
-	Password          string    `json:"password"`
+	Email             string    `json:"email,omitempty"`
+	Password          string    `json:"password,omitempty"`
 	Gender            string    `json:"gender"`
 	Dob               time.Time `json:"dob"`
 	Avatar            string    `json:"avatar"`
 	Time_registration time.Time `json:"time_registration"`
+	Role              string    `json:"role,omitempty"`
 }
 
-func HashPassword(password string) (string, error) {
-	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+)
+
+// HashPassword hashes password with bcrypt at the given cost.
+func HashPassword(password string, cost int) (string, error) {
+	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
 		return "", fmt.Errorf("could not hash password: %v", err)
 	}
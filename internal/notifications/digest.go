@@ -0,0 +1,142 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log/slog"
+	"net/url"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/mail"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+)
+
+const (
+	// DigestBatchSize is how many digests are claimed at once.
+	DigestBatchSize = 50
+	// digestGroups is how many notification groups a digest lists.
+	digestGroups = 20
+
+	UnsubscribePath = "/api/notifications/unsubscribe"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var (
+	digestText = texttemplate.Must(texttemplate.ParseFS(templates, "templates/digest.txt"))
+	digestHTML = htmltemplate.Must(htmltemplate.ParseFS(templates, "templates/digest.html"))
+)
+
+type digestData struct {
+	Username       string
+	Period         string
+	Groups         []models.NotificationGroup
+	More           bool
+	InboxURL       string
+	UnsubscribeURL string
+}
+
+// Digester batches unread notifications into periodic emails.
+type Digester struct {
+	store     Store
+	mailer    mail.Mailer
+	tokens    *UnsubscribeTokens
+	publicURL string
+	log       *slog.Logger
+}
+
+// NewDigester creates a digest job. publicURL is the base of the links in the
+// emails.
+func NewDigester(store Store, mailer mail.Mailer, tokens *UnsubscribeTokens, publicURL string, log *slog.Logger) *Digester {
+	return &Digester{
+		store:     store,
+		mailer:    mailer,
+		tokens:    tokens,
+		publicURL: strings.TrimRight(publicURL, "/"),
+		log:       log,
+	}
+}
+
+// SendDue sends one batch of due digests and returns how many users were
+// processed. Users without unread notifications get no email. A digest that
+// fails to send is released, so it is sent on a later run, and the failures
+// are returned.
+func (d *Digester) SendDue(ctx context.Context) (int, error) {
+	recipients, err := d.store.ClaimDueDigests(ctx, DigestBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	for _, r := range recipients {
+		if err := d.send(ctx, r); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send digest to user %s: %v", r.UserID, err))
+			if err := d.store.ReleaseDigest(ctx, r.UserID, r.LastDigestAt); err != nil {
+				errs = append(errs, fmt.Errorf("failed to release digest of user %s: %v", r.UserID, err))
+			}
+		}
+	}
+
+	return len(recipients), errors.Join(errs...)
+}
+
+func (d *Digester) send(ctx context.Context, r models.DigestRecipient) error {
+	types := r.Preferences.TypesFor(models.ChannelEmail)
+	if len(types) == 0 || r.Email == "" {
+		return nil
+	}
+
+	query := storage.NotificationQuery{
+		Page:       pagination.Page{Limit: digestGroups, Sort: "id", Desc: true},
+		UnreadOnly: true,
+		Types:      types,
+		Since:      r.Since,
+	}
+	groups, next, err := d.store.GetNotificationGroups(ctx, r.UserID, query)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+	for i := range groups {
+		groups[i].Summary = Summarize(groups[i])
+	}
+
+	unsubscribe := d.publicURL + UnsubscribePath + "?token=" + url.QueryEscape(d.tokens.Issue(r.UserID))
+	data := digestData{
+		Username:       r.Username,
+		Period:         r.Preferences.Digest,
+		Groups:         groups,
+		More:           next != "",
+		InboxURL:       d.publicURL + "/notifications",
+		UnsubscribeURL: unsubscribe,
+	}
+
+	var text, html bytes.Buffer
+	if err := digestText.Execute(&text, data); err != nil {
+		return err
+	}
+	if err := digestHTML.Execute(&html, data); err != nil {
+		return err
+	}
+
+	return d.mailer.Send(ctx, mail.Message{
+		To:      r.Email,
+		Subject: "Your " + r.Preferences.Digest + " notification digest",
+		Text:    text.String(),
+		HTML:    html.String(),
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribe + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	})
+}
@@ -0,0 +1,40 @@
+package notifications
+
+import "github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+
+// Event is something that happened to RecipientID because of ActorID. Events
+// are built with the constructors below so every type gets the target it is
+// grouped by.
+type Event struct {
+	Type        string
+	RecipientID string
+	ActorID     string
+	TargetType  string
+	TargetID    int64
+}
+
+// Mention is emitted when a published post mentions the recipient.
+func Mention(recipientID, actorID string, postID int) Event {
+	return Event{models.NotificationMention, recipientID, actorID, models.TargetPost, int64(postID)}
+}
+
+// Comment is emitted when someone comments on a post of the recipient.
+func Comment(recipientID, actorID string, postID int) Event {
+	return Event{models.NotificationComment, recipientID, actorID, models.TargetPost, int64(postID)}
+}
+
+// Reply is emitted when someone replies to a comment of the recipient.
+func Reply(recipientID, actorID string, commentID int) Event {
+	return Event{models.NotificationReply, recipientID, actorID, models.TargetComment, int64(commentID)}
+}
+
+// Reaction is emitted when someone reacts to a post or comment of the
+// recipient.
+func Reaction(recipientID, actorID, target string, targetID int) Event {
+	return Event{models.NotificationReaction, recipientID, actorID, target, int64(targetID)}
+}
+
+// Repost is emitted when someone reposts a post of the recipient.
+func Repost(recipientID, actorID string, postID int) Event {
+	return Event{models.NotificationRepost, recipientID, actorID, models.TargetPost, int64(postID)}
+}
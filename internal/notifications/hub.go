@@ -0,0 +1,86 @@
+package notifications
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+)
+
+// subscriberBuffer is how many notifications a slow subscriber may lag behind
+// before new ones are dropped for it. Dropped notifications are still in the inbox.
+const subscriberBuffer = 16
+
+// streamTicketTTL is how long a stream ticket may wait before it is used.
+const streamTicketTTL = 30 * time.Second
+
+// Hub fans out new notifications to the open streams of their recipients.
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[string]map[chan models.Notification]struct{}
+	closed bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[string]map[chan models.Notification]struct{})}
+}
+
+// Subscribe returns a channel receiving the notifications of userID and a
+// function releasing it. The channel is closed when the hub is closed.
+func (h *Hub) Subscribe(userID string) (<-chan models.Notification, func()) {
+	ch := make(chan models.Notification, subscriberBuffer)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[chan models.Notification]struct{})
+	}
+	h.subs[userID][ch] = struct{}{}
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if _, ok := h.subs[userID][ch]; !ok {
+			return
+		}
+		delete(h.subs[userID], ch)
+		if len(h.subs[userID]) == 0 {
+			delete(h.subs, userID)
+		}
+		close(ch)
+	}
+}
+
+// Publish sends n to every stream of its recipient without blocking.
+func (h *Hub) Publish(n models.Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs[n.UserID] {
+		select {
+		case ch <- n:
+		default:
+		}
+	}
+}
+
+// Close ends all streams, so long-lived requests let the server shut down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for userID, chans := range h.subs {
+		for ch := range chans {
+			close(ch)
+		}
+		delete(h.subs, userID)
+	}
+}
@@ -0,0 +1,142 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+)
+
+func TestHubPublish(t *testing.T) {
+	tests := []struct {
+		name      string
+		subscribe string
+		publish   []string
+		want      int
+	}{
+		{name: "own notification", subscribe: "alice", publish: []string{"alice"}, want: 1},
+		{name: "other user", subscribe: "alice", publish: []string{"bob"}, want: 0},
+		{name: "mixed", subscribe: "alice", publish: []string{"alice", "bob", "alice"}, want: 2},
+		{name: "slow subscriber drops overflow", subscribe: "alice", publish: slices.Repeat([]string{"alice"}, subscriberBuffer+5), want: subscriberBuffer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHub()
+			ch, unsubscribe := h.Subscribe(tt.subscribe)
+			defer unsubscribe()
+
+			for i, userID := range tt.publish {
+				h.Publish(models.Notification{ID: int64(i + 1), UserID: userID})
+			}
+
+			if got := len(ch); got != tt.want {
+				t.Errorf("received %d notifications, want %d", got, tt.want)
+			}
+			for len(ch) > 0 {
+				if n := <-ch; n.UserID != tt.subscribe {
+					t.Errorf("received notification of %q", n.UserID)
+				}
+			}
+		})
+	}
+}
+
+func TestHubUnsubscribe(t *testing.T) {
+	h := NewHub()
+	first, unsubscribe := h.Subscribe("alice")
+	second, keep := h.Subscribe("alice")
+	defer keep()
+
+	unsubscribe()
+	unsubscribe() // releasing twice is harmless
+
+	if _, ok := <-first; ok {
+		t.Fatal("channel still open after unsubscribe")
+	}
+
+	h.Publish(models.Notification{ID: 1, UserID: "alice"})
+	if n := <-second; n.ID != 1 {
+		t.Errorf("other stream got notification %d, want 1", n.ID)
+	}
+}
+
+func TestHubClose(t *testing.T) {
+	h := NewHub()
+	ch, unsubscribe := h.Subscribe("alice")
+
+	h.Close()
+	if _, ok := <-ch; ok {
+		t.Fatal("channel still open after close")
+	}
+	unsubscribe() // must not close the channel again
+
+	late, _ := h.Subscribe("alice")
+	if _, ok := <-late; ok {
+		t.Fatal("subscribing to a closed hub returned an open channel")
+	}
+}
+
+func TestEmitPublishes(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    Event
+		relation models.Relation
+		want     bool
+	}{
+		{name: "comment", event: Comment("alice", "bob", 1), want: true},
+		{name: "own action", event: Comment("alice", "alice", 1), want: false},
+		{name: "no recipient", event: Comment("", "bob", 1), want: false},
+		{name: "blocked actor", event: Comment("alice", "bob", 1), relation: models.Relation{Blocked: true}, want: false},
+		{name: "muted actor", event: Comment("alice", "bob", 1), relation: models.Relation{Muted: true}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{relation: tt.relation}
+			s := NewService(store, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			defer s.Close()
+
+			ch, unsubscribe := s.Subscribe("alice")
+			defer unsubscribe()
+
+			if err := s.Emit(context.Background(), tt.event); err != nil {
+				t.Fatalf("Emit: %v", err)
+			}
+
+			if got := len(ch) == 1; got != tt.want {
+				t.Errorf("published = %v, want %v", got, tt.want)
+			}
+			if got := len(store.created) == 1; got != tt.want {
+				t.Errorf("stored = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeStore keeps notifications in memory. Methods the tests don't reach
+// panic through the nil embedded Store.
+type fakeStore struct {
+	Store
+	relation models.Relation
+	created  []models.Notification
+}
+
+func (f *fakeStore) GetRelation(ctx context.Context, userID, otherID string) (models.Relation, error) {
+	return f.relation, nil
+}
+
+func (f *fakeStore) GetNotificationPreferences(ctx context.Context, userID string) (models.NotificationPreferences, error) {
+	return models.NotificationPreferences{}, fmt.Errorf("preferences of %s: %w", userID, storage.ErrNotFound)
+}
+
+func (f *fakeStore) CreateNotification(ctx context.Context, n *models.Notification) error {
+	n.ID = int64(len(f.created) + 1)
+	f.created = append(f.created, *n)
+	return nil
+}
@@ -0,0 +1,210 @@
+// Package notifications stores the events that concern a user, groups them
+// into an inbox and pushes new ones to the user's open streams.
+package notifications
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/content"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/google/uuid"
+)
+
+// Emitter is how producers in the service layer report events. Tests can
+// replace it with an in-memory fake recording the events.
+type Emitter interface {
+	Emit(ctx context.Context, e Event) error
+}
+
+// Inbox is the read side used by the HTTP handlers.
+type Inbox interface {
+	List(ctx context.Context, userID string, query storage.NotificationQuery) (models.NotificationInbox, string, error)
+	UnreadCount(ctx context.Context, userID string) (int, error)
+	MarkRead(ctx context.Context, userID string, ids []int64) (int, error)
+	MarkAllRead(ctx context.Context, userID string) (int, error)
+	Subscribe(userID string) (<-chan models.Notification, func())
+	StreamTicket(ctx context.Context, userID string) (string, error)
+	RedeemStreamTicket(ctx context.Context, ticket string) (string, error)
+	Preferences(ctx context.Context, userID string) (models.NotificationPreferences, error)
+	UpdatePreferences(ctx context.Context, userID string, prefs *models.NotificationPreferences) error
+	Unsubscribe(ctx context.Context, token string) error
+}
+
+// Store persists notifications.
+type Store interface {
+	CreateNotification(ctx context.Context, n *models.Notification) error
+	GetNotificationGroups(ctx context.Context, userID string, query storage.NotificationQuery) ([]models.NotificationGroup, string, error)
+	CountUnreadNotifications(ctx context.Context, userID string, types []string) (int, error)
+	MarkNotificationsRead(ctx context.Context, userID string, ids []int64) (int, error)
+	MarkAllNotificationsRead(ctx context.Context, userID string) (int, error)
+	GetNotificationPreferences(ctx context.Context, userID string) (models.NotificationPreferences, error)
+	SaveNotificationPreferences(ctx context.Context, userID string, prefs models.NotificationPreferences) error
+	DisableDigest(ctx context.Context, userID string) error
+	ClaimDueDigests(ctx context.Context, limit int) ([]models.DigestRecipient, error)
+	ReleaseDigest(ctx context.Context, userID string, lastDigestAt *time.Time) error
+	CreateStreamTicket(ctx context.Context, ticket, userID string, ttl time.Duration) error
+	RedeemStreamTicket(ctx context.Context, ticket string) (string, error)
+	GetRelation(ctx context.Context, userID, otherID string) (models.Relation, error)
+}
+
+type Service struct {
+	store    Store
+	hub      *Hub
+	tokens   *UnsubscribeTokens
+	webhooks *http.Client
+	log      *slog.Logger
+
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func NewService(store Store, tokens *UnsubscribeTokens, log *slog.Logger) *Service {
+	return &Service{
+		store:    store,
+		hub:      NewHub(),
+		tokens:   tokens,
+		webhooks: content.PublicHTTPClient(webhookTimeout),
+		log:      log,
+	}
+}
+
+// Emit stores the event as a notification of its recipient and delivers it
+// through the channels the recipient enabled for its type. Users are never
+// notified about their own actions, and nothing is pushed in quiet hours.
+func (s *Service) Emit(ctx context.Context, e Event) error {
+	if e.RecipientID == "" || e.RecipientID == e.ActorID {
+		return nil
+	}
+
+	// Nothing from users the recipient blocked, muted or was blocked by.
+	if e.ActorID != "" {
+		rel, err := s.store.GetRelation(ctx, e.RecipientID, e.ActorID)
+		if err != nil {
+			return err
+		}
+		if rel.Blocked || rel.Muted {
+			return nil
+		}
+	}
+
+	prefs, err := s.Preferences(ctx, e.RecipientID)
+	if err != nil {
+		s.log.ErrorContext(ctx, "Emit: failed to load preferences, using defaults", "userID", e.RecipientID, "err", err)
+		prefs = models.DefaultNotificationPreferences()
+	}
+
+	inApp := prefs.Enabled(e.Type, models.ChannelInApp)
+	webhook := prefs.Enabled(e.Type, models.ChannelWebhook) && prefs.WebhookURL != ""
+	// Email-only notifications are stored too: the digest is built from them.
+	if !inApp && !webhook && !prefs.Enabled(e.Type, models.ChannelEmail) {
+		return nil
+	}
+
+	n := models.Notification{
+		UserID:     e.RecipientID,
+		Type:       e.Type,
+		ActorID:    e.ActorID,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+	}
+	if err := s.store.CreateNotification(ctx, &n); err != nil {
+		return err
+	}
+
+	if inQuietHours(prefs, time.Now()) {
+		return nil
+	}
+	if inApp {
+		s.hub.Publish(n)
+	}
+	if webhook {
+		s.sendWebhook(prefs.WebhookURL, n)
+	}
+
+	return nil
+}
+
+// List returns a page of the inbox. Types with the in-app channel turned off
+// are left out.
+func (s *Service) List(ctx context.Context, userID string, query storage.NotificationQuery) (models.NotificationInbox, string, error) {
+	prefs, err := s.Preferences(ctx, userID)
+	if err != nil {
+		return models.NotificationInbox{}, "", err
+	}
+	query.Types = prefs.TypesFor(models.ChannelInApp)
+
+	groups, next, err := s.store.GetNotificationGroups(ctx, userID, query)
+	if err != nil {
+		return models.NotificationInbox{}, "", err
+	}
+
+	unread, err := s.store.CountUnreadNotifications(ctx, userID, query.Types)
+	if err != nil {
+		return models.NotificationInbox{}, "", err
+	}
+
+	for i := range groups {
+		groups[i].Summary = Summarize(groups[i])
+	}
+
+	return models.NotificationInbox{Unread: unread, Groups: groups}, next, nil
+}
+
+func (s *Service) UnreadCount(ctx context.Context, userID string) (int, error) {
+	prefs, err := s.Preferences(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.store.CountUnreadNotifications(ctx, userID, prefs.TypesFor(models.ChannelInApp))
+}
+
+// MarkRead marks the given notifications of userID read and returns how many
+// were unread. Ids of other users' notifications are ignored.
+func (s *Service) MarkRead(ctx context.Context, userID string, ids []int64) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return s.store.MarkNotificationsRead(ctx, userID, ids)
+}
+
+func (s *Service) MarkAllRead(ctx context.Context, userID string) (int, error) {
+	return s.store.MarkAllNotificationsRead(ctx, userID)
+}
+
+func (s *Service) Subscribe(userID string) (<-chan models.Notification, func()) {
+	return s.hub.Subscribe(userID)
+}
+
+// StreamTicket issues a single-use ticket opening one stream of userID.
+// EventSource cannot send the session header, and a session in the URL would
+// end up in access logs and browser history.
+func (s *Service) StreamTicket(ctx context.Context, userID string) (string, error) {
+	ticket := uuid.New().String()
+	if err := s.store.CreateStreamTicket(ctx, ticket, userID, streamTicketTTL); err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// RedeemStreamTicket spends ticket and returns the user it was issued to.
+func (s *Service) RedeemStreamTicket(ctx context.Context, ticket string) (string, error) {
+	return s.store.RedeemStreamTicket(ctx, ticket)
+}
+
+// Close ends all open streams and waits for webhooks in flight.
+func (s *Service) Close() {
+	s.hub.Close()
+
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
@@ -0,0 +1,134 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+)
+
+var ErrInvalidPreferences = errors.New("invalid notification preferences")
+
+// quietTimeLayout is the format of QuietHours.Start and End.
+const quietTimeLayout = "15:04"
+
+// ValidatePreferences checks prefs and fills in the types and channels left
+// out with their defaults.
+func ValidatePreferences(prefs *models.NotificationPreferences) error {
+	defaults := models.DefaultNotificationPreferences()
+
+	for typ, channels := range prefs.Channels {
+		if !slices.Contains(models.NotificationTypes, typ) {
+			return fmt.Errorf("%w: unknown notification type %q", ErrInvalidPreferences, typ)
+		}
+		for channel, enabled := range channels {
+			if !slices.Contains(models.NotificationChannels, channel) {
+				return fmt.Errorf("%w: unknown channel %q", ErrInvalidPreferences, channel)
+			}
+			defaults.Channels[typ][channel] = enabled
+		}
+	}
+	prefs.Channels = defaults.Channels
+
+	switch prefs.Digest {
+	case "":
+		prefs.Digest = models.DigestOff
+	case models.DigestOff, models.DigestDaily, models.DigestWeekly:
+	default:
+		return fmt.Errorf("%w: digest must be off, daily or weekly", ErrInvalidPreferences)
+	}
+
+	if prefs.WebhookURL != "" {
+		u, err := url.Parse(prefs.WebhookURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("%w: webhook_url must be an https URL", ErrInvalidPreferences)
+		}
+	}
+
+	if q := prefs.QuietHours; q != nil {
+		if _, err := time.Parse(quietTimeLayout, q.Start); err != nil {
+			return fmt.Errorf("%w: quiet hours start must be HH:MM", ErrInvalidPreferences)
+		}
+		if _, err := time.Parse(quietTimeLayout, q.End); err != nil {
+			return fmt.Errorf("%w: quiet hours end must be HH:MM", ErrInvalidPreferences)
+		}
+		if q.Timezone == "" {
+			q.Timezone = "UTC"
+		}
+		if _, err := time.LoadLocation(q.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown time zone %q", ErrInvalidPreferences, q.Timezone)
+		}
+	}
+
+	return nil
+}
+
+// inQuietHours reports whether t falls into the quiet hours of prefs.
+func inQuietHours(prefs models.NotificationPreferences, t time.Time) bool {
+	q := prefs.QuietHours
+	if q == nil {
+		return false
+	}
+
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return false
+	}
+	start, err1 := time.Parse(quietTimeLayout, q.Start)
+	end, err2 := time.Parse(quietTimeLayout, q.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	local := t.In(loc)
+	now := local.Hour()*60 + local.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+
+	if from <= to {
+		return now >= from && now < to
+	}
+	return now >= from || now < to
+}
+
+// Preferences returns the preferences of userID, falling back to the defaults
+// for users who never saved any.
+func (s *Service) Preferences(ctx context.Context, userID string) (models.NotificationPreferences, error) {
+	prefs, err := s.store.GetNotificationPreferences(ctx, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return models.DefaultNotificationPreferences(), nil
+	}
+	if err != nil {
+		return prefs, err
+	}
+
+	// Saved preferences may predate newer notification types.
+	if err := ValidatePreferences(&prefs); err != nil {
+		return prefs, err
+	}
+
+	return prefs, nil
+}
+
+func (s *Service) UpdatePreferences(ctx context.Context, userID string, prefs *models.NotificationPreferences) error {
+	if err := ValidatePreferences(prefs); err != nil {
+		return err
+	}
+
+	return s.store.SaveNotificationPreferences(ctx, userID, *prefs)
+}
+
+// Unsubscribe turns the email digest off for the user the token was issued to.
+func (s *Service) Unsubscribe(ctx context.Context, token string) error {
+	userID, err := s.tokens.Verify(token)
+	if err != nil {
+		return err
+	}
+
+	return s.store.DisableDigest(ctx, userID)
+}
@@ -0,0 +1,46 @@
+package notifications
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+)
+
+func TestInQuietHours(t *testing.T) {
+	at := func(hhmm string) time.Time {
+		t, _ := time.Parse("2006-01-02 15:04", "2024-03-10 "+hhmm)
+		return t
+	}
+	quiet := func(start, end, tz string) *models.QuietHours {
+		return &models.QuietHours{Start: start, End: end, Timezone: tz}
+	}
+
+	tests := []struct {
+		name  string
+		quiet *models.QuietHours
+		at    time.Time
+		want  bool
+	}{
+		{name: "no quiet hours", quiet: nil, at: at("03:00"), want: false},
+		{name: "inside daytime range", quiet: quiet("13:00", "15:00", "UTC"), at: at("14:00"), want: true},
+		{name: "start is inclusive", quiet: quiet("13:00", "15:00", "UTC"), at: at("13:00"), want: true},
+		{name: "end is exclusive", quiet: quiet("13:00", "15:00", "UTC"), at: at("15:00"), want: false},
+		{name: "overnight before midnight", quiet: quiet("22:00", "07:00", "UTC"), at: at("23:30"), want: true},
+		{name: "overnight after midnight", quiet: quiet("22:00", "07:00", "UTC"), at: at("06:59"), want: true},
+		{name: "overnight outside", quiet: quiet("22:00", "07:00", "UTC"), at: at("12:00"), want: false},
+		{name: "time zone", quiet: quiet("22:00", "07:00", "Europe/Moscow"), at: at("20:00"), want: true},
+		{name: "time zone outside", quiet: quiet("22:00", "07:00", "Europe/Moscow"), at: at("05:00"), want: false},
+		{name: "unknown time zone", quiet: quiet("00:00", "23:59", "Nowhere/City"), at: at("12:00"), want: false},
+		{name: "malformed time", quiet: quiet("10pm", "07:00", "UTC"), at: at("23:00"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefs := models.NotificationPreferences{QuietHours: tt.quiet}
+			if got := inQuietHours(prefs, tt.at); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package notifications
+
+import (
+	"fmt"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+)
+
+// Summarize describes a group in one line, e.g. "alice and 3 others reacted
+// to your post".
+func Summarize(g models.NotificationGroup) string {
+	return actorsPhrase(g) + " " + verbPhrase(g)
+}
+
+func actorsPhrase(g models.NotificationGroup) string {
+	switch {
+	case len(g.Actors) == 0:
+		return "Someone"
+	case g.ActorsCount <= 1:
+		return g.Actors[0]
+	case g.ActorsCount == 2 && len(g.Actors) > 1:
+		return g.Actors[0] + " and " + g.Actors[1]
+	case g.ActorsCount == 2:
+		return g.Actors[0] + " and 1 other"
+	default:
+		return fmt.Sprintf("%s and %d others", g.Actors[0], g.ActorsCount-1)
+	}
+}
+
+func verbPhrase(g models.NotificationGroup) string {
+	switch g.Type {
+	case models.NotificationMention:
+		return "mentioned you in a post"
+	case models.NotificationComment:
+		return "commented on your post"
+	case models.NotificationReply:
+		return "replied to your comment"
+	case models.NotificationReaction:
+		return "reacted to your " + g.TargetType
+	case models.NotificationRepost:
+		return "reposted your post"
+	case models.NotificationMessage:
+		return "sent you a message"
+	case models.NotificationFollow:
+		return "followed you"
+	default:
+		return g.Type
+	}
+}
@@ -0,0 +1,59 @@
+package notifications
+
+import (
+	"testing"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+)
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name  string
+		group models.NotificationGroup
+		want  string
+	}{
+		{
+			name:  "no actors",
+			group: models.NotificationGroup{Type: models.NotificationMention},
+			want:  "Someone mentioned you in a post",
+		},
+		{
+			name:  "one actor",
+			group: models.NotificationGroup{Type: models.NotificationComment, Actors: []string{"alice"}, ActorsCount: 1},
+			want:  "alice commented on your post",
+		},
+		{
+			name:  "two actors",
+			group: models.NotificationGroup{Type: models.NotificationReply, Actors: []string{"alice", "bob"}, ActorsCount: 2},
+			want:  "alice and bob replied to your comment",
+		},
+		{
+			name:  "two actors, one known",
+			group: models.NotificationGroup{Type: models.NotificationRepost, Actors: []string{"alice"}, ActorsCount: 2},
+			want:  "alice and 1 other reposted your post",
+		},
+		{
+			name:  "many actors",
+			group: models.NotificationGroup{Type: models.NotificationReaction, TargetType: models.TargetComment, Actors: []string{"alice", "bob"}, ActorsCount: 4},
+			want:  "alice and 3 others reacted to your comment",
+		},
+		{
+			name:  "follow",
+			group: models.NotificationGroup{Type: models.NotificationFollow, Actors: []string{"alice"}, ActorsCount: 1},
+			want:  "alice followed you",
+		},
+		{
+			name:  "unknown type",
+			group: models.NotificationGroup{Type: "poke", Actors: []string{"alice"}, ActorsCount: 1},
+			want:  "alice poke",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Summarize(tt.group); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package notifications
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidToken = errors.New("invalid unsubscribe token")
+
+// UnsubscribeTokens issues the tokens of the one-click unsubscribe links in
+// digest emails. A token is the user id and its HMAC, so no state is stored.
+type UnsubscribeTokens struct {
+	key []byte
+}
+
+// NewUnsubscribeTokens creates tokens signed with key. An empty key is
+// replaced by a random one, which invalidates sent links on restart.
+func NewUnsubscribeTokens(key string) *UnsubscribeTokens {
+	secret := []byte(key)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+	return &UnsubscribeTokens{key: secret}
+}
+
+func (t *UnsubscribeTokens) sign(userID string) []byte {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte("unsubscribe:" + userID))
+	return mac.Sum(nil)
+}
+
+func (t *UnsubscribeTokens) Issue(userID string) string {
+	return userID + "." + base64.RawURLEncoding.EncodeToString(t.sign(userID))
+}
+
+// Verify returns the user the token was issued to.
+func (t *UnsubscribeTokens) Verify(token string) (string, error) {
+	userID, sig, ok := strings.Cut(token, ".")
+	if !ok || userID == "" {
+		return "", ErrInvalidToken
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, t.sign(userID)) {
+		return "", ErrInvalidToken
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,51 @@
+package notifications
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUnsubscribeTokens(t *testing.T) {
+	tokens := NewUnsubscribeTokens("secret")
+	valid := tokens.Issue("5b8f4f0e-7c1a-4d5e-9a0b-2f3c4d5e6f70")
+	other := NewUnsubscribeTokens("other secret").Issue("5b8f4f0e-7c1a-4d5e-9a0b-2f3c4d5e6f70")
+	_, sig, _ := strings.Cut(valid, ".")
+
+	tests := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr bool
+	}{
+		{name: "valid", token: valid, want: "5b8f4f0e-7c1a-4d5e-9a0b-2f3c4d5e6f70"},
+		{name: "empty", token: "", wantErr: true},
+		{name: "no signature", token: "5b8f4f0e-7c1a-4d5e-9a0b-2f3c4d5e6f70", wantErr: true},
+		{name: "no user", token: "." + sig, wantErr: true},
+		{name: "signature of another user", token: "someone-else." + sig, wantErr: true},
+		{name: "other key", token: other, wantErr: true},
+		{name: "not base64", token: "user.!!!", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tokens.Verify(tt.token)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("got (%q, %v), want ErrInvalidToken", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got (%q, %v), want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnsubscribeTokensRandomKey(t *testing.T) {
+	token := NewUnsubscribeTokens("").Issue("user")
+	if _, err := NewUnsubscribeTokens("").Verify(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token verified with another random key: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// sendWebhook posts n as JSON to the webhook of its recipient. It runs in the
+// background and only logs failures; webhooks are best effort.
+func (s *Service) sendWebhook(url string, n models.Notification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+		defer cancel()
+
+		if err := s.postWebhook(ctx, url, n); err != nil {
+			s.log.WarnContext(ctx, "webhook delivery failed", "userID", n.UserID, "notificationID", n.ID, "err", err)
+		}
+	}()
+}
+
+func (s *Service) postWebhook(ctx context.Context, url string, n models.Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.webhooks.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the counters of one replica in memory.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	windows   map[string]*window
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	last    time.Time
+	expires time.Time
+}
+
+type window struct {
+	start   time.Time
+	prev    int
+	curr    int
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		windows: make(map[string]*window),
+	}
+}
+
+// sweepInterval is how often idle counters are dropped.
+const sweepInterval = time.Minute
+
+func (s *MemoryStore) Allow(_ context.Context, key string, p Policy, now time.Time) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(now)
+	}
+
+	if p.Algorithm == SlidingWindow {
+		return s.allowWindow(key, p, now), nil
+	}
+	return s.allowBucket(key, p, now), nil
+}
+
+func (s *MemoryStore) allowBucket(key string, p Policy, now time.Time) Decision {
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(p.burst()), last: now}
+		s.buckets[key] = b
+	}
+
+	var d Decision
+	b.tokens, d = tokenBucket(p, b.tokens, b.last, now)
+	b.last = now
+	b.expires = now.Add(d.Reset)
+
+	return d
+}
+
+func (s *MemoryStore) allowWindow(key string, p Policy, now time.Time) Decision {
+	start := now.Truncate(p.Period)
+
+	w, ok := s.windows[key]
+	if !ok {
+		w = &window{start: start}
+		s.windows[key] = w
+	}
+	switch {
+	case w.start.Equal(start):
+	case w.start.Add(p.Period).Equal(start):
+		w.prev, w.curr, w.start = w.curr, 0, start
+	default:
+		w.prev, w.curr, w.start = 0, 0, start
+	}
+
+	d := slidingWindow(p, w.prev, w.curr, now.Sub(start))
+	if d.Allowed {
+		w.curr++
+	}
+	w.expires = start.Add(2 * p.Period)
+
+	return d
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for k, b := range s.buckets {
+		if now.After(b.expires) {
+			delete(s.buckets, k)
+		}
+	}
+	for k, w := range s.windows {
+		if now.After(w.expires) {
+			delete(s.windows, k)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Limiter applies the policies of route groups to requests.
+type Limiter struct {
+	store  Store
+	limits atomic.Pointer[limits]
+	log    *slog.Logger
+
+	// User resolves the session user of a request for the user key, empty
+	// for anonymous requests, which are then limited by IP.
+	User func(r *http.Request) string
+	// TrustedProxies is the number of reverse proxies in front of the app,
+	// each appending the address it got the request from to X-Forwarded-For.
+	// The IP key uses the address appended by the outermost of them; entries
+	// to its left come from the client and are ignored. Zero uses the address
+	// of the connection.
+	TrustedProxies int
+}
+
+// NewLimiter creates a limiter applying policies by group name and fallback
+// to groups without a policy of their own.
+func NewLimiter(store Store, policies map[string]Policy, fallback Policy, log *slog.Logger) *Limiter {
+	l := &Limiter{store: store, log: log}
+	l.SetPolicies(policies, fallback)
+	return l
+}
+
+// limits are the policies a limiter applies, swapped as a whole.
+type limits struct {
+	policies map[string]Policy
+	fallback Policy
+}
+
+// SetPolicies replaces the policies of the limiter. Requests in flight finish
+// under the old ones. Counts are kept, so a client near its old limit stays
+// near the new one.
+func (l *Limiter) SetPolicies(policies map[string]Policy, fallback Policy) {
+	l.limits.Store(&limits{policies: policies, fallback: fallback})
+}
+
+func (l *Limiter) policy(group string) Policy {
+	lim := l.limits.Load()
+	if p, ok := lim.policies[group]; ok {
+		return p
+	}
+	return lim.fallback
+}
+
+// Middleware limits the routes of group. Requests over the limit get 429 with
+// Retry-After; every response carries the RateLimit-* headers of the IETF
+// draft. When the store fails, requests are let through.
+func (l *Limiter) Middleware(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := l.policy(group)
+			key := group + ":" + l.clientKey(r, p.Key)
+
+			d, err := l.store.Allow(r.Context(), key, p, time.Now())
+			if err != nil {
+				l.log.ErrorContext(r.Context(), "rate limit store failed", "group", group, "err", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", strconv.Itoa(p.Requests)+";w="+strconv.Itoa(int(p.Period.Seconds())))
+			h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			h.Set("RateLimit-Reset", ceilSeconds(d.Reset))
+
+			if !d.Allowed {
+				h.Set("Retry-After", ceilSeconds(d.RetryAfter))
+				writeTooManyRequests(w)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey identifies the client of r. The session token is hashed so it
+// never ends up in the store. Only tokens of live sessions get a key of their
+// own: made-up tokens would otherwise get a fresh limit on every request, so
+// they are limited by IP.
+func (l *Limiter) clientKey(r *http.Request, kind string) string {
+	switch kind {
+	case KeyUser:
+		if userID := l.user(r); userID != "" {
+			return "user:" + userID
+		}
+	case KeyToken:
+		if token := r.Header.Get("Authorization"); token != "" && l.user(r) != "" {
+			sum := sha256.Sum256([]byte(token))
+			return "token:" + hex.EncodeToString(sum[:16])
+		}
+	}
+	return "ip:" + l.clientIP(r)
+}
+
+func (l *Limiter) user(r *http.Request) string {
+	if l.User == nil {
+		return ""
+	}
+	return l.User(r)
+}
+
+func (l *Limiter) clientIP(r *http.Request) string {
+	if l.TrustedProxies > 0 {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			hops := strings.Split(strings.Join(fwd, ","), ",")
+			ip := strings.TrimSpace(hops[max(len(hops)-l.TrustedProxies, 0)])
+			if ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// writeTooManyRequests answers in the error format of the API handlers.
+func writeTooManyRequests(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "Error",
+		"message": "Too many requests, try again later",
+	})
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParsePolicy reads a policy written as "requests/period" followed by
+// optional ",burst=N", ",key=ip|user|token" and ",algorithm=token_bucket|sliding_window".
+// Options that are not given keep their value in base.
+func ParsePolicy(spec string, base Policy) (Policy, error) {
+	p := base
+	parts := strings.Split(spec, ",")
+
+	requests, period, ok := strings.Cut(strings.TrimSpace(parts[0]), "/")
+	if !ok {
+		return p, fmt.Errorf("want requests/period, got %q", parts[0])
+	}
+	var err error
+	if p.Requests, err = strconv.Atoi(requests); err != nil {
+		return p, fmt.Errorf("invalid requests %q", requests)
+	}
+	if p.Period, err = time.ParseDuration(period); err != nil {
+		return p, fmt.Errorf("invalid period %q", period)
+	}
+
+	for _, opt := range parts[1:] {
+		name, val, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch name {
+		case "burst":
+			if p.Burst, err = strconv.Atoi(val); err != nil {
+				return p, fmt.Errorf("invalid burst %q", val)
+			}
+		case "key":
+			p.Key = val
+		case "algorithm":
+			p.Algorithm = val
+		default:
+			return p, fmt.Errorf("unknown option %q", name)
+		}
+	}
+
+	return p, p.Validate()
+}
+
+// String writes p in the form ParsePolicy reads.
+func (p Policy) String() string {
+	s := strconv.Itoa(p.Requests) + "/" + p.Period.String()
+	if p.Burst > 0 {
+		s += ",burst=" + strconv.Itoa(p.Burst)
+	}
+	return s + ",key=" + p.Key + ",algorithm=" + p.Algorithm
+}
+
+func (p Policy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText parses a policy with ParsePolicy, using p as the base.
+func (p *Policy) UnmarshalText(text []byte) error {
+	parsed, err := ParsePolicy(string(text), *p)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
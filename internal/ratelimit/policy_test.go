@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	base := Policy{Requests: 100, Period: time.Minute, Burst: 20, Algorithm: TokenBucket, Key: KeyIP}
+
+	tests := []struct {
+		name    string
+		spec    string
+		want    Policy
+		wantErr string
+	}{
+		{
+			name: "rate only keeps base options",
+			spec: "10/1s",
+			want: Policy{Requests: 10, Period: time.Second, Burst: 20, Algorithm: TokenBucket, Key: KeyIP},
+		},
+		{
+			name: "all options",
+			spec: "5/1m, burst=0, key=user, algorithm=sliding_window",
+			want: Policy{Requests: 5, Period: time.Minute, Algorithm: SlidingWindow, Key: KeyUser},
+		},
+		{name: "missing period", spec: "10", wantErr: "want requests/period"},
+		{name: "bad requests", spec: "ten/1m", wantErr: `invalid requests "ten"`},
+		{name: "bad period", spec: "10/minute", wantErr: `invalid period "minute"`},
+		{name: "bad burst", spec: "10/1m,burst=lots", wantErr: `invalid burst "lots"`},
+		{name: "unknown option", spec: "10/1m,window=5", wantErr: `unknown option "window"`},
+		{name: "zero requests", spec: "0/1m", wantErr: "requests must be positive"},
+		{name: "negative period", spec: "10/-1m", wantErr: "period must be positive"},
+		{name: "unknown key", spec: "10/1m,key=cookie", wantErr: `unknown key "cookie"`},
+		{name: "unknown algorithm", spec: "10/1m,algorithm=leaky", wantErr: `unknown algorithm "leaky"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePolicy(tt.spec, base)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParsePolicy(%q) error = %v, want %q", tt.spec, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePolicy(%q): %v", tt.spec, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePolicy(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyStringRoundTrip(t *testing.T) {
+	tests := []Policy{
+		{Requests: 10, Period: time.Minute, Algorithm: SlidingWindow, Key: KeyIP},
+		{Requests: 120, Period: time.Minute, Burst: 30, Algorithm: TokenBucket, Key: KeyUser},
+		{Requests: 1, Period: 90 * time.Second, Algorithm: TokenBucket, Key: KeyToken},
+	}
+
+	for _, p := range tests {
+		t.Run(p.String(), func(t *testing.T) {
+			got, err := ParsePolicy(p.String(), Policy{})
+			if err != nil {
+				t.Fatalf("ParsePolicy: %v", err)
+			}
+			if got != p {
+				t.Errorf("round trip = %+v, want %+v", got, p)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Package ratelimit limits how often a client may call a group of routes.
+// Limits are counted by a Store, in memory for a single replica or in Redis
+// when replicas share them.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Algorithms.
+const (
+	// TokenBucket allows bursts of up to Burst requests and refills at
+	// Requests per Period.
+	TokenBucket = "token_bucket"
+	// SlidingWindow allows Requests in any Period, estimated from the counts
+	// of the current and previous fixed windows.
+	SlidingWindow = "sliding_window"
+)
+
+// Keys a client is identified by.
+const (
+	KeyIP    = "ip"
+	KeyUser  = "user"
+	KeyToken = "token"
+)
+
+// Policy is the limit of one route group.
+type Policy struct {
+	Requests  int
+	Period    time.Duration
+	Burst     int
+	Algorithm string
+	Key       string
+}
+
+// burst is the bucket size of a token bucket, Requests unless set.
+func (p Policy) burst() int {
+	if p.Burst > 0 {
+		return p.Burst
+	}
+	return p.Requests
+}
+
+func (p Policy) Validate() error {
+	switch {
+	case p.Requests <= 0:
+		return fmt.Errorf("requests must be positive")
+	case p.Period <= 0:
+		return fmt.Errorf("period must be positive")
+	case p.Burst < 0:
+		return fmt.Errorf("burst must not be negative")
+	case p.Algorithm != TokenBucket && p.Algorithm != SlidingWindow:
+		return fmt.Errorf("unknown algorithm %q", p.Algorithm)
+	case p.Key != KeyIP && p.Key != KeyUser && p.Key != KeyToken:
+		return fmt.Errorf("unknown key %q", p.Key)
+	}
+	return nil
+}
+
+// Decision is the outcome of a request against a policy. Reset is when the
+// client is back to its full allowance, RetryAfter when a denied request may
+// be retried.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Store counts requests per key.
+type Store interface {
+	Allow(ctx context.Context, key string, policy Policy, now time.Time) (Decision, error)
+}
+
+// tokenBucket refills tokens for the time passed since last and takes one if
+// it can. It returns the new token count along with the decision.
+func tokenBucket(p Policy, tokens float64, last, now time.Time) (float64, Decision) {
+	capacity := float64(p.burst())
+	rate := float64(p.Requests) / p.Period.Seconds()
+
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = min(capacity, tokens+elapsed*rate)
+	}
+
+	d := Decision{Limit: p.burst()}
+	if tokens >= 1 {
+		tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	d.Remaining = int(tokens)
+	d.Reset = seconds((capacity - tokens) / rate)
+
+	return tokens, d
+}
+
+// slidingWindow weighs the count of the previous window by how much of it
+// still overlaps the sliding window. elapsed is the time since the current
+// window started.
+func slidingWindow(p Policy, prev, curr int, elapsed time.Duration) Decision {
+	weight := 1 - elapsed.Seconds()/p.Period.Seconds()
+	used := float64(prev)*weight + float64(curr)
+
+	d := Decision{Limit: p.Requests, Reset: p.Period - elapsed}
+	if used+1 <= float64(p.Requests) {
+		d.Allowed = true
+		used++
+	} else if curr+1 > p.Requests || prev == 0 {
+		d.RetryAfter = p.Period - elapsed
+	} else {
+		// Wait until enough of the previous window has slid out.
+		excess := used + 1 - float64(p.Requests)
+		d.RetryAfter = seconds(excess / float64(prev) * p.Period.Seconds())
+	}
+	d.Remaining = max(0, p.Requests-int(used+0.999))
+
+	return d
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
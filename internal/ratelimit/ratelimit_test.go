@@ -0,0 +1,167 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type step struct {
+	at         time.Duration
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+}
+
+func TestMemoryStoreAllow(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		steps  []step
+	}{
+		{
+			name:   "token bucket burst and refill",
+			policy: Policy{Requests: 2, Period: time.Second, Burst: 3, Algorithm: TokenBucket},
+			steps: []step{
+				{at: 0, allowed: true, remaining: 2},
+				{at: 0, allowed: true, remaining: 1},
+				{at: 0, allowed: true, remaining: 0},
+				{at: 0, allowed: false, remaining: 0, retryAfter: 500 * time.Millisecond},
+				{at: 500 * time.Millisecond, allowed: true, remaining: 0},
+				{at: 10 * time.Second, allowed: true, remaining: 2},
+			},
+		},
+		{
+			name:   "token bucket without burst",
+			policy: Policy{Requests: 1, Period: time.Minute, Algorithm: TokenBucket},
+			steps: []step{
+				{at: 0, allowed: true, remaining: 0},
+				{at: 30 * time.Second, allowed: false, remaining: 0, retryAfter: 30 * time.Second},
+				{at: time.Minute, allowed: true, remaining: 0},
+			},
+		},
+		{
+			name:   "sliding window",
+			policy: Policy{Requests: 4, Period: time.Minute, Algorithm: SlidingWindow},
+			steps: []step{
+				{at: 0, allowed: true, remaining: 3},
+				{at: 0, allowed: true, remaining: 2},
+				{at: 0, allowed: true, remaining: 1},
+				{at: 0, allowed: true, remaining: 0},
+				// The current window is full.
+				{at: 10 * time.Second, allowed: false, remaining: 0, retryAfter: 50 * time.Second},
+				// Half of the previous window still counts.
+				{at: 90 * time.Second, allowed: true, remaining: 1},
+				{at: 90 * time.Second, allowed: true, remaining: 0},
+				{at: 90 * time.Second, allowed: false, remaining: 0, retryAfter: 15 * time.Second},
+				{at: 105 * time.Second, allowed: true, remaining: 0},
+				// Windows older than the previous one are forgotten.
+				{at: 5 * time.Minute, allowed: true, remaining: 3},
+			},
+		},
+	}
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStore()
+			for i, st := range tt.steps {
+				d, err := s.Allow(context.Background(), "k", tt.policy, start.Add(st.at))
+				if err != nil {
+					t.Fatalf("step %d: Allow: %v", i, err)
+				}
+				if d.Allowed != st.allowed || d.Remaining != st.remaining || d.RetryAfter != st.retryAfter {
+					t.Errorf("step %d at %s: got allowed=%v remaining=%d retryAfter=%s, want allowed=%v remaining=%d retryAfter=%s",
+						i, st.at, d.Allowed, d.Remaining, d.RetryAfter, st.allowed, st.remaining, st.retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreKeys(t *testing.T) {
+	s := NewMemoryStore()
+	p := Policy{Requests: 1, Period: time.Minute, Algorithm: TokenBucket}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, key := range []string{"a", "b"} {
+		if d, _ := s.Allow(context.Background(), key, p, now); !d.Allowed {
+			t.Errorf("first request of %s denied", key)
+		}
+	}
+	if d, _ := s.Allow(context.Background(), "a", p, now); d.Allowed {
+		t.Error("second request of a allowed")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxies    int
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{name: "connection address", remoteAddr: "10.0.0.1:1234", want: "10.0.0.1"},
+		{name: "header ignored without proxies", remoteAddr: "10.0.0.1:1234", forwarded: []string{"1.2.3.4"}, want: "10.0.0.1"},
+		{name: "one proxy", proxies: 1, remoteAddr: "10.0.0.1:1234", forwarded: []string{"1.2.3.4"}, want: "1.2.3.4"},
+		{name: "spoofed entry ignored", proxies: 1, remoteAddr: "10.0.0.1:1234", forwarded: []string{"6.6.6.6, 1.2.3.4"}, want: "1.2.3.4"},
+		{name: "two proxies", proxies: 2, remoteAddr: "10.0.0.1:1234", forwarded: []string{"6.6.6.6, 1.2.3.4, 10.0.0.2"}, want: "1.2.3.4"},
+		{name: "fewer hops than proxies", proxies: 2, remoteAddr: "10.0.0.1:1234", forwarded: []string{"1.2.3.4"}, want: "1.2.3.4"},
+		{name: "several header lines", proxies: 1, remoteAddr: "10.0.0.1:1234", forwarded: []string{"6.6.6.6", "1.2.3.4"}, want: "1.2.3.4"},
+		{name: "no header behind proxy", proxies: 1, remoteAddr: "10.0.0.1:1234", want: "10.0.0.1"},
+		{name: "empty hop", proxies: 1, remoteAddr: "10.0.0.1:1234", forwarded: []string{"1.2.3.4, "}, want: "10.0.0.1"},
+		{name: "address without port", remoteAddr: "10.0.0.1", want: "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Limiter{TrustedProxies: tt.proxies}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+
+			if got := l.clientIP(r); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	p := Policy{Requests: 1, Period: time.Minute, Algorithm: TokenBucket, Key: KeyIP}
+	l := NewLimiter(NewMemoryStore(), map[string]Policy{"auth": p}, p, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	h := l.Middleware("auth")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name       string
+		wantStatus int
+		wantRetry  string
+	}{
+		{name: "first request", wantStatus: http.StatusOK},
+		{name: "over the limit", wantStatus: http.StatusTooManyRequests, wantRetry: "60"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/login", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetry {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetry)
+			}
+			if got := w.Header().Get("RateLimit-Policy"); got != "1;w=60" {
+				t.Errorf("RateLimit-Policy = %q, want 1;w=60", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript is tokenBucket run atomically in Redis. Times are in
+// milliseconds; tokens are stored as a string to keep the fraction.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local last = tonumber(state[2]) or now
+if now > last then
+	tokens = math.min(capacity, tokens + (now - last) * rate)
+end
+local allowed, retry = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+local reset = math.ceil((capacity - tokens) / rate)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.max(reset, 1))
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+// slidingWindowScript counts the request in the current window if the
+// weighted total allows it and returns the counts it decided on.
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local elapsed = tonumber(ARGV[3])
+local prev = tonumber(redis.call('GET', KEYS[1]) or '0')
+local curr = tonumber(redis.call('GET', KEYS[2]) or '0')
+local allowed = 0
+if prev * (1 - elapsed / period) + curr + 1 <= limit then
+	redis.call('INCR', KEYS[2])
+	redis.call('PEXPIRE', KEYS[2], period * 2)
+	allowed = 1
+end
+return {allowed, prev, curr}
+`)
+
+// RedisStore shares counters between replicas through Redis.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore connects to the Redis at url, e.g. redis://localhost:6379/0.
+func NewRedisStore(url string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %v", err)
+	}
+	return &RedisStore{client: redis.NewClient(opts)}, nil
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+// redisKey puts the key in a hash tag, so both windows of a sliding window
+// land on the same cluster slot.
+func redisKey(key string) string {
+	return "ratelimit:{" + key + "}"
+}
+
+func (s *RedisStore) Allow(ctx context.Context, key string, p Policy, now time.Time) (Decision, error) {
+	if p.Algorithm == SlidingWindow {
+		return s.allowWindow(ctx, key, p, now)
+	}
+	return s.allowBucket(ctx, key, p, now)
+}
+
+func (s *RedisStore) allowBucket(ctx context.Context, key string, p Policy, now time.Time) (Decision, error) {
+	rate := float64(p.Requests) / float64(p.Period.Milliseconds())
+	res, err := tokenBucketScript.Run(ctx, s.client, []string{redisKey(key)},
+		p.burst(), strconv.FormatFloat(rate, 'g', -1, 64), now.UnixMilli()).Int64Slice()
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to run token bucket: %v", err)
+	}
+
+	return Decision{
+		Allowed:    res[0] == 1,
+		Limit:      p.burst(),
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+		Reset:      time.Duration(res[3]) * time.Millisecond,
+	}, nil
+}
+
+func (s *RedisStore) allowWindow(ctx context.Context, key string, p Policy, now time.Time) (Decision, error) {
+	start := now.Truncate(p.Period)
+	prevKey := redisKey(key) + ":" + strconv.FormatInt(start.Add(-p.Period).UnixMilli(), 10)
+	currKey := redisKey(key) + ":" + strconv.FormatInt(start.UnixMilli(), 10)
+	elapsed := now.Sub(start)
+
+	res, err := slidingWindowScript.Run(ctx, s.client, []string{prevKey, currKey},
+		p.Requests, p.Period.Milliseconds(), elapsed.Milliseconds()).Int64Slice()
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to run sliding window: %v", err)
+	}
+
+	d := slidingWindow(p, int(res[1]), int(res[2]), elapsed)
+	// The script had the last word on the counter.
+	d.Allowed = res[0] == 1
+	if !d.Allowed && d.RetryAfter <= 0 {
+		d.RetryAfter = p.Period - elapsed
+	}
+
+	return d, nil
+}
+
+// Ping checks the connection to Redis for the readiness probe.
+func (s *RedisStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/filter"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/notifications"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+)
+
+func (s *Service) CreateComment(ctx context.Context, comment *models.Comment, userID string) (err error) {
+	ctx, span := tracer.Start(ctx, "service.CreateComment")
+	defer endSpan(span, &err)
+
+	postAuthorID, err := s.repo.GetVisiblePostAuthorID(ctx, comment.PostID, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.CanInteract(ctx, userID, postAuthorID); err != nil {
+		return err
+	}
+
+	// A deleted parent has no author to check or notify; the storage still
+	// decides whether it can be replied to.
+	var parentAuthorID string
+	if comment.ParentID != nil {
+		parentAuthorID, err = s.repo.GetCommentAuthorID(ctx, comment.PostID, *comment.ParentID)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		if err := s.CanInteract(ctx, userID, parentAuthorID); err != nil {
+			return err
+		}
+	}
+
+	verdict, err := s.checkContent(ctx, filter.KindComment, userID, comment.Content)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.CreateComment(ctx, comment, userID); err != nil {
+		return err
+	}
+	comment.Reactions = []models.ReactionCount{}
+
+	if verdict.Verdict == filter.Flag {
+		s.flagContent(ctx, models.TargetComment, strconv.Itoa(comment.ID), verdict)
+	}
+
+	s.notifyComment(ctx, comment, postAuthorID, parentAuthorID, userID)
+
+	return nil
+}
+
+// notifyComment tells the author of the parent comment about a reply and the
+// author of the post about a comment, notifying nobody twice.
+func (s *Service) notifyComment(ctx context.Context, comment *models.Comment, postAuthorID, parentAuthorID, userID string) {
+	if parentAuthorID != "" {
+		s.notify(ctx, notifications.Reply(parentAuthorID, userID, *comment.ParentID))
+		if parentAuthorID == postAuthorID {
+			return
+		}
+	}
+
+	s.notify(ctx, notifications.Comment(postAuthorID, userID, comment.PostID))
+}
+
+// canModifyComment reports whether userID is the author of the comment or a
+// moderator. Authors can only touch comments under posts they still see.
+func (s *Service) canModifyComment(ctx context.Context, postID, commentID int, userID string) error {
+	authorID, err := s.repo.GetCommentAuthorID(ctx, postID, commentID)
+	if err != nil {
+		return err
+	}
+	if authorID == userID {
+		_, err := s.repo.GetVisiblePostAuthorID(ctx, postID, userID)
+		return err
+	}
+
+	role, err := s.repo.GetUserRole(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if role != models.RoleModerator {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+func (s *Service) UpdateComment(ctx context.Context, comment *models.Comment, userID string) (err error) {
+	ctx, span := tracer.Start(ctx, "service.UpdateComment")
+	defer endSpan(span, &err)
+
+	if err := s.canModifyComment(ctx, comment.PostID, comment.ID, userID); err != nil {
+		return err
+	}
+
+	verdict, err := s.checkContent(ctx, filter.KindComment, userID, comment.Content)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdateComment(ctx, comment); err != nil {
+		return err
+	}
+
+	if verdict.Verdict == filter.Flag {
+		s.flagContent(ctx, models.TargetComment, strconv.Itoa(comment.ID), verdict)
+	}
+
+	comments := []models.Comment{*comment}
+	if err := s.attachCommentReactions(ctx, comments, userID); err != nil {
+		return err
+	}
+	comment.Reactions = comments[0].Reactions
+
+	return nil
+}
+
+func (s *Service) DeleteComment(ctx context.Context, postID, commentID int, userID string) (err error) {
+	ctx, span := tracer.Start(ctx, "service.DeleteComment")
+	defer endSpan(span, &err)
+
+	if err := s.canModifyComment(ctx, postID, commentID, userID); err != nil {
+		return err
+	}
+
+	return s.repo.DeleteComment(ctx, postID, commentID)
+}
+
+// GetPostComments returns a page of comment threads in display order, flat
+// with depth on every comment.
+func (s *Service) GetPostComments(ctx context.Context, postID int, query storage.CommentQuery) (_ []models.Comment, _ string, err error) {
+	ctx, span := tracer.Start(ctx, "service.GetPostComments")
+	defer endSpan(span, &err)
+
+	if _, err := s.repo.GetVisiblePostAuthorID(ctx, postID, query.ViewerID); err != nil {
+		return nil, "", err
+	}
+
+	comments, next, err := s.repo.GetPostComments(ctx, postID, query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := s.attachCommentReactions(ctx, comments, query.ViewerID); err != nil {
+		return nil, "", err
+	}
+
+	return comments, next, nil
+}
+
+// BuildCommentTree nests a flat list of comments in display order under their
+// parents. A reply whose parent is not in the list is dropped with its own
+// replies rather than shown out of its thread.
+func BuildCommentTree(flat []models.Comment) []*models.Comment {
+	byID := make(map[int]*models.Comment, len(flat))
+	roots := []*models.Comment{}
+
+	for i := range flat {
+		c := &flat[i]
+
+		if c.ParentID == nil {
+			byID[c.ID] = c
+			roots = append(roots, c)
+			continue
+		}
+		if parent, ok := byID[*c.ParentID]; ok {
+			byID[c.ID] = c
+			parent.Replies = append(parent.Replies, c)
+		}
+	}
+
+	return roots
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+)
+
+func TestBuildCommentTree(t *testing.T) {
+	// Comments are written as id or id:parent, in display order.
+	tests := []struct {
+		name string
+		flat string
+		want string
+	}{
+		{name: "empty", flat: "", want: ""},
+		{name: "roots only", flat: "1 2 3", want: "1 2 3"},
+		{name: "nested", flat: "1 2:1 3:2 4:1 5", want: "1(2(3) 4) 5"},
+		{name: "missing parent drops the thread", flat: "1 2:9 3:2 4:1", want: "1(4)"},
+		{name: "parent after child", flat: "3:2 2", want: "2"},
+		{name: "several threads", flat: "1 2:1 5 6:5 7:6", want: "1(2) 5(6(7))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderTree(BuildCommentTree(parseComments(t, tt.flat))); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func parseComments(t *testing.T, s string) []models.Comment {
+	comments := []models.Comment{}
+	for _, f := range strings.Fields(s) {
+		idStr, parentStr, hasParent := strings.Cut(f, ":")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			t.Fatalf("bad comment %q", f)
+		}
+		c := models.Comment{ID: id}
+		if hasParent {
+			parent, err := strconv.Atoi(parentStr)
+			if err != nil {
+				t.Fatalf("bad comment %q", f)
+			}
+			c.ParentID = &parent
+		}
+		comments = append(comments, c)
+	}
+	return comments
+}
+
+func renderTree(comments []*models.Comment) string {
+	parts := make([]string, len(comments))
+	for i, c := range comments {
+		parts[i] = strconv.Itoa(c.ID)
+		if len(c.Replies) > 0 {
+			parts[i] += "(" + renderTree(c.Replies) + ")"
+		}
+	}
+	return strings.Join(parts, " ")
+}
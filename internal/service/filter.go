@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/filter"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+)
+
+var ErrContentRejected = errors.New("content rejected")
+
+// checkContent runs text through the content filter. Rejected content is an
+// error; flagged content is stored and the caller hides it with flagContent.
+func (s *Service) checkContent(ctx context.Context, kind, userID, text string) (filter.Result, error) {
+	res := s.filter.Check(ctx, filter.Input{UserID: userID, Kind: kind, Text: text})
+	if res.Verdict == filter.Reject {
+		return res, fmt.Errorf("%w: %s", ErrContentRejected, res.Reason)
+	}
+	return res, nil
+}
+
+// flagContent hides flagged content pending review. Like notifications, a
+// failure here never fails the action, so it is only logged.
+func (s *Service) flagContent(ctx context.Context, target, targetID string, res filter.Result) {
+	note := res.Filter + ": " + res.Reason
+	if err := s.repo.FlagContent(ctx, target, targetID, note); err != nil {
+		s.log.ErrorContext(ctx, "failed to flag content", "target", target, "targetID", targetID, "err", err)
+	}
+}
+
+// postText is everything of a post the content filter looks at.
+func postText(post *models.Post) string {
+	parts := []string{post.Title, post.Content}
+	if post.Poll != nil {
+		for _, o := range post.Poll.Options {
+			parts = append(parts, o.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+)
+
+var ErrInvalidPoll = errors.New("poll needs 2 to 10 distinct non-empty options of up to 200 characters and a closing time after publication")
+
+// validatePoll normalizes the options of a poll created with post and checks
+// them against the limits in models.
+func validatePoll(post *models.Post) error {
+	poll := post.Poll
+	if len(poll.Options) < models.MinPollOptions || len(poll.Options) > models.MaxPollOptions {
+		return ErrInvalidPoll
+	}
+
+	seen := make(map[string]bool, len(poll.Options))
+	for i := range poll.Options {
+		text := strings.TrimSpace(poll.Options[i].Text)
+		if text == "" || utf8.RuneCountInString(text) > models.MaxPollOptionLength || seen[text] {
+			return ErrInvalidPoll
+		}
+		seen[text] = true
+		poll.Options[i] = models.PollOption{Text: text}
+	}
+
+	if poll.ClosesAt != nil {
+		opens := time.Now()
+		if post.PublishAt != nil {
+			opens = *post.PublishAt
+		}
+		if !poll.ClosesAt.After(opens) {
+			return ErrInvalidPoll
+		}
+	}
+
+	return nil
+}
+
+// Vote records the choice of userID in the poll of a post and returns the poll
+// with its results.
+func (s *Service) Vote(ctx context.Context, postID int, userID string, optionIDs []int) (_ *models.Poll, err error) {
+	ctx, span := tracer.Start(ctx, "service.Vote")
+	defer endSpan(span, &err)
+
+	slices.Sort(optionIDs)
+	optionIDs = slices.Compact(optionIDs)
+	if len(optionIDs) == 0 {
+		return nil, storage.ErrInvalidVote
+	}
+
+	if err := s.repo.Vote(ctx, postID, userID, optionIDs); err != nil {
+		return nil, err
+	}
+
+	polls, err := s.repo.GetPolls(ctx, []int{postID}, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return polls[postID], nil
+}
+
+// attachPolls fills in the polls of posts for viewerID, hiding the results of
+// polls the viewer has not voted in while they are open.
+func (s *Service) attachPolls(ctx context.Context, posts []models.Post, viewerID string) error {
+	ids := make([]int, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+	}
+
+	polls, err := s.repo.GetPolls(ctx, ids, viewerID)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		poll := polls[posts[i].ID]
+		if poll != nil && !poll.ResultsVisible() {
+			for j := range poll.Options {
+				poll.Options[j].Votes = nil
+			}
+		}
+		posts[i].Poll = poll
+	}
+
+	return nil
+}
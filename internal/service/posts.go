@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/content"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/filter"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/notifications"
+)
+
+var (
+	ErrForbidden     = errors.New("forbidden")
+	ErrInvalidFormat = errors.New("format must be plain or markdown")
+	ErrInvalidStatus = errors.New("status must be draft, scheduled or published; scheduled posts need a future publish_at")
+)
+
+// PublishBatchSize limits how many due posts a single PublishDuePosts call
+// publishes, keeping the claiming transaction short.
+const PublishBatchSize = 100
+
+func validateFormat(format string) error {
+	if format != models.FormatPlain && format != models.FormatMarkdown {
+		return ErrInvalidFormat
+	}
+	return nil
+}
+
+// validateStatus checks the status of a created or edited post. PublishAt only
+// makes sense for scheduled posts and is dropped otherwise.
+func validateStatus(post *models.Post) error {
+	switch post.Status {
+	case models.StatusDraft, models.StatusPublished:
+		post.PublishAt = nil
+	case models.StatusScheduled:
+		if post.PublishAt == nil || !post.PublishAt.After(time.Now()) {
+			return ErrInvalidStatus
+		}
+	default:
+		return ErrInvalidStatus
+	}
+	return nil
+}
+
+// canModifyPost reports whether userID is the author of the post or a moderator
+// and returns the author.
+func (s *Service) canModifyPost(ctx context.Context, postID int, userID string) (string, error) {
+	authorID, err := s.repo.GetPostAuthorID(ctx, postID)
+	if err != nil {
+		return "", err
+	}
+	if authorID == userID {
+		return authorID, nil
+	}
+
+	role, err := s.repo.GetUserRole(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if role != models.RoleModerator {
+		return "", ErrForbidden
+	}
+
+	return authorID, nil
+}
+
+func (s *Service) UpdatePost(ctx context.Context, post *models.Post, userID string) (err error) {
+	ctx, span := tracer.Start(ctx, "service.UpdatePost")
+	defer endSpan(span, &err)
+
+	if post.Format != "" {
+		if err := validateFormat(post.Format); err != nil {
+			return err
+		}
+	}
+	if post.Status != "" {
+		if err := validateStatus(post); err != nil {
+			return err
+		}
+	}
+
+	authorID, err := s.canModifyPost(ctx, post.ID, userID)
+	if err != nil {
+		return err
+	}
+
+	// Publishing a draft or moving its schedule resubmits the same text, which
+	// the duplicate filter would take for a copy of the post itself, so only
+	// changed text goes through the filter.
+	current := models.Post{ID: post.ID}
+	if err := s.repo.GetPost(ctx, &current, authorID); err != nil {
+		return err
+	}
+
+	var verdict filter.Result
+	if post.Title != current.Title || post.Content != current.Content {
+		if verdict, err = s.checkContent(ctx, filter.KindPost, userID, postText(post)); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.UpdatePost(ctx, post, userID); err != nil {
+		return err
+	}
+	s.renderer.Invalidate(post.ID)
+
+	if verdict.Verdict == filter.Flag {
+		s.flagContent(ctx, models.TargetPost, strconv.Itoa(post.ID), verdict)
+	}
+
+	// Read the post as its author so moderators can edit drafts too.
+	if err := s.GetPost(ctx, post, authorID); err != nil {
+		return err
+	}
+
+	if post.Status != models.StatusPublished {
+		return nil
+	}
+
+	return s.syncPostEntities(ctx, post, authorID)
+}
+
+func (s *Service) DeletePost(ctx context.Context, postID int, userID string) (err error) {
+	ctx, span := tracer.Start(ctx, "service.DeletePost")
+	defer endSpan(span, &err)
+
+	if _, err := s.canModifyPost(ctx, postID, userID); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeletePost(ctx, postID); err != nil {
+		return err
+	}
+	s.renderer.Invalidate(postID)
+
+	s.log.InfoContext(ctx, "post deleted", "postID", postID, "userID", userID)
+
+	return nil
+}
+
+func (s *Service) GetPostRevisions(ctx context.Context, postID int, viewerID string) (_ []models.PostRevision, err error) {
+	ctx, span := tracer.Start(ctx, "service.GetPostRevisions")
+	defer endSpan(span, &err)
+
+	return s.repo.GetPostRevisions(ctx, postID, viewerID)
+}
+
+// preparePosts fills in the fields computed on read: reaction, repost and
+// bookmark counters and polls for viewerID, the entities of the content and
+// its rendered HTML.
+func (s *Service) preparePosts(ctx context.Context, posts []models.Post, viewerID string) error {
+	if err := s.attachPostReactions(ctx, posts, viewerID); err != nil {
+		return err
+	}
+	if err := s.attachPostShares(ctx, posts, viewerID); err != nil {
+		return err
+	}
+	if err := s.attachPolls(ctx, posts, viewerID); err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].Entities = content.ParseEntities(posts[i].Content)
+		s.renderer.RenderPost(&posts[i])
+	}
+
+	return nil
+}
+
+// PublishDuePosts publishes the scheduled posts whose time has come and
+// returns how many were published. Mentions are only processed on publication,
+// so drafts never notify anyone.
+func (s *Service) PublishDuePosts(ctx context.Context) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "service.PublishDuePosts")
+	defer endSpan(span, &err)
+
+	posts, err := s.repo.PublishDuePosts(ctx, PublishBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range posts {
+		if err := s.syncPostEntities(ctx, &posts[i], posts[i].AuthorID); err != nil {
+			s.log.ErrorContext(ctx, "PublishDuePosts: failed to sync entities", "postID", posts[i].ID, "err", err)
+		}
+	}
+
+	return len(posts), nil
+}
+
+// syncPostEntities stores the hashtags and mentions of a published post and
+// notifies users mentioned for the first time.
+func (s *Service) syncPostEntities(ctx context.Context, post *models.Post, authorID string) error {
+	mentioned, err := s.repo.SetPostEntities(ctx, post.ID, content.Hashtags(post.Content), content.Mentions(post.Content))
+	if err != nil {
+		return err
+	}
+
+	s.notifyMentions(ctx, post, authorID, mentioned)
+
+	return nil
+}
+
+// notifyMentions tells the users newly mentioned in post about it.
+func (s *Service) notifyMentions(ctx context.Context, post *models.Post, authorID string, mentioned []string) {
+	for _, userID := range mentioned {
+		s.notify(ctx, notifications.Mention(userID, authorID, post.ID))
+	}
+}
+
+const (
+	DefaultTrendingWindow = 24 * time.Hour
+	MaxTrendingWindow     = 7 * 24 * time.Hour
+	MaxTrendingTags       = 50
+)
+
+// GetTrendingTags returns the hashtags used by the most posts created within
+// the window ending now.
+func (s *Service) GetTrendingTags(ctx context.Context, window time.Duration, limit int) (_ []models.TrendingTag, err error) {
+	ctx, span := tracer.Start(ctx, "service.GetTrendingTags")
+	defer endSpan(span, &err)
+
+	window = min(max(window, time.Hour), MaxTrendingWindow)
+	limit = min(max(limit, 1), MaxTrendingTags)
+
+	return s.repo.GetTrendingTags(ctx, time.Now().Add(-window), limit)
+}
+
+// FetchImage loads an image embedded in a post through the signed image proxy.
+func (s *Service) FetchImage(ctx context.Context, src, sig string) (_ *http.Response, err error) {
+	ctx, span := tracer.Start(ctx, "service.FetchImage")
+	defer endSpan(span, &err)
+
+	return s.images.Fetch(ctx, src, sig)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/notifications"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+)
+
+var ErrUnknownReaction = errors.New("unknown reaction emoji")
+
+func (s *Service) AddReaction(ctx context.Context, target string, targetID int, userID, emoji string) (err error) {
+	ctx, span := tracer.Start(ctx, "service.AddReaction")
+	defer endSpan(span, &err)
+
+	if !models.IsReactionEmoji(emoji) {
+		return ErrUnknownReaction
+	}
+
+	if err := s.repo.ReactionTargetExists(ctx, target, targetID, userID); err != nil {
+		return err
+	}
+
+	authorID, err := s.repo.GetReactionTargetAuthorID(ctx, target, targetID)
+	if err != nil {
+		return err
+	}
+	if err := s.CanInteract(ctx, userID, authorID); err != nil {
+		return err
+	}
+
+	added, err := s.repo.AddReaction(ctx, target, targetID, userID, emoji)
+	if err != nil {
+		return err
+	}
+
+	s.log.DebugContext(ctx, "reaction added", "target", target, "targetID", targetID, "emoji", emoji, "new", added)
+
+	if added {
+		s.notify(ctx, notifications.Reaction(authorID, userID, target, targetID))
+	}
+
+	return nil
+}
+
+func (s *Service) RemoveReaction(ctx context.Context, target string, targetID int, userID, emoji string) (err error) {
+	ctx, span := tracer.Start(ctx, "service.RemoveReaction")
+	defer endSpan(span, &err)
+
+	if !models.IsReactionEmoji(emoji) {
+		return ErrUnknownReaction
+	}
+
+	if _, err := s.repo.RemoveReaction(ctx, target, targetID, userID, emoji); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) GetReactionCounts(ctx context.Context, target string, targetID int, viewerID string) (_ []models.ReactionCount, err error) {
+	ctx, span := tracer.Start(ctx, "service.GetReactionCounts")
+	defer endSpan(span, &err)
+
+	if err := s.repo.ReactionTargetExists(ctx, target, targetID, viewerID); err != nil {
+		return nil, err
+	}
+
+	counts, err := s.repo.GetReactionCounts(ctx, target, []int{targetID}, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return nonNilCounts(counts[targetID]), nil
+}
+
+func (s *Service) GetReactors(ctx context.Context, target string, targetID int, emoji string, viewerID string, page pagination.Page) (_ []models.Reactor, _ string, err error) {
+	ctx, span := tracer.Start(ctx, "service.GetReactors")
+	defer endSpan(span, &err)
+
+	if !models.IsReactionEmoji(emoji) {
+		return nil, "", ErrUnknownReaction
+	}
+
+	if err := s.repo.ReactionTargetExists(ctx, target, targetID, viewerID); err != nil {
+		return nil, "", err
+	}
+
+	return s.repo.GetReactors(ctx, target, targetID, emoji, viewerID, page)
+}
+
+// attachPostReactions fills in the reaction counters of posts for viewerID.
+func (s *Service) attachPostReactions(ctx context.Context, posts []models.Post, viewerID string) error {
+	ids := make([]int, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+	}
+
+	counts, err := s.repo.GetReactionCounts(ctx, models.TargetPost, ids, viewerID)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].Reactions = nonNilCounts(counts[posts[i].ID])
+	}
+
+	return nil
+}
+
+// attachCommentReactions fills in the reaction counters of comments for viewerID.
+func (s *Service) attachCommentReactions(ctx context.Context, comments []models.Comment, viewerID string) error {
+	ids := make([]int, len(comments))
+	for i := range comments {
+		ids[i] = comments[i].ID
+	}
+
+	counts, err := s.repo.GetReactionCounts(ctx, models.TargetComment, ids, viewerID)
+	if err != nil {
+		return err
+	}
+
+	for i := range comments {
+		comments[i].Reactions = nonNilCounts(counts[comments[i].ID])
+	}
+
+	return nil
+}
+
+// nonNilCounts makes targets without reactions serialize as [] instead of null.
+func nonNilCounts(counts []models.ReactionCount) []models.ReactionCount {
+	if counts == nil {
+		return []models.ReactionCount{}
+	}
+	return counts
+}
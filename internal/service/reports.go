@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+)
+
+var (
+	ErrInvalidReport    = errors.New("invalid report")
+	ErrAlreadyReported  = errors.New("already reported")
+	ErrInvalidCaseQuery = errors.New("invalid moderation queue filter")
+)
+
+// CreateReport files a report of userID against report.TargetType and
+// report.TargetID. A user can report a target only once.
+func (s *Service) CreateReport(ctx context.Context, report *models.Report, userID string) (err error) {
+	ctx, span := tracer.Start(ctx, "service.CreateReport")
+	defer endSpan(span, &err)
+
+	report.Details = strings.TrimSpace(report.Details)
+	switch {
+	case !models.IsReportTarget(report.TargetType), !models.IsReportReason(report.Reason),
+		report.TargetID == "", utf8.RuneCountInString(report.Details) > models.MaxReportDetailsLength:
+		return ErrInvalidReport
+	case report.TargetType == models.TargetUser && report.TargetID == userID:
+		return ErrInvalidReport
+	}
+	report.ReporterID = userID
+
+	created, err := s.repo.CreateReport(ctx, report, s.reportHideThreshold)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrAlreadyReported
+	}
+
+	return nil
+}
+
+// requireModerator returns ErrForbidden unless userID is a moderator.
+func (s *Service) requireModerator(ctx context.Context, userID string) error {
+	role, err := s.repo.GetUserRole(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if role != models.RoleModerator {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (s *Service) GetModerationCases(ctx context.Context, query storage.CaseQuery, moderatorID string) (_ []models.ModerationCase, _ string, err error) {
+	ctx, span := tracer.Start(ctx, "service.GetModerationCases")
+	defer endSpan(span, &err)
+
+	if err := s.requireModerator(ctx, moderatorID); err != nil {
+		return nil, "", err
+	}
+
+	switch {
+	case query.Status != "" && query.Status != models.CaseOpen && query.Status != models.CaseClaimed && query.Status != models.CaseResolved,
+		query.TargetType != "" && !models.IsReportTarget(query.TargetType),
+		query.Reason != "" && !models.IsReportReason(query.Reason):
+		return nil, "", ErrInvalidCaseQuery
+	}
+
+	return s.repo.GetModerationCases(ctx, query)
+}
+
+func (s *Service) GetModerationCase(ctx context.Context, caseID int64, moderatorID string) (_ *models.ModerationCase, err error) {
+	ctx, span := tracer.Start(ctx, "service.GetModerationCase")
+	defer endSpan(span, &err)
+
+	if err := s.requireModerator(ctx, moderatorID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetModerationCase(ctx, caseID)
+}
+
+func (s *Service) GetModerationActions(ctx context.Context, page pagination.Page, moderatorID string) (_ []models.ModerationAction, _ string, err error) {
+	ctx, span := tracer.Start(ctx, "service.GetModerationActions")
+	defer endSpan(span, &err)
+
+	if err := s.requireModerator(ctx, moderatorID); err != nil {
+		return nil, "", err
+	}
+
+	return s.repo.GetModerationActions(ctx, page)
+}
+
+func (s *Service) ClaimCase(ctx context.Context, caseID int64, moderatorID string) (err error) {
+	ctx, span := tracer.Start(ctx, "service.ClaimCase")
+	defer endSpan(span, &err)
+
+	if err := s.requireModerator(ctx, moderatorID); err != nil {
+		return err
+	}
+
+	return s.repo.ClaimCase(ctx, caseID, moderatorID)
+}
+
+// ResolveCase closes a case with one of models.CaseResolutions. The case must
+// be open or claimed by the same moderator.
+func (s *Service) ResolveCase(ctx context.Context, caseID int64, moderatorID, action, note string) (err error) {
+	ctx, span := tracer.Start(ctx, "service.ResolveCase")
+	defer endSpan(span, &err)
+
+	if err := s.requireModerator(ctx, moderatorID); err != nil {
+		return err
+	}
+
+	return s.repo.ResolveCase(ctx, caseID, moderatorID, action, strings.TrimSpace(note))
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+)
+
+const (
+	minSearchLength = 2
+	maxSearchLength = 200
+)
+
+var ErrInvalidSearch = errors.New("search query must be between 2 and 200 characters")
+
+func normalizeSearch(query *storage.SearchQuery) error {
+	query.Text = strings.TrimSpace(query.Text)
+	if n := utf8.RuneCountInString(query.Text); n < minSearchLength || n > maxSearchLength {
+		return ErrInvalidSearch
+	}
+	return nil
+}
+
+func (s *Service) SearchPosts(ctx context.Context, query storage.SearchQuery) (_ []models.PostSearchResult, _ string, err error) {
+	ctx, span := tracer.Start(ctx, "service.SearchPosts")
+	defer endSpan(span, &err)
+
+	if err := normalizeSearch(&query); err != nil {
+		return nil, "", err
+	}
+
+	results, next, err := s.repo.SearchPosts(ctx, query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	posts := make([]models.Post, len(results))
+	for i := range results {
+		posts[i] = results[i].Post
+	}
+	if err := s.preparePosts(ctx, posts, query.ViewerID); err != nil {
+		return nil, "", err
+	}
+	for i := range results {
+		results[i].Post = posts[i]
+	}
+
+	return results, next, nil
+}
+
+func (s *Service) SearchUsers(ctx context.Context, query storage.SearchQuery) (_ []models.UserSearchResult, _ string, err error) {
+	ctx, span := tracer.Start(ctx, "service.SearchUsers")
+	defer endSpan(span, &err)
+
+	if err := normalizeSearch(&query); err != nil {
+		return nil, "", err
+	}
+
+	return s.repo.SearchUsers(ctx, query)
+}
@@ -3,39 +3,117 @@ package service
 import (
 	"context"
 	"fmt"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/content"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/filter"
 	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/notifications"
 	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
 	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
 )
 
 type ServiceIface interface {
 	Create(ctx context.Context, user *models.User) error
 	Login(ctx context.Context, username, password string) (string, error)
-	GetAllUsers() ([]models.User, error)
+	GetAllUsers(ctx context.Context, query storage.UserQuery) ([]models.User, string, error)
 	CreateDialog(ctx context.Context, userIDOne, userIDTwo string) (int64, error)
 	GetUserByID(ctx context.Context, sessionID string) (string, error)
-	GetUserDialogs(ctx context.Context, userID string) ([]models.Dialog, error)
+	GetUserDialogs(ctx context.Context, userID string, query storage.DialogQuery) ([]models.Dialog, string, error)
 	CreatePost(ctx context.Context, post *models.Post, userID string) error
-	GetAllPosts(ctx context.Context) ([]models.Post, error)
-	GetPost(ctx context.Context, post *models.Post) error
+	GetAllPosts(ctx context.Context, query storage.PostQuery) ([]models.Post, string, error)
+	GetPost(ctx context.Context, post *models.Post, viewerID string) error
+	UpdatePost(ctx context.Context, post *models.Post, userID string) error
+	DeletePost(ctx context.Context, postID int, userID string) error
+	GetPostRevisions(ctx context.Context, postID int, viewerID string) ([]models.PostRevision, error)
+	CreateComment(ctx context.Context, comment *models.Comment, userID string) error
+	UpdateComment(ctx context.Context, comment *models.Comment, userID string) error
+	DeleteComment(ctx context.Context, postID, commentID int, userID string) error
+	GetPostComments(ctx context.Context, postID int, query storage.CommentQuery) ([]models.Comment, string, error)
+	AddReaction(ctx context.Context, target string, targetID int, userID, emoji string) error
+	RemoveReaction(ctx context.Context, target string, targetID int, userID, emoji string) error
+	GetReactionCounts(ctx context.Context, target string, targetID int, viewerID string) ([]models.ReactionCount, error)
+	GetReactors(ctx context.Context, target string, targetID int, emoji string, viewerID string, page pagination.Page) ([]models.Reactor, string, error)
+	SearchPosts(ctx context.Context, query storage.SearchQuery) ([]models.PostSearchResult, string, error)
+	SearchUsers(ctx context.Context, query storage.SearchQuery) ([]models.UserSearchResult, string, error)
+	GetTrendingTags(ctx context.Context, window time.Duration, limit int) ([]models.TrendingTag, error)
+	FetchImage(ctx context.Context, src, sig string) (*http.Response, error)
+	PublishDuePosts(ctx context.Context) (int, error)
+	AddBookmark(ctx context.Context, userID string, postID int) error
+	RemoveBookmark(ctx context.Context, userID string, postID int) error
+	GetBookmarks(ctx context.Context, userID string, page pagination.Page) ([]models.Post, string, error)
+	CreateRepost(ctx context.Context, repost *models.Repost, userID string) error
+	DeleteRepost(ctx context.Context, userID string, postID int) error
+	GetFeed(ctx context.Context, query storage.FeedQuery) ([]models.FeedItem, string, error)
+	Vote(ctx context.Context, postID int, userID string, optionIDs []int) (*models.Poll, error)
+	Block(ctx context.Context, userID, targetID string) error
+	Unblock(ctx context.Context, userID, targetID string) error
+	Mute(ctx context.Context, userID, targetID string) error
+	Unmute(ctx context.Context, userID, targetID string) error
+	GetBlockedUsers(ctx context.Context, userID string, page pagination.Page) ([]models.RelatedUser, string, error)
+	GetMutedUsers(ctx context.Context, userID string, page pagination.Page) ([]models.RelatedUser, string, error)
+	CreateReport(ctx context.Context, report *models.Report, userID string) error
+	GetModerationCases(ctx context.Context, query storage.CaseQuery, moderatorID string) ([]models.ModerationCase, string, error)
+	GetModerationCase(ctx context.Context, caseID int64, moderatorID string) (*models.ModerationCase, error)
+	GetModerationActions(ctx context.Context, page pagination.Page, moderatorID string) ([]models.ModerationAction, string, error)
+	ClaimCase(ctx context.Context, caseID int64, moderatorID string) error
+	ResolveCase(ctx context.Context, caseID int64, moderatorID, action, note string) error
+}
+
+// Metrics counts user activity. It is called after the action succeeded,
+// except for Login, which counts failed attempts too.
+type Metrics interface {
+	Login(ok bool)
+	Registration()
+	PostCreated()
 }
 
 type Service struct {
-	repo storage.Storage
-	log  *slog.Logger
+	repo     storage.Storage
+	log      *slog.Logger
+	renderer *content.Renderer
+	images   *content.ImageProxy
+	notifier notifications.Emitter
+	filter   filter.Checker
+	metrics  Metrics
+	// reportHideThreshold is the number of reports that hides a post or
+	// comment pending review, zero never hides.
+	reportHideThreshold int
+	// passwordCost is the bcrypt cost of new password hashes.
+	passwordCost int
 }
 
-func NewService(repo storage.Storage, log *slog.Logger) *Service {
+func NewService(repo storage.Storage, log *slog.Logger, renderer *content.Renderer, images *content.ImageProxy, notifier notifications.Emitter, contentFilter filter.Checker, metrics Metrics, reportHideThreshold, passwordCost int) *Service {
 	return &Service{
-		repo: repo,
-		log:  log,
+		repo:                repo,
+		log:                 log,
+		renderer:            renderer,
+		images:              images,
+		notifier:            notifier,
+		filter:              contentFilter,
+		metrics:             metrics,
+		reportHideThreshold: reportHideThreshold,
+		passwordCost:        passwordCost,
+	}
+}
+
+// notify emits a notification event. A failed notification never fails the
+// action that caused it, so the error is only logged.
+func (s *Service) notify(ctx context.Context, e notifications.Event) {
+	if err := s.notifier.Emit(ctx, e); err != nil {
+		s.log.ErrorContext(ctx, "failed to emit notification", "type", e.Type, "recipientID", e.RecipientID, "err", err)
 	}
 }
 
-func (s *Service) Create(ctx context.Context, user *models.User) error {
-	hashedPassword, err := models.HashPassword(user.Password)
+func (s *Service) Create(ctx context.Context, user *models.User) (err error) {
+	ctx, span := tracer.Start(ctx, "service.Create")
+	defer endSpan(span, &err)
+
+	hashedPassword, err := models.HashPassword(user.Password, s.passwordCost)
 	if err != nil {
-		fmt.Printf("ошибка при хэшировании пароля: %v\n", err)
+		s.log.ErrorContext(ctx, "Create: failed to hash password", "err", err)
 		return err
 	}
 	user.Password = hashedPassword
@@ -44,33 +122,48 @@ func (s *Service) Create(ctx context.Context, user *models.User) error {
 	if err != nil {
 		return err
 	}
+	s.metrics.Registration()
 
 	return nil
 }
-func (s *Service) Login(ctx context.Context, username, password string) (string, error) {
-	s.log.Debug("Login", "starting login attempt", "username", username)
+func (s *Service) Login(ctx context.Context, username, password string) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "service.Login")
+	defer endSpan(span, &err)
+
+	s.log.DebugContext(ctx, "Login: starting login attempt", "username", username)
 
 	sessionID, err := s.repo.Login(ctx, username, password)
+	s.metrics.Login(err == nil)
 	if err != nil {
-		s.log.Error("Login", "error during login", "username", username, "error", err)
+		s.log.ErrorContext(ctx, "Login: error during login", "username", username, "error", err)
 		return "", fmt.Errorf("не удалось выполнить вход: %v", err)
 	}
 
-	s.log.Info("Login", "user authenticated successfully", "username", username)
+	s.log.InfoContext(ctx, "Login: user authenticated successfully", "username", username)
 
 	return sessionID, nil
 }
 
-func (s *Service) GetAllUsers() ([]models.User, error) {
-	res, err := s.repo.GetAllUsers()
+func (s *Service) GetAllUsers(ctx context.Context, query storage.UserQuery) (_ []models.User, _ string, err error) {
+	ctx, span := tracer.Start(ctx, "service.GetAllUsers")
+	defer endSpan(span, &err)
+
+	res, next, err := s.repo.GetAllUsers(ctx, query)
 	if err != nil {
-		return []models.User{}, err
+		return []models.User{}, "", err
 	}
 
-	return res, nil
+	return res, next, nil
 }
 
-func (s *Service) CreateDialog(ctx context.Context, userIDOne, userIDTwo string) (int64, error) {
+func (s *Service) CreateDialog(ctx context.Context, userIDOne, userIDTwo string) (_ int64, err error) {
+	ctx, span := tracer.Start(ctx, "service.CreateDialog")
+	defer endSpan(span, &err)
+
+	if err := s.CanInteract(ctx, userIDOne, userIDTwo); err != nil {
+		return 0, err
+	}
+
 	dialogID, err := s.repo.CreateDialog(ctx, userIDOne, userIDTwo)
 	if err != nil {
 		return 0, err
@@ -79,7 +172,10 @@ func (s *Service) CreateDialog(ctx context.Context, userIDOne, userIDTwo string)
 	return dialogID, nil
 }
 
-func (s *Service) GetUserByID(ctx context.Context, sessionID string) (string, error) {
+func (s *Service) GetUserByID(ctx context.Context, sessionID string) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "service.GetUserByID")
+	defer endSpan(span, &err)
+
 	userID, err := s.repo.GetUserByID(ctx, sessionID)
 	if err != nil {
 		return "", err
@@ -88,38 +184,89 @@ func (s *Service) GetUserByID(ctx context.Context, sessionID string) (string, er
 	return userID, nil
 }
 
-func (s *Service) GetUserDialogs(ctx context.Context, userID string) ([]models.Dialog, error) {
-	users, err := s.repo.GetUserDialogs(ctx, userID)
+func (s *Service) GetUserDialogs(ctx context.Context, userID string, query storage.DialogQuery) (_ []models.Dialog, _ string, err error) {
+	ctx, span := tracer.Start(ctx, "service.GetUserDialogs")
+	defer endSpan(span, &err)
+
+	dialogs, next, err := s.repo.GetUserDialogs(ctx, userID, query)
 	if err != nil {
-		return []models.Dialog{}, err
+		return []models.Dialog{}, "", err
 	}
 
-	return users, nil
+	return dialogs, next, nil
 }
 
-func (s *Service) CreatePost(ctx context.Context, post *models.Post, userID string) error {
-	err := s.repo.CreatePost(ctx, post, userID)
+func (s *Service) CreatePost(ctx context.Context, post *models.Post, userID string) (err error) {
+	ctx, span := tracer.Start(ctx, "service.CreatePost")
+	defer endSpan(span, &err)
+
+	if post.Format == "" {
+		post.Format = models.FormatPlain
+	}
+	if err := validateFormat(post.Format); err != nil {
+		return err
+	}
+	if post.Status == "" {
+		post.Status = models.StatusPublished
+	}
+	if err := validateStatus(post); err != nil {
+		return err
+	}
+	if post.Poll != nil {
+		if err := validatePoll(post); err != nil {
+			return err
+		}
+	}
+
+	verdict, err := s.checkContent(ctx, filter.KindPost, userID, postText(post))
+	if err != nil {
+		return err
+	}
+
+	mentioned, err := s.repo.CreatePost(ctx, post, userID, content.Hashtags(post.Content), content.Mentions(post.Content))
 	if err != nil {
 		return err
 	}
+	s.metrics.PostCreated()
+
+	if verdict.Verdict == filter.Flag {
+		s.flagContent(ctx, models.TargetPost, strconv.Itoa(post.ID), verdict)
+	}
+
+	s.notifyMentions(ctx, post, userID, mentioned)
 
 	return nil
 }
 
-func (s *Service) GetAllPosts(ctx context.Context) ([]models.Post, error) {
-	posts, err := s.repo.GetAllPosts(ctx)
+func (s *Service) GetAllPosts(ctx context.Context, query storage.PostQuery) (_ []models.Post, _ string, err error) {
+	ctx, span := tracer.Start(ctx, "service.GetAllPosts")
+	defer endSpan(span, &err)
+
+	posts, next, err := s.repo.GetAllPosts(ctx, query)
 	if err != nil {
-		return []models.Post{}, err
+		return []models.Post{}, "", err
 	}
 
-	return posts, nil
+	if err := s.preparePosts(ctx, posts, query.ViewerID); err != nil {
+		return []models.Post{}, "", err
+	}
+
+	return posts, next, nil
 }
 
-func (s *Service) GetPost(ctx context.Context, post *models.Post) error {
-	err := s.repo.GetPost(ctx, post)
-	if err != nil {
+func (s *Service) GetPost(ctx context.Context, post *models.Post, viewerID string) (err error) {
+	ctx, span := tracer.Start(ctx, "service.GetPost")
+	defer endSpan(span, &err)
+
+	if err := s.repo.GetPost(ctx, post, viewerID); err != nil {
+		return err
+	}
+
+	posts := []models.Post{*post}
+	if err := s.preparePosts(ctx, posts, viewerID); err != nil {
 		return err
 	}
+	*post = posts[0]
 
 	return nil
 }
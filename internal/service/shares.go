@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/notifications"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+)
+
+var (
+	ErrAlreadyReposted = errors.New("post already reposted")
+	ErrQuoteTooLong    = errors.New("quote is too long")
+)
+
+func (s *Service) AddBookmark(ctx context.Context, userID string, postID int) (err error) {
+	ctx, span := tracer.Start(ctx, "service.AddBookmark")
+	defer endSpan(span, &err)
+
+	return s.repo.AddBookmark(ctx, userID, postID)
+}
+
+func (s *Service) RemoveBookmark(ctx context.Context, userID string, postID int) (err error) {
+	ctx, span := tracer.Start(ctx, "service.RemoveBookmark")
+	defer endSpan(span, &err)
+
+	return s.repo.RemoveBookmark(ctx, userID, postID)
+}
+
+func (s *Service) GetBookmarks(ctx context.Context, userID string, page pagination.Page) (_ []models.Post, _ string, err error) {
+	ctx, span := tracer.Start(ctx, "service.GetBookmarks")
+	defer endSpan(span, &err)
+
+	posts, next, err := s.repo.GetBookmarks(ctx, userID, page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := s.preparePosts(ctx, posts, userID); err != nil {
+		return nil, "", err
+	}
+
+	return posts, next, nil
+}
+
+// CreateRepost shares the post repost.Post.ID on behalf of userID and fills in
+// the repost with the original post as the user sees it.
+func (s *Service) CreateRepost(ctx context.Context, repost *models.Repost, userID string) (err error) {
+	ctx, span := tracer.Start(ctx, "service.CreateRepost")
+	defer endSpan(span, &err)
+
+	repost.Quote = strings.TrimSpace(repost.Quote)
+	if utf8.RuneCountInString(repost.Quote) > models.MaxQuoteLength {
+		return ErrQuoteTooLong
+	}
+
+	authorID, err := s.repo.GetVisiblePostAuthorID(ctx, repost.Post.ID, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.CanInteract(ctx, userID, authorID); err != nil {
+		return err
+	}
+
+	created, err := s.repo.CreateRepost(ctx, repost, userID)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrAlreadyReposted
+	}
+
+	if err := s.GetPost(ctx, &repost.Post, userID); err != nil {
+		return err
+	}
+
+	s.notify(ctx, notifications.Repost(repost.Post.AuthorID, userID, repost.Post.ID))
+
+	return nil
+}
+
+func (s *Service) DeleteRepost(ctx context.Context, userID string, postID int) (err error) {
+	ctx, span := tracer.Start(ctx, "service.DeleteRepost")
+	defer endSpan(span, &err)
+
+	deleted, err := s.repo.DeleteRepost(ctx, userID, postID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("repost of post %d: %w", postID, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// GetFeed returns a page of posts and reposts, prepared for viewerID like any
+// other post list.
+func (s *Service) GetFeed(ctx context.Context, query storage.FeedQuery) (_ []models.FeedItem, _ string, err error) {
+	ctx, span := tracer.Start(ctx, "service.GetFeed")
+	defer endSpan(span, &err)
+
+	items, next, err := s.repo.GetFeed(ctx, query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	posts := make([]models.Post, len(items))
+	for i, item := range items {
+		posts[i] = *feedPost(item)
+	}
+	if err := s.preparePosts(ctx, posts, query.ViewerID); err != nil {
+		return nil, "", err
+	}
+	for i := range items {
+		*feedPost(items[i]) = posts[i]
+	}
+
+	return items, next, nil
+}
+
+func feedPost(item models.FeedItem) *models.Post {
+	if item.Repost != nil {
+		return &item.Repost.Post
+	}
+	return item.Post
+}
+
+// attachPostShares fills in the repost and bookmark counters of posts for viewerID.
+func (s *Service) attachPostShares(ctx context.Context, posts []models.Post, viewerID string) error {
+	ids := make([]int, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+	}
+
+	counts, err := s.repo.GetPostShareCounts(ctx, ids, viewerID)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		sc := counts[posts[i].ID]
+		posts[i].RepostsCount, posts[i].BookmarksCount = sc.Reposts, sc.Bookmarks
+		posts[i].Reposted, posts[i].Bookmarked = sc.Reposted, sc.Bookmarked
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer starts a span named service.<Method> in every method of
+// ServiceIface, under the span of the request and above those of its queries.
+var tracer = otel.Tracer("github.com/Fyefhqdishka/deadlock_v.2/internal/service")
+
+// endSpan ends span and marks it failed with the error err points at. A
+// missing record is an expected outcome rather than an error of the span.
+func endSpan(span trace.Span, err *error) {
+	if *err != nil && !errors.Is(*err, storage.ErrNotFound) {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
@@ -0,0 +1,241 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateComment inserts a root comment or a reply and fills in its id and depth.
+// The materialized path is the parent's path plus the zero-padded id of the new row.
+func (s *Storage) CreateComment(ctx context.Context, comment *models.Comment, userID string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	parentPath, rootID := "", 0
+	comment.Depth = 0
+	if comment.ParentID != nil {
+		stmt := `SELECT path, root_id, depth + 1 FROM comments WHERE id = $1 AND post_id = $2 AND deleted_at IS NULL`
+		err := tx.QueryRow(ctx, stmt, *comment.ParentID, comment.PostID).Scan(&parentPath, &rootID, &comment.Depth)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("parent comment %d: %w", *comment.ParentID, storage.ErrNotFound)
+			}
+			return fmt.Errorf("failed to get parent comment: %v", err)
+		}
+		if comment.Depth > models.MaxCommentDepth {
+			return storage.ErrTooDeep
+		}
+		parentPath += "."
+	}
+
+	stmt := `INSERT INTO comments (post_id, user_id, parent_id, depth, content)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, TO_CHAR(created_at, 'YYYY-MM-DD HH24:MI:SS')`
+	err = tx.QueryRow(ctx, stmt, comment.PostID, userID, comment.ParentID, comment.Depth, comment.Content).
+		Scan(&comment.ID, &comment.CreateAt)
+	if err != nil {
+		s.log.ErrorContext(ctx, "CreateComment: failed to insert comment", "postID", comment.PostID, "err", err)
+		return err
+	}
+
+	if comment.ParentID == nil {
+		rootID = comment.ID
+	}
+
+	stmt = `UPDATE comments SET path = $2 || LPAD(id::text, 10, '0'), root_id = $3 WHERE id = $1`
+	if _, err := tx.Exec(ctx, stmt, comment.ID, parentPath, rootID); err != nil {
+		s.log.ErrorContext(ctx, "CreateComment: failed to set comment path", "commentID", comment.ID, "err", err)
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit comment: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetCommentAuthorID(ctx context.Context, postID, commentID int) (string, error) {
+	var userID string
+
+	stmt := `SELECT user_id FROM comments WHERE id = $1 AND post_id = $2 AND deleted_at IS NULL`
+	err := s.db.QueryRow(ctx, stmt, commentID, postID).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("comment with ID %d: %w", commentID, storage.ErrNotFound)
+		}
+		return "", fmt.Errorf("failed to get author of comment %d: %v", commentID, err)
+	}
+
+	return userID, nil
+}
+
+func (s *Storage) UpdateComment(ctx context.Context, comment *models.Comment) error {
+	stmt := `UPDATE comments SET content = $3, edited_at = now()
+	WHERE id = $1 AND post_id = $2 AND deleted_at IS NULL
+	RETURNING TO_CHAR(created_at, 'YYYY-MM-DD HH24:MI:SS'), TO_CHAR(edited_at, 'YYYY-MM-DD HH24:MI:SS'), parent_id, depth`
+	err := s.db.QueryRow(ctx, stmt, comment.ID, comment.PostID, comment.Content).
+		Scan(&comment.CreateAt, &comment.EditedAt, &comment.ParentID, &comment.Depth)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("comment with ID %d: %w", comment.ID, storage.ErrNotFound)
+		}
+		s.log.ErrorContext(ctx, "UpdateComment: failed to update comment", "commentID", comment.ID, "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteComment soft-deletes a comment. Its replies stay in place and the
+// comment itself is returned as a placeholder.
+func (s *Storage) DeleteComment(ctx context.Context, postID, commentID int) error {
+	stmt := `UPDATE comments SET deleted_at = now() WHERE id = $1 AND post_id = $2 AND deleted_at IS NULL`
+	res, err := s.db.Exec(ctx, stmt, commentID, postID)
+	if err != nil {
+		s.log.ErrorContext(ctx, "DeleteComment: failed to delete comment", "commentID", commentID, "err", err)
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return fmt.Errorf("comment with ID %d: %w", commentID, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// visibleComment hides deleted comments unless a live reply sits anywhere
+// below them, found by the materialized path of the thread.
+const visibleComment = `(c.deleted_at IS NULL OR EXISTS (
+	SELECT 1 FROM comments r
+	WHERE r.root_id = c.root_id AND r.path LIKE c.path || '.%' AND r.deleted_at IS NULL))`
+
+// threadNotBlocked hides the comments of users blocked in either direction
+// together with every reply below them, so no reply loses its parent.
+func threadNotBlocked(q *listQuery, viewerID string) string {
+	return `NOT EXISTS (SELECT 1 FROM comments a
+		WHERE a.root_id = c.root_id AND (a.id = c.id OR c.path LIKE a.path || '.%')
+			AND NOT ` + notBlocked(q, viewerID, "a.user_id") + `)`
+}
+
+// setPlaceholder replaces the content of a deleted comment, and of a hidden
+// one when the query withheld it from the viewer. The author of a hidden
+// comment still reads it, flagged as hidden.
+func setPlaceholder(c *models.Comment, withheld bool) {
+	switch {
+	case c.Deleted:
+		c.Content = models.DeletedCommentPlaceholder
+	case withheld:
+		c.Content = models.HiddenCommentPlaceholder
+	}
+}
+
+var commentKeyset = keyset{
+	columns: map[string]sortColumn{
+		"id": {expr: "c.id", cast: "int"},
+	},
+	id: sortColumn{expr: "c.id", cast: "int"},
+}
+
+// GetPostComments returns a page of threads flattened in display order: every
+// root comment of the page followed by up to models.MaxThreadReplies of its
+// replies, ordered by path. Comments of users blocked in either direction are
+// left out, with the replies below them.
+func (s *Storage) GetPostComments(ctx context.Context, postID int, query storage.CommentQuery) ([]models.Comment, string, error) {
+	var q listQuery
+	q.and("c.post_id = " + q.arg(postID))
+	q.and("c.parent_id IS NULL")
+	q.and(visibleComment)
+	q.and(notBlocked(&q, query.ViewerID, "c.user_id"))
+
+	page, err := commentKeyset.paginate(&q, query.Page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rootOrder := "c.root_id"
+	if query.Desc {
+		rootOrder += " DESC"
+	}
+
+	// Hidden comments keep their content for their author, like hidden posts.
+	withheld := `(c.hidden_at IS NOT NULL AND c.user_id::text IS DISTINCT FROM ` + q.arg(query.ViewerID) + `)`
+
+	// n numbers the rows of a thread in display order, the root being the
+	// first. One reply more than the limit tells that the thread goes on.
+	stmt := `WITH roots AS (SELECT c.id FROM comments c` + q.whereClause() + page + `),
+	thread AS (SELECT c.id, ROW_NUMBER() OVER (PARTITION BY c.root_id ORDER BY c.path) AS n
+		FROM comments c
+		WHERE c.root_id IN (SELECT id FROM roots) AND c.depth <= ` + q.arg(query.Depth) + `
+			AND ` + visibleComment + ` AND ` + threadNotBlocked(&q, query.ViewerID) + `)
+	SELECT c.id, c.post_id, c.parent_id, c.root_id, c.depth,
+		CASE WHEN c.deleted_at IS NULL AND NOT (` + withheld + `) THEN c.content ELSE '' END,
+		CASE WHEN c.deleted_at IS NULL THEN u.username ELSE '' END,
+		CASE WHEN c.deleted_at IS NULL THEN u.avatar ELSE '' END,
+		TO_CHAR(c.created_at, 'YYYY-MM-DD HH24:MI:SS'),
+		COALESCE(TO_CHAR(c.edited_at, 'YYYY-MM-DD HH24:MI:SS'), ''),
+		c.deleted_at IS NOT NULL, c.hidden_at IS NOT NULL, ` + withheld + `
+	FROM thread t
+	JOIN comments c ON c.id = t.id
+	JOIN users u ON c.user_id = u.id
+	WHERE t.n <= ` + q.arg(models.MaxThreadReplies+2) + `
+	ORDER BY ` + rootOrder + `, c.path`
+
+	rows, err := s.db.Query(ctx, stmt, q.args...)
+	if err != nil {
+		s.log.ErrorContext(ctx, "GetPostComments: failed to fetch comments", "postID", postID, "err", err)
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	var roots []int
+	rootAt, replies := 0, 0
+
+	for rows.Next() {
+		var c models.Comment
+		var rootID int
+		var withheld bool
+		if err := rows.Scan(&c.ID, &c.PostID, &c.ParentID, &rootID, &c.Depth, &c.Content,
+			&c.Username, &c.Avatar, &c.CreateAt, &c.EditedAt, &c.Deleted, &c.Hidden, &withheld); err != nil {
+			return nil, "", fmt.Errorf("failed to scan comment: %v", err)
+		}
+		setPlaceholder(&c, withheld)
+		if len(roots) == 0 || roots[len(roots)-1] != rootID {
+			roots = append(roots, rootID)
+			rootAt, replies = len(comments), 0
+		} else {
+			replies++
+			if replies > models.MaxThreadReplies {
+				comments[rootAt].MoreReplies = true
+				continue
+			}
+		}
+		if len(roots) > query.Size() {
+			// The extra thread requested by paginate only tells that a next page exists.
+			break
+		}
+		comments = append(comments, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	var next string
+	if len(roots) > query.Size() {
+		last := strconv.Itoa(roots[query.Size()-1])
+		next = query.Next(last, last)
+	}
+
+	return comments, next, nil
+}
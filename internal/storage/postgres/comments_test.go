@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+)
+
+func TestSetPlaceholder(t *testing.T) {
+	tests := []struct {
+		name     string
+		comment  models.Comment
+		withheld bool
+		want     string
+	}{
+		{name: "visible", comment: models.Comment{Content: "hi"}, want: "hi"},
+		{name: "author sees own hidden comment", comment: models.Comment{Content: "hi", Hidden: true}, want: "hi"},
+		{name: "hidden from others", comment: models.Comment{Hidden: true}, withheld: true, want: models.HiddenCommentPlaceholder},
+		{name: "deleted", comment: models.Comment{Deleted: true}, want: models.DeletedCommentPlaceholder},
+		{name: "deleted wins over hidden", comment: models.Comment{Deleted: true, Hidden: true}, withheld: true, want: models.DeletedCommentPlaceholder},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.comment
+			setPlaceholder(&c, tt.withheld)
+			if c.Content != tt.want {
+				t.Errorf("content = %q, want %q", c.Content, tt.want)
+			}
+			if c.Hidden != tt.comment.Hidden {
+				t.Errorf("hidden = %v, want %v", c.Hidden, tt.comment.Hidden)
+			}
+		})
+	}
+}
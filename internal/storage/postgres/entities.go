@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// SetPostEntities replaces the hashtags and mentions of a post. Usernames that
+// do not exist are skipped. It returns the ids of users who were not
+// mentioned in the post before.
+func (s *Storage) SetPostEntities(ctx context.Context, postID int, tags, usernames []string) ([]string, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	mentioned, err := setPostEntities(ctx, tx, postID, tags, usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit entities of post %d: %v", postID, err)
+	}
+
+	return mentioned, nil
+}
+
+func setPostEntities(ctx context.Context, tx pgx.Tx, postID int, tags, usernames []string) ([]string, error) {
+	if _, err := tx.Exec(ctx, `DELETE FROM post_hashtags WHERE post_id = $1`, postID); err != nil {
+		return nil, fmt.Errorf("failed to clear hashtags of post %d: %v", postID, err)
+	}
+
+	if len(tags) > 0 {
+		stmt := `INSERT INTO hashtags (tag) SELECT unnest($1::text[]) ON CONFLICT (tag) DO NOTHING`
+		if _, err := tx.Exec(ctx, stmt, tags); err != nil {
+			return nil, fmt.Errorf("failed to save hashtags: %v", err)
+		}
+
+		stmt = `INSERT INTO post_hashtags (post_id, hashtag_id) SELECT $1, id FROM hashtags WHERE tag = ANY($2)`
+		if _, err := tx.Exec(ctx, stmt, postID, tags); err != nil {
+			return nil, fmt.Errorf("failed to link hashtags to post %d: %v", postID, err)
+		}
+	}
+
+	stmt := `WITH mentioned AS (
+		SELECT id FROM users WHERE username = ANY($2)
+	), removed AS (
+		DELETE FROM post_mentions WHERE post_id = $1 AND user_id NOT IN (SELECT id FROM mentioned)
+	)
+	INSERT INTO post_mentions (post_id, user_id) SELECT $1, id FROM mentioned
+	ON CONFLICT DO NOTHING
+	RETURNING user_id`
+
+	rows, err := tx.Query(ctx, stmt, postID, usernames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save mentions of post %d: %v", postID, err)
+	}
+
+	var mentioned []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan mention: %v", err)
+		}
+		mentioned = append(mentioned, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	return mentioned, nil
+}
+
+// GetTrendingTags counts the posts created since the given time per hashtag.
+func (s *Storage) GetTrendingTags(ctx context.Context, since time.Time, limit int) ([]models.TrendingTag, error) {
+	stmt := `SELECT h.tag, count(*) AS posts
+	FROM post_hashtags ph
+	JOIN hashtags h ON ph.hashtag_id = h.id
+	JOIN posts p ON ph.post_id = p.id
+	WHERE p.created_at >= $1 AND p.deleted_at IS NULL AND p.status = 'published' AND p.hidden_at IS NULL
+	GROUP BY h.tag
+	ORDER BY posts DESC, h.tag
+	LIMIT $2`
+
+	rows, err := s.read.Query(ctx, stmt, since, limit)
+	if err != nil {
+		s.log.ErrorContext(ctx, "GetTrendingTags: failed to fetch tags", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.TrendingTag{}
+	for rows.Next() {
+		var tag models.TrendingTag
+		if err := rows.Scan(&tag.Tag, &tag.Posts); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %v", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,193 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Storage) CreateNotification(ctx context.Context, n *models.Notification) error {
+	stmt := `INSERT INTO notifications (user_id, type, actor_id, target_type, target_id)
+	VALUES ($1, $2, NULLIF($3, '')::uuid, NULLIF($4, ''), $5)
+	RETURNING id, TO_CHAR(created_at, 'YYYY-MM-DD HH24:MI:SS'),
+		COALESCE((SELECT username FROM users WHERE id = actor_id), '')`
+
+	err := s.db.QueryRow(ctx, stmt, n.UserID, n.Type, n.ActorID, n.TargetType, n.TargetID).
+		Scan(&n.ID, &n.CreateAt, &n.ActorUsername)
+	if err != nil {
+		s.log.ErrorContext(ctx, "CreateNotification: failed to save notification", "userID", n.UserID, "type", n.Type, "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// maxGroupActors is how many distinct usernames are returned per group.
+const maxGroupActors = 3
+
+var notificationKeyset = keyset{
+	columns: map[string]sortColumn{
+		"id": {expr: "g.id", cast: "bigint"},
+	},
+	id: sortColumn{expr: "g.id", cast: "bigint"},
+}
+
+// GetNotificationGroups pages over the inbox of userID, newest group first.
+// Notifications are grouped by type, target and read state, so new activity
+// on a target does not merge into a group that was already read.
+func (s *Storage) GetNotificationGroups(ctx context.Context, userID string, query storage.NotificationQuery) ([]models.NotificationGroup, string, error) {
+	var q listQuery
+	q.and("n.user_id = " + q.arg(userID))
+	if query.UnreadOnly {
+		q.and("n.read_at IS NULL")
+	}
+	if query.Types != nil {
+		q.and("n.type = ANY(" + q.arg(query.Types) + ")")
+	}
+	if !query.Since.IsZero() {
+		q.and("n.created_at > " + q.arg(query.Since))
+	}
+	inner := q.whereClause()
+	q.where = nil
+
+	order, err := notificationKeyset.paginate(&q, query.Page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	stmt := `SELECT g.id, g.ids, g.type, g.target_type, g.target_id, g.actors, g.actors_count, g.count,
+		g.created_at, g.read, ` + notificationKeyset.sortValue(query.Page) + `
+	FROM (
+		SELECT max(n.id) AS id,
+			array_agg(n.id ORDER BY n.id DESC) AS ids,
+			n.type,
+			COALESCE(n.target_type, '') AS target_type,
+			COALESCE(n.target_id, 0) AS target_id,
+			array_remove(array_agg(u.username ORDER BY n.id DESC), NULL) AS actors,
+			count(DISTINCT n.actor_id) AS actors_count,
+			count(*) AS count,
+			TO_CHAR(max(n.created_at), 'YYYY-MM-DD HH24:MI:SS') AS created_at,
+			n.read_at IS NOT NULL AS read
+		FROM notifications n
+		LEFT JOIN users u ON n.actor_id = u.id` + inner + `
+		GROUP BY n.type, n.target_type, n.target_id, n.read_at IS NOT NULL
+	) g` + q.whereClause() + order
+
+	rows, err := s.db.Query(ctx, stmt, q.args...)
+	if err != nil {
+		s.log.ErrorContext(ctx, "GetNotificationGroups: failed to fetch notifications", "userID", userID, "err", err)
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	groups := []models.NotificationGroup{}
+	var keys []rowKey
+
+	for rows.Next() {
+		var g models.NotificationGroup
+		var key rowKey
+		if err := rows.Scan(&g.ID, &g.IDs, &g.Type, &g.TargetType, &g.TargetID, &g.Actors, &g.ActorsCount, &g.Count,
+			&g.CreateAt, &g.Read, &key.value); err != nil {
+			return nil, "", fmt.Errorf("failed to scan notification group: %v", err)
+		}
+		g.Actors = firstDistinct(g.Actors, maxGroupActors)
+		key.id = key.value
+		groups = append(groups, g)
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	groups, next := trimPage(query.Page, groups, keys)
+
+	return groups, next, nil
+}
+
+// firstDistinct returns up to n distinct values of s, keeping their order.
+func firstDistinct(s []string, n int) []string {
+	out := make([]string, 0, n)
+	for _, v := range s {
+		if len(out) == n {
+			break
+		}
+		if !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// CountUnreadNotifications counts the unread notifications of the given types,
+// or of all types when types is nil.
+func (s *Storage) CountUnreadNotifications(ctx context.Context, userID string, types []string) (int, error) {
+	var count int
+
+	stmt := `SELECT count(*) FROM notifications
+	WHERE user_id = $1 AND read_at IS NULL AND ($2::text[] IS NULL OR type = ANY($2))`
+	if err := s.db.QueryRow(ctx, stmt, userID, types).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count unread notifications: %v", err)
+	}
+
+	return count, nil
+}
+
+func (s *Storage) MarkNotificationsRead(ctx context.Context, userID string, ids []int64) (int, error) {
+	stmt := `UPDATE notifications SET read_at = now() WHERE user_id = $1 AND id = ANY($2) AND read_at IS NULL`
+	res, err := s.db.Exec(ctx, stmt, userID, ids)
+	if err != nil {
+		s.log.ErrorContext(ctx, "MarkNotificationsRead: failed to update notifications", "userID", userID, "err", err)
+		return 0, err
+	}
+
+	return int(res.RowsAffected()), nil
+}
+
+func (s *Storage) MarkAllNotificationsRead(ctx context.Context, userID string) (int, error) {
+	stmt := `UPDATE notifications SET read_at = now() WHERE user_id = $1 AND read_at IS NULL`
+	res, err := s.db.Exec(ctx, stmt, userID)
+	if err != nil {
+		s.log.ErrorContext(ctx, "MarkAllNotificationsRead: failed to update notifications", "userID", userID, "err", err)
+		return 0, err
+	}
+
+	return int(res.RowsAffected()), nil
+}
+
+// CreateStreamTicket stores ticket for userID, valid for ttl. Expired tickets
+// are cleared on the way.
+func (s *Storage) CreateStreamTicket(ctx context.Context, ticket, userID string, ttl time.Duration) error {
+	stmt := `WITH expired AS (DELETE FROM stream_tickets WHERE expires_at <= now())
+	INSERT INTO stream_tickets (ticket, user_id, expires_at) VALUES ($1, $2, now() + $3 * interval '1 millisecond')`
+	if _, err := s.db.Exec(ctx, stmt, ticket, userID, ttl.Milliseconds()); err != nil {
+		s.log.ErrorContext(ctx, "CreateStreamTicket: failed to save ticket", "userID", userID, "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// RedeemStreamTicket spends ticket and returns the user it was issued to, or
+// storage.ErrNotFound when it is unknown, used or expired.
+func (s *Storage) RedeemStreamTicket(ctx context.Context, ticket string) (string, error) {
+	var userID string
+	stmt := `DELETE FROM stream_tickets t USING users u
+	WHERE t.ticket = $1 AND t.expires_at > now() AND u.id = t.user_id AND u.suspended_at IS NULL
+	RETURNING t.user_id`
+	err := s.db.QueryRow(ctx, stmt, ticket).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("stream ticket: %w", storage.ErrNotFound)
+		}
+		return "", fmt.Errorf("failed to redeem stream ticket: %v", err)
+	}
+
+	return userID, nil
+}
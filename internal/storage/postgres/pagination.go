@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+)
+
+// listQuery collects the WHERE conditions and positional arguments of a list query.
+type listQuery struct {
+	where []string
+	args  []any
+}
+
+func (q *listQuery) arg(v any) string {
+	q.args = append(q.args, v)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+func (q *listQuery) and(cond string) {
+	q.where = append(q.where, cond)
+}
+
+func (q *listQuery) whereClause() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.where, " AND ")
+}
+
+// sortColumn is an SQL expression a list can be ordered by and the type its
+// text form is cast back to when it comes from a cursor.
+type sortColumn struct {
+	expr string
+	cast string
+}
+
+// keyset implements cursor pagination over the whitelisted sort columns, with
+// id breaking ties between rows that share a sort value.
+type keyset struct {
+	columns map[string]sortColumn
+	id      sortColumn
+}
+
+// sortValue is the select expression for the text form of the sort key, the
+// value that ends up in the cursor.
+func (k keyset) sortValue(page pagination.Page) string {
+	return k.columns[page.Sort].expr + "::text"
+}
+
+// paginate adds the cursor condition to q and returns the ORDER BY and LIMIT
+// clauses. One extra row is requested to find out whether a next page exists.
+func (k keyset) paginate(q *listQuery, page pagination.Page) (string, error) {
+	col, ok := k.columns[page.Sort]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", pagination.ErrInvalidSort, page.Sort)
+	}
+
+	op, dir := ">", "ASC"
+	if page.Desc {
+		op, dir = "<", "DESC"
+	}
+
+	if c := page.Cursor; c != nil {
+		q.and(fmt.Sprintf("(%s, %s) %s (%s::%s, %s::%s)",
+			col.expr, k.id.expr, op, q.arg(c.Value), col.cast, q.arg(c.ID), k.id.cast))
+	}
+
+	return fmt.Sprintf(" ORDER BY %s %s, %s %s LIMIT %d", col.expr, dir, k.id.expr, dir, page.Size()+1), nil
+}
+
+// rowKey is the sort value and id of a fetched row.
+type rowKey struct {
+	value string
+	id    string
+}
+
+// trimPage drops the extra row requested by paginate and returns the cursor of
+// the next page, or an empty string when items is the last page.
+func trimPage[T any](page pagination.Page, items []T, keys []rowKey) ([]T, string) {
+	size := page.Size()
+	if len(items) <= size {
+		return items, ""
+	}
+
+	last := keys[size-1]
+	return items[:size], page.Next(last.value, last.id)
+}
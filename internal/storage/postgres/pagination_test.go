@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+)
+
+func TestTrimPage(t *testing.T) {
+	keys := func(n int) []rowKey {
+		k := make([]rowKey, n)
+		for i := range k {
+			k[i] = rowKey{value: string(rune('a' + i)), id: string(rune('0' + i))}
+		}
+		return k
+	}
+	items := func(n int) []int {
+		list := make([]int, n)
+		for i := range list {
+			list[i] = i
+		}
+		return list
+	}
+
+	tests := []struct {
+		name      string
+		page      pagination.Page
+		rows      int
+		wantLen   int
+		wantValue string
+		wantID    string
+	}{
+		{name: "empty", page: pagination.Page{Limit: 3, Sort: "id"}, rows: 0, wantLen: 0},
+		{name: "short page", page: pagination.Page{Limit: 3, Sort: "id"}, rows: 2, wantLen: 2},
+		{name: "exactly full", page: pagination.Page{Limit: 3, Sort: "id"}, rows: 3, wantLen: 3},
+		{name: "extra row", page: pagination.Page{Limit: 3, Sort: "id"}, rows: 4, wantLen: 3, wantValue: "c", wantID: "2"},
+		{name: "default size", page: pagination.Page{Sort: "id"}, rows: pagination.DefaultLimit + 1, wantLen: pagination.DefaultLimit, wantValue: string(rune('a' + pagination.DefaultLimit - 1)), wantID: string(rune('0' + pagination.DefaultLimit - 1))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, next := trimPage(tt.page, items(tt.rows), keys(tt.rows))
+			if len(got) != tt.wantLen {
+				t.Errorf("got %d items, want %d", len(got), tt.wantLen)
+			}
+			if tt.wantID == "" {
+				if next != "" {
+					t.Errorf("got next cursor %q on the last page", next)
+				}
+				return
+			}
+			c, err := pagination.DecodeCursor(next)
+			if err != nil {
+				t.Fatalf("DecodeCursor: %v", err)
+			}
+			if c.Value != tt.wantValue || c.ID != tt.wantID {
+				t.Errorf("cursor points at (%q, %q), want (%q, %q)", c.Value, c.ID, tt.wantValue, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestKeysetPaginate(t *testing.T) {
+	ks := keyset{
+		columns: map[string]sortColumn{"created_at": {expr: "p.created_at", cast: "timestamp"}},
+		id:      sortColumn{expr: "p.id", cast: "int"},
+	}
+
+	tests := []struct {
+		name      string
+		page      pagination.Page
+		wantOrder string
+		wantWhere string
+		wantArgs  int
+		wantErr   error
+	}{
+		{
+			name:      "first page",
+			page:      pagination.Page{Limit: 10, Sort: "created_at"},
+			wantOrder: " ORDER BY p.created_at ASC, p.id ASC LIMIT 11",
+		},
+		{
+			name:      "descending after cursor",
+			page:      pagination.Page{Limit: 10, Sort: "created_at", Desc: true, Cursor: &pagination.Cursor{Sort: "-created_at", Value: "2024-01-01", ID: "5"}},
+			wantOrder: " ORDER BY p.created_at DESC, p.id DESC LIMIT 11",
+			wantWhere: " WHERE (p.created_at, p.id) < ($1::timestamp, $2::int)",
+			wantArgs:  2,
+		},
+		{name: "unknown sort", page: pagination.Page{Sort: "title"}, wantErr: pagination.ErrInvalidSort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var q listQuery
+			order, err := ks.paginate(&q, tt.page)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("paginate: %v", err)
+			}
+			if order != tt.wantOrder {
+				t.Errorf("got order %q, want %q", order, tt.wantOrder)
+			}
+			if where := q.whereClause(); where != tt.wantWhere {
+				t.Errorf("got where %q, want %q", where, tt.wantWhere)
+			}
+			if len(q.args) != tt.wantArgs {
+				t.Errorf("got %d args, want %d", len(q.args), tt.wantArgs)
+			}
+		})
+	}
+}
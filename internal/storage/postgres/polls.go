@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// createPoll stores the poll of a post being created, within its transaction,
+// and fills in the ids of the poll and its options.
+func (s *Storage) createPoll(ctx context.Context, tx pgx.Tx, postID int, poll *models.Poll) error {
+	stmt := `INSERT INTO polls (post_id, multiple, closes_at) VALUES ($1, $2, $3) RETURNING id`
+	if err := tx.QueryRow(ctx, stmt, postID, poll.Multiple, poll.ClosesAt).Scan(&poll.ID); err != nil {
+		s.log.ErrorContext(ctx, "createPoll: failed to insert poll", "postID", postID, "err", err)
+		return err
+	}
+
+	stmt = `INSERT INTO poll_options (poll_id, position, text) VALUES ($1, $2, $3) RETURNING id`
+	for i := range poll.Options {
+		if err := tx.QueryRow(ctx, stmt, poll.ID, i, poll.Options[i].Text).Scan(&poll.Options[i].ID); err != nil {
+			s.log.ErrorContext(ctx, "createPoll: failed to insert option", "pollID", poll.ID, "err", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Vote records the ballot of userID in the poll of a post. The primary key of
+// poll_ballots guarantees a single ballot per user; optionIDs must be distinct.
+func (s *Storage) Vote(ctx context.Context, postID int, userID string, optionIDs []int) error {
+	if err := s.visiblePostExists(ctx, postID, userID); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var pollID int
+	var multiple, closed bool
+	stmt := `SELECT id, multiple, closes_at IS NOT NULL AND closes_at <= now() FROM polls WHERE post_id = $1`
+	err = tx.QueryRow(ctx, stmt, postID).Scan(&pollID, &multiple, &closed)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("poll of post %d: %w", postID, storage.ErrNotFound)
+		}
+		return fmt.Errorf("failed to get poll of post %d: %v", postID, err)
+	}
+	if closed {
+		return storage.ErrPollClosed
+	}
+	if !multiple && len(optionIDs) > 1 {
+		return storage.ErrInvalidVote
+	}
+
+	stmt = `INSERT INTO poll_ballots (poll_id, user_id) VALUES ($1, $2) ON CONFLICT (poll_id, user_id) DO NOTHING`
+	res, err := tx.Exec(ctx, stmt, pollID, userID)
+	if err != nil {
+		s.log.ErrorContext(ctx, "Vote: failed to insert ballot", "pollID", pollID, "err", err)
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return storage.ErrAlreadyVoted
+	}
+
+	stmt = `INSERT INTO poll_votes (poll_id, option_id, user_id)
+	SELECT $1, o.id, $2 FROM poll_options o WHERE o.poll_id = $1 AND o.id = ANY($3)`
+	res, err = tx.Exec(ctx, stmt, pollID, userID, optionIDs)
+	if err != nil {
+		s.log.ErrorContext(ctx, "Vote: failed to insert votes", "pollID", pollID, "err", err)
+		return err
+	}
+	if int(res.RowsAffected()) != len(optionIDs) {
+		return storage.ErrInvalidVote
+	}
+
+	stmt = `UPDATE poll_options SET votes = votes + 1 WHERE poll_id = $1 AND id = ANY($2)`
+	if _, err := tx.Exec(ctx, stmt, pollID, optionIDs); err != nil {
+		s.log.ErrorContext(ctx, "Vote: failed to update counters", "pollID", pollID, "err", err)
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit vote: %v", err)
+	}
+
+	return nil
+}
+
+// GetPolls returns the polls of the given posts keyed by post id, with the
+// ballot of viewerID. Vote counts are always filled in; hiding them is up to
+// the caller.
+func (s *Storage) GetPolls(ctx context.Context, postIDs []int, viewerID string) (map[int]*models.Poll, error) {
+	polls := make(map[int]*models.Poll)
+	if len(postIDs) == 0 {
+		return polls, nil
+	}
+
+	stmt := `SELECT pl.post_id, pl.id, pl.multiple, pl.closes_at,
+		pl.closes_at IS NOT NULL AND pl.closes_at <= now(),
+		(SELECT count(*) FROM poll_ballots b WHERE b.poll_id = pl.id),
+		EXISTS (SELECT 1 FROM poll_ballots b WHERE b.poll_id = pl.id AND b.user_id::text = $2),
+		o.id, o.text, o.votes,
+		EXISTS (SELECT 1 FROM poll_votes v WHERE v.option_id = o.id AND v.user_id::text = $2)
+	FROM polls pl
+	JOIN poll_options o ON o.poll_id = pl.id
+	WHERE pl.post_id = ANY($1)
+	ORDER BY pl.post_id, o.position`
+
+	rows, err := s.db.Query(ctx, stmt, postIDs, viewerID)
+	if err != nil {
+		s.log.ErrorContext(ctx, "GetPolls: failed to fetch polls", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID, votes int
+		var poll models.Poll
+		var opt models.PollOption
+		if err := rows.Scan(&postID, &poll.ID, &poll.Multiple, &poll.ClosesAt, &poll.Closed, &poll.Voters, &poll.Voted,
+			&opt.ID, &opt.Text, &votes, &opt.Chosen); err != nil {
+			return nil, fmt.Errorf("failed to scan poll option: %v", err)
+		}
+		opt.Votes = &votes
+
+		if polls[postID] == nil {
+			polls[postID] = &poll
+		}
+		polls[postID].Options = append(polls[postID].Options, opt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	return polls, nil
+}
@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
 	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -15,25 +19,119 @@ type PgxPoolIface interface {
 	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
 	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
 }
 
-func NewStorage(db PgxPoolIface, log *slog.Logger) storage.Storage {
+// NewStorage creates the storage on db. Anonymous listings, which tolerate
+// replication lag, go to read while it is reachable; it may be nil to send
+// them to db as well.
+func NewStorage(db, read PgxPoolIface, log *slog.Logger) storage.Storage {
+	if read == nil {
+		read = db
+	} else {
+		read = &replicaPool{PgxPoolIface: db, replica: read, log: log}
+	}
 	return &Storage{
-		db:  db,
-		log: log,
+		db:   db,
+		read: read,
+		log:  log,
 	}
 }
 
-func ConnectDB(dsn string) (*pgxpool.Pool, error) {
-	pool, err := pgxpool.New(context.Background(), dsn)
+// Config describes a connection pool. Zero durations keep the pgxpool
+// defaults, a zero StatementTimeout leaves statements unbounded.
+type Config struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Database string
+
+	// SSLMode is one of the libpq modes, e.g. disable or verify-full.
+	// SSLRootCert is the CA that signed the server certificate; SSLCert and
+	// SSLKey are the client certificate and its key.
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+	StatementTimeout  time.Duration
+}
+
+// DSN is the connection URL of c. Every part is escaped, so passwords and
+// paths may contain any character.
+func (c Config) DSN() string {
+	query := url.Values{}
+	query.Set("sslmode", c.SSLMode)
+	for name, val := range map[string]string{
+		"sslrootcert": c.SSLRootCert,
+		"sslcert":     c.SSLCert,
+		"sslkey":      c.SSLKey,
+	} {
+		if val != "" {
+			query.Set(name, val)
+		}
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     net.JoinHostPort(c.Host, c.Port),
+		Path:     "/" + c.Database,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// ConnectDB opens a pool described by cfg and checks that it can reach the
+// database.
+func ConnectDB(cfg Config) (*pgxpool.Pool, error) {
+	pool, err := NewPool(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create pool: %w", err)
+		return nil, err
 	}
 
 	err = pool.Ping(context.Background())
 	if err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("unable to ping database: %w", err)
 	}
 
 	return pool, nil
 }
+
+// NewPool opens a pool described by cfg without waiting for the database,
+// which is connected to on first use.
+func NewPool(cfg Config) (*pgxpool.Pool, error) {
+	poolCfg, err := pgxpool.ParseConfig(cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("invalid database config: %w", err)
+	}
+	poolCfg.MaxConns = cfg.MaxConns
+	poolCfg.MinConns = cfg.MinConns
+	if cfg.MaxConnLifetime > 0 {
+		poolCfg.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		poolCfg.MaxConnIdleTime = cfg.MaxConnIdleTime
+	}
+	if cfg.HealthCheckPeriod > 0 {
+		poolCfg.HealthCheckPeriod = cfg.HealthCheckPeriod
+	}
+	if cfg.StatementTimeout > 0 {
+		poolCfg.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+	poolCfg.ConnConfig.Tracer = queryTracer{}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create pool: %w", err)
+	}
+
+	return pool, nil
+}
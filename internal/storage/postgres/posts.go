@@ -0,0 +1,222 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// postColumns selects a post joined with its author as p and u, in the order
+// expected by postFields.
+const postColumns = `p.id, p.title, p.content, p.content_format,
+	TO_CHAR(p.created_at, 'YYYY-MM-DD HH24:MI:SS'),
+	TO_CHAR(p.updated_at, 'YYYY-MM-DD HH24:MI:SS'),
+	COALESCE(TO_CHAR(p.edited_at, 'YYYY-MM-DD HH24:MI:SS'), ''),
+	p.status, p.publish_at, p.user_id, u.username, u.avatar,
+	(SELECT count(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL)`
+
+func postFields(post *models.Post) []any {
+	return []any{&post.ID, &post.Title, &post.Content, &post.Format, &post.CreateAt, &post.UpdatedAt, &post.EditedAt,
+		&post.Status, &post.PublishAt, &post.AuthorID, &post.Username, &post.Avatar, &post.CommentsCount}
+}
+
+func (s *Storage) GetPostAuthorID(ctx context.Context, postID int) (string, error) {
+	var userID string
+
+	stmt := `SELECT user_id FROM posts WHERE id = $1 AND deleted_at IS NULL`
+	err := s.db.QueryRow(ctx, stmt, postID).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("post with ID %d: %w", postID, storage.ErrNotFound)
+		}
+		return "", fmt.Errorf("failed to get author of post %d: %v", postID, err)
+	}
+
+	return userID, nil
+}
+
+// GetVisiblePostAuthorID returns the author of a post viewerID may see: a
+// published post that is not hidden pending review, or one of their own, with
+// no block between them and the author. Other posts are reported as not found,
+// like missing ones.
+func (s *Storage) GetVisiblePostAuthorID(ctx context.Context, postID int, viewerID string) (string, error) {
+	var userID string
+
+	stmt := `SELECT p.user_id FROM posts p
+	WHERE p.id = $1 AND p.deleted_at IS NULL AND ((p.status = 'published' AND p.hidden_at IS NULL) OR p.user_id::text = $2)
+		AND NOT EXISTS (SELECT 1 FROM user_blocks ub
+			WHERE (ub.user_id::text = $2 AND ub.target_id = p.user_id) OR (ub.user_id = p.user_id AND ub.target_id::text = $2))`
+	err := s.db.QueryRow(ctx, stmt, postID, viewerID).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("post with ID %d: %w", postID, storage.ErrNotFound)
+		}
+		return "", fmt.Errorf("failed to get author of post %d: %v", postID, err)
+	}
+
+	return userID, nil
+}
+
+// UpdatePost replaces the title and content of a post and stores the previous
+// version in post_revisions within the same transaction.
+func (s *Storage) UpdatePost(ctx context.Context, post *models.Post, editorID string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var before models.PostVersion
+	stmt := `SELECT title, content FROM posts WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+	err = tx.QueryRow(ctx, stmt, post.ID).Scan(&before.Title, &before.Content)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("post with ID %d: %w", post.ID, storage.ErrNotFound)
+		}
+		return fmt.Errorf("failed to lock post %d: %v", post.ID, err)
+	}
+
+	stmt = `INSERT INTO post_revisions (post_id, editor_id, title_before, content_before, title_after, content_after)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err = tx.Exec(ctx, stmt, post.ID, editorID, before.Title, before.Content, post.Title, post.Content)
+	if err != nil {
+		s.log.ErrorContext(ctx, "UpdatePost: failed to save revision", "postID", post.ID, "err", err)
+		return err
+	}
+
+	// An empty status keeps the current one. Publishing a draft moves it to
+	// the top of the feed, like the background publisher does.
+	stmt = `UPDATE posts SET title = $2, content = $3, content_format = COALESCE(NULLIF($4, ''), content_format),
+		created_at = CASE WHEN $5::text = 'published' AND status <> 'published' THEN now() ELSE created_at END,
+		publish_at = CASE WHEN $5::text = '' THEN publish_at ELSE $6 END,
+		status = COALESCE(NULLIF($5::text, ''), status),
+		edited_at = now(), updated_at = now()
+	WHERE id = $1`
+	_, err = tx.Exec(ctx, stmt, post.ID, post.Title, post.Content, post.Format, post.Status, post.PublishAt)
+	if err != nil {
+		s.log.ErrorContext(ctx, "UpdatePost: failed to update post", "postID", post.ID, "err", err)
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit post %d: %v", post.ID, err)
+	}
+
+	s.log.DebugContext(ctx, "post updated", "postID", post.ID, "editorID", editorID)
+
+	return nil
+}
+
+// DeletePost soft-deletes a post: the row and its revisions are kept but the
+// post disappears from every read path.
+func (s *Storage) DeletePost(ctx context.Context, postID int) error {
+	stmt := `UPDATE posts SET deleted_at = now(), updated_at = now() WHERE id = $1 AND deleted_at IS NULL`
+	res, err := s.db.Exec(ctx, stmt, postID)
+	if err != nil {
+		s.log.ErrorContext(ctx, "DeletePost: failed to delete post", "postID", postID, "err", err)
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return fmt.Errorf("post with ID %d: %w", postID, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// GetPostRevisions returns the edit history of a post viewerID may see.
+func (s *Storage) GetPostRevisions(ctx context.Context, postID int, viewerID string) ([]models.PostRevision, error) {
+	if _, err := s.GetVisiblePostAuthorID(ctx, postID, viewerID); err != nil {
+		return nil, err
+	}
+
+	stmt := `SELECT r.id, r.post_id, u.username,
+		r.title_before, r.content_before, r.title_after, r.content_after,
+		TO_CHAR(r.created_at, 'YYYY-MM-DD HH24:MI:SS') AS created_at
+	FROM post_revisions r
+	JOIN users u ON r.editor_id = u.id
+	WHERE r.post_id = $1
+	ORDER BY r.id`
+
+	rows, err := s.db.Query(ctx, stmt, postID)
+	if err != nil {
+		s.log.ErrorContext(ctx, "GetPostRevisions: failed to fetch revisions", "postID", postID, "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.PostRevision{}
+
+	for rows.Next() {
+		var rev models.PostRevision
+		if err := rows.Scan(&rev.ID, &rev.PostID, &rev.EditorUsername,
+			&rev.Before.Title, &rev.Before.Content, &rev.After.Title, &rev.After.Content, &rev.CreateAt); err != nil {
+			return nil, fmt.Errorf("failed to scan revision: %v", err)
+		}
+		revisions = append(revisions, rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	return revisions, nil
+}
+
+func (s *Storage) GetUserRole(ctx context.Context, userID string) (string, error) {
+	var role string
+
+	stmt := `SELECT role FROM users WHERE id = $1`
+	err := s.db.QueryRow(ctx, stmt, userID).Scan(&role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("user %s: %w", userID, storage.ErrNotFound)
+		}
+		return "", fmt.Errorf("failed to get role of user %s: %v", userID, err)
+	}
+
+	return role, nil
+}
+
+// PublishDuePosts publishes up to limit scheduled posts whose publish_at has
+// passed. Rows are claimed with FOR UPDATE SKIP LOCKED and flipped to published
+// in the same statement, so concurrent replicas never publish a post twice.
+func (s *Storage) PublishDuePosts(ctx context.Context, limit int) ([]models.Post, error) {
+	stmt := `WITH due AS (
+		SELECT id FROM posts
+		WHERE status = 'scheduled' AND publish_at <= now() AND deleted_at IS NULL
+		ORDER BY publish_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	UPDATE posts p SET status = 'published', created_at = now(), updated_at = now()
+	FROM due
+	WHERE p.id = due.id
+	RETURNING p.id, p.title, p.content, p.user_id`
+
+	rows, err := s.db.Query(ctx, stmt, limit)
+	if err != nil {
+		s.log.ErrorContext(ctx, "PublishDuePosts: failed to publish posts", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []models.Post
+	for rows.Next() {
+		post := models.Post{Status: models.StatusPublished}
+		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.AuthorID); err != nil {
+			return nil, fmt.Errorf("failed to scan published post: %v", err)
+		}
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	return posts, nil
+}
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetNotificationPreferences returns the saved preferences of userID, or
+// storage.ErrNotFound when the user never changed the defaults.
+func (s *Storage) GetNotificationPreferences(ctx context.Context, userID string) (models.NotificationPreferences, error) {
+	var prefs models.NotificationPreferences
+	var data []byte
+
+	stmt := `SELECT preferences, digest FROM notification_preferences WHERE user_id = $1`
+	err := s.db.QueryRow(ctx, stmt, userID).Scan(&data, &prefs.Digest)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return prefs, fmt.Errorf("notification preferences of %s: %w", userID, storage.ErrNotFound)
+		}
+		return prefs, fmt.Errorf("failed to get notification preferences of %s: %v", userID, err)
+	}
+
+	digest := prefs.Digest
+	if err := json.Unmarshal(data, &prefs); err != nil {
+		return prefs, fmt.Errorf("invalid notification preferences of %s: %v", userID, err)
+	}
+	prefs.Digest = digest
+
+	return prefs, nil
+}
+
+// SaveNotificationPreferences replaces the preferences of userID. The digest
+// frequency is kept in its own column for the digest job.
+func (s *Storage) SaveNotificationPreferences(ctx context.Context, userID string, prefs models.NotificationPreferences) error {
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification preferences: %v", err)
+	}
+
+	stmt := `INSERT INTO notification_preferences (user_id, preferences, digest) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id) DO UPDATE SET preferences = EXCLUDED.preferences, digest = EXCLUDED.digest, updated_at = now()`
+	if _, err := s.db.Exec(ctx, stmt, userID, data, prefs.Digest); err != nil {
+		s.log.ErrorContext(ctx, "SaveNotificationPreferences: failed to save preferences", "userID", userID, "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// DisableDigest turns the digest of userID off, keeping the other preferences.
+func (s *Storage) DisableDigest(ctx context.Context, userID string) error {
+	stmt := `INSERT INTO notification_preferences (user_id, preferences, digest)
+	SELECT id, '{}', 'off' FROM users WHERE id = $1
+	ON CONFLICT (user_id) DO UPDATE SET digest = 'off', updated_at = now()`
+	res, err := s.db.Exec(ctx, stmt, userID)
+	if err != nil {
+		s.log.ErrorContext(ctx, "DisableDigest: failed to update preferences", "userID", userID, "err", err)
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return fmt.Errorf("user %s: %w", userID, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// ClaimDueDigests picks up to limit users whose digest is due and moves their
+// last_digest_at to now. Rows are claimed with FOR UPDATE SKIP LOCKED, so each
+// digest is sent by one replica only. A digest that fails to send must be
+// released with ReleaseDigest.
+func (s *Storage) ClaimDueDigests(ctx context.Context, limit int) ([]models.DigestRecipient, error) {
+	stmt := `WITH due AS (
+		SELECT user_id, last_digest_at FROM notification_preferences
+		WHERE digest <> 'off' AND (last_digest_at IS NULL OR last_digest_at <= now() -
+			CASE digest WHEN 'daily' THEN interval '1 day' ELSE interval '7 days' END)
+		ORDER BY last_digest_at NULLS FIRST
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	UPDATE notification_preferences np SET last_digest_at = now()
+	FROM due
+	JOIN users u ON u.id = due.user_id
+	WHERE np.user_id = due.user_id
+	RETURNING np.user_id, u.username, u.email, np.preferences, np.digest,
+		COALESCE(due.last_digest_at, now() - CASE np.digest WHEN 'daily' THEN interval '1 day' ELSE interval '7 days' END),
+		due.last_digest_at`
+
+	rows, err := s.db.Query(ctx, stmt, limit)
+	if err != nil {
+		s.log.ErrorContext(ctx, "ClaimDueDigests: failed to claim digests", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []models.DigestRecipient
+	for rows.Next() {
+		var r models.DigestRecipient
+		var data []byte
+		var digest string
+		if err := rows.Scan(&r.UserID, &r.Username, &r.Email, &data, &digest, &r.Since, &r.LastDigestAt); err != nil {
+			return nil, fmt.Errorf("failed to scan digest recipient: %v", err)
+		}
+		if err := json.Unmarshal(data, &r.Preferences); err != nil {
+			s.log.ErrorContext(ctx, "ClaimDueDigests: invalid preferences", "userID", r.UserID, "err", err)
+			continue
+		}
+		r.Preferences.Digest = digest
+		recipients = append(recipients, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	return recipients, nil
+}
+
+// ReleaseDigest gives back a digest claimed by ClaimDueDigests that was not
+// sent, restoring its last_digest_at so the digest is due again.
+func (s *Storage) ReleaseDigest(ctx context.Context, userID string, lastDigestAt *time.Time) error {
+	stmt := `UPDATE notification_preferences SET last_digest_at = $2 WHERE user_id = $1`
+	if _, err := s.db.Exec(ctx, stmt, userID, lastDigestAt); err != nil {
+		s.log.ErrorContext(ctx, "ReleaseDigest: failed to restore last digest time", "userID", userID, "err", err)
+		return err
+	}
+
+	return nil
+}
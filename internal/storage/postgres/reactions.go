@@ -0,0 +1,199 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+)
+
+// ReactionTargetExists checks that a post or comment exists and belongs to a
+// post viewerID may see, with no block between viewerID and its author.
+// Messages are rejected until the chat stores them where they can be checked.
+func (s *Storage) ReactionTargetExists(ctx context.Context, target string, targetID int, viewerID string) error {
+	var q listQuery
+	var from string
+	switch target {
+	case models.TargetPost:
+		from = "posts p"
+		q.and("p.id = " + q.arg(targetID))
+	case models.TargetComment:
+		from = "comments c JOIN posts p ON c.post_id = p.id"
+		q.and("c.id = " + q.arg(targetID))
+		q.and("c.deleted_at IS NULL")
+		q.and(notBlocked(&q, viewerID, "c.user_id"))
+	default:
+		return fmt.Errorf("reaction target %q: %w", target, storage.ErrNotFound)
+	}
+	q.and("p.deleted_at IS NULL")
+	q.and(visiblePost(&q, viewerID))
+	q.and(notBlocked(&q, viewerID, "p.user_id"))
+
+	stmt := `SELECT EXISTS (SELECT 1 FROM ` + from + q.whereClause() + `)`
+
+	var exists bool
+	if err := s.db.QueryRow(ctx, stmt, q.args...).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check %s %d: %v", target, targetID, err)
+	}
+	if !exists {
+		return fmt.Errorf("%s with ID %d: %w", target, targetID, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// GetReactionTargetAuthorID returns the author of a post or comment, and an
+// empty string for other targets.
+func (s *Storage) GetReactionTargetAuthorID(ctx context.Context, target string, targetID int) (string, error) {
+	var stmt string
+	switch target {
+	case models.TargetPost:
+		stmt = `SELECT user_id FROM posts WHERE id = $1`
+	case models.TargetComment:
+		stmt = `SELECT user_id FROM comments WHERE id = $1`
+	default:
+		return "", nil
+	}
+
+	var userID string
+	if err := s.db.QueryRow(ctx, stmt, targetID).Scan(&userID); err != nil {
+		return "", fmt.Errorf("failed to get author of %s %d: %v", target, targetID, err)
+	}
+
+	return userID, nil
+}
+
+// AddReaction stores the reaction; a trigger bumps its counter. It reports
+// false when the user already reacted with this emoji.
+func (s *Storage) AddReaction(ctx context.Context, target string, targetID int, userID, emoji string) (bool, error) {
+	stmt := `INSERT INTO reactions (target_type, target_id, user_id, emoji) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (target_type, target_id, user_id, emoji) DO NOTHING`
+	res, err := s.db.Exec(ctx, stmt, target, targetID, userID, emoji)
+	if err != nil {
+		s.log.ErrorContext(ctx, "AddReaction: failed to insert reaction", "target", target, "targetID", targetID, "err", err)
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
+}
+
+// RemoveReaction deletes the reaction; a trigger decrements its counter. It
+// reports false when there was nothing to remove.
+func (s *Storage) RemoveReaction(ctx context.Context, target string, targetID int, userID, emoji string) (bool, error) {
+	stmt := `DELETE FROM reactions WHERE target_type = $1 AND target_id = $2 AND user_id = $3 AND emoji = $4`
+	res, err := s.db.Exec(ctx, stmt, target, targetID, userID, emoji)
+	if err != nil {
+		s.log.ErrorContext(ctx, "RemoveReaction: failed to delete reaction", "target", target, "targetID", targetID, "err", err)
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
+}
+
+// GetReactionCounts returns the non-zero reaction counters of the given
+// targets, keyed by target id, marking the emoji viewerID reacted with.
+// Reactions of users blocked in either direction are not counted, so the
+// counters agree with GetReactors.
+func (s *Storage) GetReactionCounts(ctx context.Context, target string, targetIDs []int, viewerID string) (map[int][]models.ReactionCount, error) {
+	counts := make(map[int][]models.ReactionCount, len(targetIDs))
+	if len(targetIDs) == 0 {
+		return counts, nil
+	}
+
+	stmt := `SELECT target_id, emoji, count, reacted FROM (
+		SELECT rc.target_id, rc.emoji,
+			rc.count - (
+				SELECT count(*) FROM reactions r
+				WHERE r.target_type = rc.target_type AND r.target_id = rc.target_id AND r.emoji = rc.emoji
+					AND EXISTS (SELECT 1 FROM user_blocks ub
+						WHERE (ub.user_id::text = $3 AND ub.target_id = r.user_id) OR (ub.user_id = r.user_id AND ub.target_id::text = $3))
+			) AS count,
+			EXISTS (
+				SELECT 1 FROM reactions r
+				WHERE r.target_type = rc.target_type AND r.target_id = rc.target_id
+					AND r.emoji = rc.emoji AND r.user_id::text = $3
+			) AS reacted
+		FROM reaction_counts rc
+		WHERE rc.target_type = $1 AND rc.target_id = ANY($2) AND rc.count > 0
+	) counts
+	WHERE count > 0
+	ORDER BY target_id, count DESC, emoji`
+
+	rows, err := s.db.Query(ctx, stmt, target, targetIDs, viewerID)
+	if err != nil {
+		s.log.ErrorContext(ctx, "GetReactionCounts: failed to fetch counters", "target", target, "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var rc models.ReactionCount
+		if err := rows.Scan(&id, &rc.Emoji, &rc.Count, &rc.Reacted); err != nil {
+			return nil, fmt.Errorf("failed to scan reaction count: %v", err)
+		}
+		counts[id] = append(counts[id], rc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	return counts, nil
+}
+
+var reactorKeyset = keyset{
+	columns: map[string]sortColumn{
+		"id": {expr: "r.id", cast: "bigint"},
+	},
+	id: sortColumn{expr: "r.id", cast: "bigint"},
+}
+
+func (s *Storage) GetReactors(ctx context.Context, target string, targetID int, emoji string, viewerID string, page pagination.Page) ([]models.Reactor, string, error) {
+	var q listQuery
+	q.and("r.target_type = " + q.arg(target))
+	q.and("r.target_id = " + q.arg(targetID))
+	q.and("r.emoji = " + q.arg(emoji))
+	q.and(notBlocked(&q, viewerID, "r.user_id"))
+
+	order, err := reactorKeyset.paginate(&q, page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	stmt := `SELECT r.id, u.id, u.username, u.avatar, TO_CHAR(r.created_at, 'YYYY-MM-DD HH24:MI:SS')
+	FROM reactions r
+	JOIN users u ON r.user_id = u.id` + q.whereClause() + order
+
+	rows, err := s.reader(viewerID).Query(ctx, stmt, q.args...)
+	if err != nil {
+		s.log.ErrorContext(ctx, "GetReactors: failed to fetch reactors", "target", target, "targetID", targetID, "err", err)
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	reactors := []models.Reactor{}
+	var keys []rowKey
+
+	for rows.Next() {
+		var reactor models.Reactor
+		var id int64
+		if err := rows.Scan(&id, &reactor.UserID, &reactor.Username, &reactor.Avatar, &reactor.CreateAt); err != nil {
+			return nil, "", fmt.Errorf("failed to scan reactor: %v", err)
+		}
+		key := strconv.FormatInt(id, 10)
+		reactors = append(reactors, reactor)
+		keys = append(keys, rowKey{value: key, id: key})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	reactors, next := trimPage(page, reactors, keys)
+
+	return reactors, next, nil
+}
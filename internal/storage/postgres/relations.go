@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// relationTables maps relation kinds to their tables.
+var relationTables = map[string]string{
+	models.RelationBlock: "user_blocks",
+	models.RelationMute:  "user_mutes",
+}
+
+// notBlocked is the condition hiding rows of users who blocked the viewer or
+// were blocked by them. userExpr is the column holding the other user.
+func notBlocked(q *listQuery, viewerID, userExpr string) string {
+	v := q.arg(viewerID)
+	return `NOT EXISTS (SELECT 1 FROM user_blocks ub
+		WHERE (ub.user_id::text = ` + v + ` AND ub.target_id = ` + userExpr + `)
+			OR (ub.user_id = ` + userExpr + ` AND ub.target_id::text = ` + v + `))`
+}
+
+// notMuted is the condition hiding rows of users the viewer muted.
+func notMuted(q *listQuery, viewerID, userExpr string) string {
+	return `NOT EXISTS (SELECT 1 FROM user_mutes um
+		WHERE um.user_id::text = ` + q.arg(viewerID) + ` AND um.target_id = ` + userExpr + `)`
+}
+
+// AddRelation blocks or mutes targetID on behalf of userID. Doing it twice is
+// a no-op.
+func (s *Storage) AddRelation(ctx context.Context, kind, userID, targetID string) error {
+	table, ok := relationTables[kind]
+	if !ok {
+		return fmt.Errorf("relation %q: %w", kind, storage.ErrNotFound)
+	}
+
+	stmt := `INSERT INTO ` + table + ` (user_id, target_id) VALUES ($1, $2) ON CONFLICT (user_id, target_id) DO NOTHING`
+	if _, err := s.db.Exec(ctx, stmt, userID, targetID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && (pgErr.Code == "23503" || pgErr.Code == "22P02") {
+			return fmt.Errorf("user %s: %w", targetID, storage.ErrNotFound)
+		}
+		s.log.ErrorContext(ctx, "AddRelation: failed to insert relation", "kind", kind, "userID", userID, "err", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) RemoveRelation(ctx context.Context, kind, userID, targetID string) error {
+	table, ok := relationTables[kind]
+	if !ok {
+		return fmt.Errorf("relation %q: %w", kind, storage.ErrNotFound)
+	}
+
+	stmt := `DELETE FROM ` + table + ` WHERE user_id = $1 AND target_id::text = $2`
+	if _, err := s.db.Exec(ctx, stmt, userID, targetID); err != nil {
+		s.log.ErrorContext(ctx, "RemoveRelation: failed to delete relation", "kind", kind, "userID", userID, "err", err)
+		return err
+	}
+
+	return nil
+}
+
+var relationKeyset = keyset{
+	columns: map[string]sortColumn{
+		"id": {expr: "r.id", cast: "bigint"},
+	},
+	id: sortColumn{expr: "r.id", cast: "bigint"},
+}
+
+// GetRelatedUsers lists the users userID blocked or muted, newest first by default.
+func (s *Storage) GetRelatedUsers(ctx context.Context, kind, userID string, page pagination.Page) ([]models.RelatedUser, string, error) {
+	table, ok := relationTables[kind]
+	if !ok {
+		return nil, "", fmt.Errorf("relation %q: %w", kind, storage.ErrNotFound)
+	}
+
+	var q listQuery
+	q.and("r.user_id = " + q.arg(userID))
+
+	order, err := relationKeyset.paginate(&q, page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	stmt := `SELECT r.id, u.id, u.username, u.avatar, TO_CHAR(r.created_at, 'YYYY-MM-DD HH24:MI:SS')
+	FROM ` + table + ` r
+	JOIN users u ON r.target_id = u.id` + q.whereClause() + order
+
+	rows, err := s.db.Query(ctx, stmt, q.args...)
+	if err != nil {
+		s.log.ErrorContext(ctx, "GetRelatedUsers: failed to fetch users", "kind", kind, "userID", userID, "err", err)
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	users := []models.RelatedUser{}
+	var keys []rowKey
+
+	for rows.Next() {
+		var u models.RelatedUser
+		var id int64
+		if err := rows.Scan(&id, &u.UserID, &u.Username, &u.Avatar, &u.CreateAt); err != nil {
+			return nil, "", fmt.Errorf("failed to scan user: %v", err)
+		}
+		key := strconv.FormatInt(id, 10)
+		users = append(users, u)
+		keys = append(keys, rowKey{value: key, id: key})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	users, next := trimPage(page, users, keys)
+
+	return users, next, nil
+}
+
+// GetRelation tells whether userID and otherID blocked each other and whether
+// userID muted otherID.
+func (s *Storage) GetRelation(ctx context.Context, userID, otherID string) (models.Relation, error) {
+	var rel models.Relation
+
+	stmt := `SELECT
+		EXISTS (SELECT 1 FROM user_blocks
+			WHERE (user_id::text = $1 AND target_id::text = $2) OR (user_id::text = $2 AND target_id::text = $1)),
+		EXISTS (SELECT 1 FROM user_mutes WHERE user_id::text = $1 AND target_id::text = $2)`
+	if err := s.db.QueryRow(ctx, stmt, userID, otherID).Scan(&rel.Blocked, &rel.Muted); err != nil {
+		return rel, fmt.Errorf("failed to get relation between %s and %s: %v", userID, otherID, err)
+	}
+
+	return rel, nil
+}
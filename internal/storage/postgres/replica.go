@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// replicaRetryInterval is how long reads stay on the primary after the
+// replica could not be reached.
+const replicaRetryInterval = 10 * time.Second
+
+// replicaPool runs queries on a read replica and falls back to the primary
+// while the replica is unreachable, so an outage of the replica slows reads
+// down instead of failing them. Everything but Query goes to the primary.
+type replicaPool struct {
+	PgxPoolIface
+	replica PgxPoolIface
+	log     *slog.Logger
+
+	// downUntil is the Unix time in nanoseconds until which the replica is
+	// skipped.
+	downUntil atomic.Int64
+}
+
+func (p *replicaPool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if time.Now().UnixNano() >= p.downUntil.Load() {
+		rows, err := p.replica.Query(ctx, sql, args...)
+		if err == nil || ctx.Err() != nil || !unreachable(err) {
+			return rows, err
+		}
+		p.downUntil.Store(time.Now().Add(replicaRetryInterval).UnixNano())
+		p.log.WarnContext(ctx, "database replica unreachable, reading from the primary", "retryIn", replicaRetryInterval, "err", err)
+	}
+	return p.PgxPoolIface.Query(ctx, sql, args...)
+}
+
+// unreachable reports whether err means the query never reached the server.
+func unreachable(err error) bool {
+	var connectErr *pgconn.ConnectError
+	return errors.As(err, &connectErr) || pgconn.SafeToRetry(err)
+}
@@ -0,0 +1,521 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+	"github.com/jackc/pgx/v5"
+)
+
+// hideableTables maps the report targets that can be hidden pending review
+// and removed by a moderator to their tables.
+var hideableTables = map[string]string{
+	models.TargetPost:    "posts",
+	models.TargetComment: "comments",
+}
+
+// reportTargetAuthor checks that the reported target exists and that the
+// reporter may see it, and returns the user a suspension would apply to.
+// Drafts, hidden content and content of blocked users are not found, the
+// same way the read paths leave them out. Flags raised by the content filter
+// have no reporter and skip the visibility checks.
+func reportTargetAuthor(ctx context.Context, tx pgx.Tx, target, targetID, reporterID string) (string, error) {
+	if target == models.TargetUser {
+		var authorID string
+		err := tx.QueryRow(ctx, `SELECT id::text FROM users WHERE id::text = $1`, targetID).Scan(&authorID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return "", fmt.Errorf("%s with ID %s: %w", target, targetID, storage.ErrNotFound)
+			}
+			return "", fmt.Errorf("failed to get %s %s: %v", target, targetID, err)
+		}
+		return authorID, nil
+	}
+
+	id, err := strconv.Atoi(targetID)
+	if err != nil {
+		return "", fmt.Errorf("%s with ID %s: %w", target, targetID, storage.ErrNotFound)
+	}
+
+	var q listQuery
+	var author, from string
+	switch target {
+	case models.TargetPost:
+		author, from = "p.user_id", "posts p"
+		q.and("p.id = " + q.arg(id))
+	case models.TargetComment:
+		author, from = "c.user_id", "comments c JOIN posts p ON c.post_id = p.id"
+		q.and("c.id = " + q.arg(id))
+		q.and("c.deleted_at IS NULL")
+		if reporterID != "" {
+			q.and("(c.hidden_at IS NULL OR c.user_id::text = " + q.arg(reporterID) + ")")
+			q.and(notBlocked(&q, reporterID, "c.user_id"))
+		}
+	default:
+		return "", fmt.Errorf("report target %q: %w", target, storage.ErrNotFound)
+	}
+	q.and("p.deleted_at IS NULL")
+	if reporterID != "" {
+		q.and(visiblePost(&q, reporterID))
+		q.and(notBlocked(&q, reporterID, "p.user_id"))
+	}
+
+	var authorID string
+	stmt := `SELECT ` + author + `::text FROM ` + from + q.whereClause()
+	if err := tx.QueryRow(ctx, stmt, q.args...).Scan(&authorID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("%s with ID %s: %w", target, targetID, storage.ErrNotFound)
+		}
+		return "", fmt.Errorf("failed to get author of %s %s: %v", target, targetID, err)
+	}
+
+	return authorID, nil
+}
+
+// CreateReport files the report under the case of its target, opening or
+// reopening the case as needed. It reports false when the reporter already
+// reported the target. Once the case collects hideThreshold reports since its
+// last resolution, a post or comment is hidden pending review; a threshold of
+// zero disables hiding.
+func (s *Storage) CreateReport(ctx context.Context, report *models.Report, hideThreshold int) (bool, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	authorID, err := reportTargetAuthor(ctx, tx, report.TargetType, report.TargetID, report.ReporterID)
+	if err != nil {
+		return false, err
+	}
+
+	var caseID int64
+	stmt := `INSERT INTO moderation_cases (target_type, target_id, author_id) VALUES ($1, $2, $3)
+	ON CONFLICT (target_type, target_id) DO UPDATE SET updated_at = moderation_cases.updated_at
+	RETURNING id`
+	if err := tx.QueryRow(ctx, stmt, report.TargetType, report.TargetID, authorID).Scan(&caseID); err != nil {
+		s.log.ErrorContext(ctx, "CreateReport: failed to open case", "target", report.TargetType, "targetID", report.TargetID, "err", err)
+		return false, err
+	}
+
+	stmt = `INSERT INTO reports (case_id, reporter_id, reason, details) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (case_id, reporter_id) DO NOTHING
+	RETURNING id, TO_CHAR(created_at, 'YYYY-MM-DD HH24:MI:SS')`
+	err = tx.QueryRow(ctx, stmt, caseID, report.ReporterID, report.Reason, report.Details).Scan(&report.ID, &report.CreateAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		s.log.ErrorContext(ctx, "CreateReport: failed to insert report", "caseID", caseID, "err", err)
+		return false, err
+	}
+
+	var pending int
+	stmt = `UPDATE moderation_cases SET reports_count = reports_count + 1, updated_at = now(),
+		status = CASE WHEN status = 'resolved' THEN 'open' ELSE status END,
+		moderator_id = CASE WHEN status = 'resolved' THEN NULL ELSE moderator_id END,
+		resolution = CASE WHEN status = 'resolved' THEN NULL ELSE resolution END
+	WHERE id = $1
+	RETURNING (SELECT count(*) FROM reports r WHERE r.case_id = $1 AND r.created_at > COALESCE(resolved_at, '-infinity'))`
+	if err := tx.QueryRow(ctx, stmt, caseID).Scan(&pending); err != nil {
+		return false, fmt.Errorf("failed to update case %d: %v", caseID, err)
+	}
+
+	if table, ok := hideableTables[report.TargetType]; ok && hideThreshold > 0 && pending >= hideThreshold {
+		// reportTargetAuthor has already checked that the id is a number.
+		id, _ := strconv.Atoi(report.TargetID)
+		stmt = `UPDATE ` + table + ` SET hidden_at = now() WHERE id = $1 AND hidden_at IS NULL`
+		res, err := tx.Exec(ctx, stmt, id)
+		if err != nil {
+			return false, fmt.Errorf("failed to hide %s %s: %v", report.TargetType, report.TargetID, err)
+		}
+		if res.RowsAffected() > 0 {
+			note := fmt.Sprintf("hidden after %d reports", pending)
+			if err := insertModerationAction(ctx, tx, caseID, nil, models.ActionAutoHide, note); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit report: %v", err)
+	}
+
+	return true, nil
+}
+
+// FlagContent sends a post or comment the content filter flagged to the
+// moderator queue and hides it pending review, without a report of any user.
+func (s *Storage) FlagContent(ctx context.Context, target, targetID, note string) error {
+	table, ok := hideableTables[target]
+	if !ok {
+		return fmt.Errorf("can't flag %s: %w", target, storage.ErrInvalidResolution)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	authorID, err := reportTargetAuthor(ctx, tx, target, targetID, "")
+	if err != nil {
+		return err
+	}
+
+	var caseID int64
+	stmt := `INSERT INTO moderation_cases (target_type, target_id, author_id) VALUES ($1, $2, $3)
+	ON CONFLICT (target_type, target_id) DO UPDATE SET updated_at = now(),
+		status = CASE WHEN moderation_cases.status = 'resolved' THEN 'open' ELSE moderation_cases.status END,
+		moderator_id = CASE WHEN moderation_cases.status = 'resolved' THEN NULL ELSE moderation_cases.moderator_id END,
+		resolution = CASE WHEN moderation_cases.status = 'resolved' THEN NULL ELSE moderation_cases.resolution END
+	RETURNING id`
+	if err := tx.QueryRow(ctx, stmt, target, targetID, authorID).Scan(&caseID); err != nil {
+		s.log.ErrorContext(ctx, "FlagContent: failed to open case", "target", target, "targetID", targetID, "err", err)
+		return err
+	}
+
+	id, _ := strconv.Atoi(targetID)
+	stmt = `UPDATE ` + table + ` SET hidden_at = now() WHERE id = $1 AND hidden_at IS NULL`
+	if _, err := tx.Exec(ctx, stmt, id); err != nil {
+		return fmt.Errorf("failed to hide %s %s: %v", target, targetID, err)
+	}
+	if err := insertModerationAction(ctx, tx, caseID, nil, models.ActionAutoFlag, note); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit flag: %v", err)
+	}
+
+	return nil
+}
+
+func insertModerationAction(ctx context.Context, tx pgx.Tx, caseID int64, moderatorID *string, action, note string) error {
+	stmt := `INSERT INTO moderation_actions (case_id, moderator_id, action, note) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.Exec(ctx, stmt, caseID, moderatorID, action, note); err != nil {
+		return fmt.Errorf("failed to record %s of case %d: %v", action, caseID, err)
+	}
+	return nil
+}
+
+// caseColumns are the columns of a moderation case in the order of
+// caseFields. Hidden is looked up on the target itself.
+const caseColumns = `mc.id, mc.target_type, mc.target_id, COALESCE(mc.author_id::text, ''), mc.status, mc.reports_count,
+	ARRAY(SELECT DISTINCT r.reason FROM reports r WHERE r.case_id = mc.id ORDER BY r.reason),
+	CASE mc.target_type
+		WHEN 'post' THEN EXISTS (SELECT 1 FROM posts p WHERE p.id = mc.target_id::int AND p.hidden_at IS NOT NULL)
+		WHEN 'comment' THEN EXISTS (SELECT 1 FROM comments c WHERE c.id = mc.target_id::int AND c.hidden_at IS NOT NULL)
+		ELSE false
+	END,
+	COALESCE(mc.moderator_id::text, ''), COALESCE(mc.resolution, ''),
+	TO_CHAR(mc.created_at, 'YYYY-MM-DD HH24:MI:SS'), TO_CHAR(mc.updated_at, 'YYYY-MM-DD HH24:MI:SS')`
+
+func caseFields(c *models.ModerationCase) []any {
+	return []any{&c.ID, &c.TargetType, &c.TargetID, &c.AuthorID, &c.Status, &c.ReportsCount,
+		&c.Reasons, &c.Hidden, &c.ModeratorID, &c.Resolution, &c.CreateAt, &c.UpdateAt}
+}
+
+var caseKeyset = keyset{
+	columns: map[string]sortColumn{
+		"id":            {expr: "mc.id", cast: "bigint"},
+		"reports_count": {expr: "mc.reports_count", cast: "int"},
+	},
+	id: sortColumn{expr: "mc.id", cast: "bigint"},
+}
+
+// GetModerationCases returns a page of the moderator queue. Without a status
+// filter only unresolved cases are listed.
+func (s *Storage) GetModerationCases(ctx context.Context, query storage.CaseQuery) ([]models.ModerationCase, string, error) {
+	var q listQuery
+	if query.Status != "" {
+		q.and("mc.status = " + q.arg(query.Status))
+	} else {
+		q.and("mc.status <> 'resolved'")
+	}
+	if query.TargetType != "" {
+		q.and("mc.target_type = " + q.arg(query.TargetType))
+	}
+	if query.Reason != "" {
+		q.and("EXISTS (SELECT 1 FROM reports r WHERE r.case_id = mc.id AND r.reason = " + q.arg(query.Reason) + ")")
+	}
+	if query.ModeratorID != "" {
+		q.and("mc.moderator_id::text = " + q.arg(query.ModeratorID))
+	}
+
+	page, err := caseKeyset.paginate(&q, query.Page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	stmt := `SELECT ` + caseColumns + `, ` + caseKeyset.sortValue(query.Page) + `
+	FROM moderation_cases mc` + q.whereClause() + page
+
+	rows, err := s.db.Query(ctx, stmt, q.args...)
+	if err != nil {
+		s.log.ErrorContext(ctx, "GetModerationCases: failed to fetch cases", "err", err)
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	cases := []models.ModerationCase{}
+	var keys []rowKey
+
+	for rows.Next() {
+		var c models.ModerationCase
+		var key rowKey
+		if err := rows.Scan(append(caseFields(&c), &key.value)...); err != nil {
+			return nil, "", fmt.Errorf("failed to scan case: %v", err)
+		}
+		key.id = strconv.FormatInt(c.ID, 10)
+		cases = append(cases, c)
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	cases, next := trimPage(query.Page, cases, keys)
+
+	return cases, next, nil
+}
+
+// GetModerationCase returns a case with all of its reports and its audit trail.
+func (s *Storage) GetModerationCase(ctx context.Context, caseID int64) (*models.ModerationCase, error) {
+	var c models.ModerationCase
+
+	stmt := `SELECT ` + caseColumns + ` FROM moderation_cases mc WHERE mc.id = $1`
+	if err := s.db.QueryRow(ctx, stmt, caseID).Scan(caseFields(&c)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("case with ID %d: %w", caseID, storage.ErrNotFound)
+		}
+		s.log.ErrorContext(ctx, "GetModerationCase: failed to fetch case", "caseID", caseID, "err", err)
+		return nil, err
+	}
+
+	stmt = `SELECT id, reporter_id::text, reason, details, TO_CHAR(created_at, 'YYYY-MM-DD HH24:MI:SS')
+	FROM reports WHERE case_id = $1 ORDER BY id`
+	rows, err := s.db.Query(ctx, stmt, caseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reports of case %d: %v", caseID, err)
+	}
+	c.Reports, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Report, error) {
+		r := models.Report{TargetType: c.TargetType, TargetID: c.TargetID}
+		err := row.Scan(&r.ID, &r.ReporterID, &r.Reason, &r.Details, &r.CreateAt)
+		return r, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan report: %v", err)
+	}
+
+	stmt = `SELECT id, case_id, COALESCE(moderator_id::text, ''), action, note, TO_CHAR(created_at, 'YYYY-MM-DD HH24:MI:SS')
+	FROM moderation_actions WHERE case_id = $1 ORDER BY id`
+	rows, err = s.db.Query(ctx, stmt, caseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch actions of case %d: %v", caseID, err)
+	}
+	c.Actions, err = pgx.CollectRows(rows, scanModerationAction)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan action: %v", err)
+	}
+
+	return &c, nil
+}
+
+func scanModerationAction(row pgx.CollectableRow) (models.ModerationAction, error) {
+	var a models.ModerationAction
+	err := row.Scan(&a.ID, &a.CaseID, &a.ModeratorID, &a.Action, &a.Note, &a.CreateAt)
+	return a, err
+}
+
+var actionKeyset = keyset{
+	columns: map[string]sortColumn{
+		"id": {expr: "a.id", cast: "bigint"},
+	},
+	id: sortColumn{expr: "a.id", cast: "bigint"},
+}
+
+// GetModerationActions returns a page of the audit trail of all cases.
+func (s *Storage) GetModerationActions(ctx context.Context, page pagination.Page) ([]models.ModerationAction, string, error) {
+	var q listQuery
+
+	order, err := actionKeyset.paginate(&q, page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	stmt := `SELECT a.id, a.case_id, COALESCE(a.moderator_id::text, ''), a.action, a.note,
+		TO_CHAR(a.created_at, 'YYYY-MM-DD HH24:MI:SS')
+	FROM moderation_actions a` + q.whereClause() + order
+
+	rows, err := s.db.Query(ctx, stmt, q.args...)
+	if err != nil {
+		s.log.ErrorContext(ctx, "GetModerationActions: failed to fetch actions", "err", err)
+		return nil, "", err
+	}
+
+	actions, err := pgx.CollectRows(rows, scanModerationAction)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to scan action: %v", err)
+	}
+
+	keys := make([]rowKey, len(actions))
+	for i, a := range actions {
+		id := strconv.FormatInt(a.ID, 10)
+		keys[i] = rowKey{value: id, id: id}
+	}
+
+	actions, next := trimPage(page, actions, keys)
+
+	return actions, next, nil
+}
+
+// lockedCase is the part of a case claim and resolve decide on, read with the
+// row locked.
+type lockedCase struct {
+	status      string
+	moderatorID string
+	targetType  string
+	targetID    string
+	authorID    string
+}
+
+func lockCase(ctx context.Context, tx pgx.Tx, caseID int64) (lockedCase, error) {
+	var c lockedCase
+
+	stmt := `SELECT status, COALESCE(moderator_id::text, ''), target_type, target_id, COALESCE(author_id::text, '')
+	FROM moderation_cases WHERE id = $1 FOR UPDATE`
+	err := tx.QueryRow(ctx, stmt, caseID).Scan(&c.status, &c.moderatorID, &c.targetType, &c.targetID, &c.authorID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c, fmt.Errorf("case with ID %d: %w", caseID, storage.ErrNotFound)
+		}
+		return c, fmt.Errorf("failed to lock case %d: %v", caseID, err)
+	}
+
+	return c, nil
+}
+
+// checkOwner fails when the case is resolved or claimed by another moderator.
+func (c lockedCase) checkOwner(caseID int64, moderatorID string) error {
+	switch {
+	case c.status == models.CaseResolved:
+		return fmt.Errorf("case %d: %w", caseID, storage.ErrCaseResolved)
+	case c.status == models.CaseClaimed && c.moderatorID != moderatorID:
+		return fmt.Errorf("case %d: %w", caseID, storage.ErrCaseClaimed)
+	}
+	return nil
+}
+
+// ClaimCase assigns an open case to the moderator. Claiming a case twice is a
+// no-op.
+func (s *Storage) ClaimCase(ctx context.Context, caseID int64, moderatorID string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	c, err := lockCase(ctx, tx, caseID)
+	if err != nil {
+		return err
+	}
+	if err := c.checkOwner(caseID, moderatorID); err != nil {
+		return err
+	}
+	if c.status == models.CaseClaimed {
+		return nil
+	}
+
+	stmt := `UPDATE moderation_cases SET status = 'claimed', moderator_id = $2, updated_at = now() WHERE id = $1`
+	if _, err := tx.Exec(ctx, stmt, caseID, moderatorID); err != nil {
+		s.log.ErrorContext(ctx, "ClaimCase: failed to claim case", "caseID", caseID, "err", err)
+		return err
+	}
+	if err := insertModerationAction(ctx, tx, caseID, &moderatorID, models.ActionClaim, ""); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit claim: %v", err)
+	}
+
+	return nil
+}
+
+// ResolveCase closes the case with the action and applies it: dismissing
+// unhides the target, removing soft-deletes it and suspending locks its author
+// out and ends their sessions.
+func (s *Storage) ResolveCase(ctx context.Context, caseID int64, moderatorID, action, note string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	c, err := lockCase(ctx, tx, caseID)
+	if err != nil {
+		return err
+	}
+	if err := c.checkOwner(caseID, moderatorID); err != nil {
+		return err
+	}
+
+	table, hideable := hideableTables[c.targetType]
+	targetID, _ := strconv.Atoi(c.targetID)
+	switch action {
+	case models.ActionDismiss:
+		if hideable {
+			stmt := `UPDATE ` + table + ` SET hidden_at = NULL WHERE id = $1`
+			if _, err := tx.Exec(ctx, stmt, targetID); err != nil {
+				return fmt.Errorf("failed to unhide %s %s: %v", c.targetType, c.targetID, err)
+			}
+		}
+	case models.ActionRemoveContent:
+		if !hideable {
+			return fmt.Errorf("can't remove %s: %w", c.targetType, storage.ErrInvalidResolution)
+		}
+		stmt := `UPDATE ` + table + ` SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`
+		if _, err := tx.Exec(ctx, stmt, targetID); err != nil {
+			return fmt.Errorf("failed to remove %s %s: %v", c.targetType, c.targetID, err)
+		}
+	case models.ActionSuspendUser:
+		if c.authorID == "" {
+			return fmt.Errorf("%s has no author: %w", c.targetType, storage.ErrInvalidResolution)
+		}
+		stmt := `UPDATE users SET suspended_at = now() WHERE id = $1 AND suspended_at IS NULL`
+		if _, err := tx.Exec(ctx, stmt, c.authorID); err != nil {
+			return fmt.Errorf("failed to suspend user %s: %v", c.authorID, err)
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM sessions WHERE user_id = $1`, c.authorID); err != nil {
+			return fmt.Errorf("failed to end sessions of user %s: %v", c.authorID, err)
+		}
+	default:
+		return fmt.Errorf("action %q: %w", action, storage.ErrInvalidResolution)
+	}
+
+	stmt := `UPDATE moderation_cases SET status = 'resolved', resolution = $3, moderator_id = $2,
+		resolved_at = now(), updated_at = now()
+	WHERE id = $1`
+	if _, err := tx.Exec(ctx, stmt, caseID, moderatorID, action); err != nil {
+		s.log.ErrorContext(ctx, "ResolveCase: failed to resolve case", "caseID", caseID, "err", err)
+		return err
+	}
+	if err := insertModerationAction(ctx, tx, caseID, &moderatorID, action, note); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit resolution: %v", err)
+	}
+
+	s.log.InfoContext(ctx, "moderation case resolved", "caseID", caseID, "action", action, "moderatorID", moderatorID)
+
+	return nil
+}
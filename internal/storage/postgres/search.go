@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+)
+
+// ts_headline wraps matches in these control characters instead of tags, so
+// the fragment can be HTML-escaped before the <mark> tags are put in.
+const (
+	markStart = "\x02"
+	markStop  = "\x03"
+
+	headlineOptions = `'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxFragments=2, MaxWords=30, MinWords=10'`
+)
+
+var markReplacer = strings.NewReplacer(markStart, "<mark>", markStop, "</mark>")
+
+func markHighlights(s string) string {
+	return markReplacer.Replace(html.EscapeString(s))
+}
+
+// highlightSubstring escapes s and wraps every case-insensitive occurrence of
+// text in <mark>.
+func highlightSubstring(s, text string) string {
+	re, err := regexp.Compile("(?i)" + regexp.QuoteMeta(text))
+	if err != nil || text == "" {
+		return html.EscapeString(s)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringIndex(s, -1) {
+		b.WriteString(html.EscapeString(s[last:m[0]]))
+		b.WriteString("<mark>" + html.EscapeString(s[m[0]:m[1]]) + "</mark>")
+		last = m[1]
+	}
+	b.WriteString(html.EscapeString(s[last:]))
+
+	return b.String()
+}
+
+// searchKeyset orders results by a rank expression that depends on the query
+// text, so the keyset is built per request.
+func searchKeyset(rank string, id sortColumn) keyset {
+	return keyset{
+		columns: map[string]sortColumn{"rank": {expr: rank, cast: "real"}},
+		id:      id,
+	}
+}
+
+// SearchPosts runs a Russian full-text search over post titles and contents.
+func (s *Storage) SearchPosts(ctx context.Context, query storage.SearchQuery) ([]models.PostSearchResult, string, error) {
+	var q listQuery
+	tsquery := "websearch_to_tsquery('russian', " + q.arg(query.Text) + ")"
+	q.and("p.search_vector @@ " + tsquery)
+	q.and("p.deleted_at IS NULL")
+	q.and("p.status = 'published'")
+	q.and("p.hidden_at IS NULL")
+	q.and(notBlocked(&q, query.ViewerID, "p.user_id"))
+
+	ks := searchKeyset("ts_rank_cd(p.search_vector, "+tsquery+")", sortColumn{expr: "p.id", cast: "int"})
+	page, err := ks.paginate(&q, query.Page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	stmt := `SELECT p.id, p.title, p.content, p.content_format,
+		TO_CHAR(p.created_at, 'YYYY-MM-DD HH24:MI:SS'),
+		TO_CHAR(p.updated_at, 'YYYY-MM-DD HH24:MI:SS'),
+		COALESCE(TO_CHAR(p.edited_at, 'YYYY-MM-DD HH24:MI:SS'), ''),
+		u.username, u.avatar,
+		(SELECT count(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL),
+		` + ks.columns["rank"].expr + `,
+		ts_headline('russian', p.title, ` + tsquery + `, 'HighlightAll=true, StartSel=' || chr(2) || ', StopSel=' || chr(3)),
+		ts_headline('russian', p.content, ` + tsquery + `, ` + headlineOptions + `),
+		` + ks.sortValue(query.Page) + `
+	FROM posts p
+	JOIN users u ON p.user_id = u.id` + q.whereClause() + page
+
+	rows, err := s.reader(query.ViewerID).Query(ctx, stmt, q.args...)
+	if err != nil {
+		s.log.ErrorContext(ctx, "SearchPosts: failed to search posts", "err", err)
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	results := []models.PostSearchResult{}
+	var keys []rowKey
+
+	for rows.Next() {
+		var res models.PostSearchResult
+		var key rowKey
+		if err := rows.Scan(&res.ID, &res.Title, &res.Content, &res.Format, &res.CreateAt, &res.UpdatedAt, &res.EditedAt,
+			&res.Username, &res.Avatar, &res.CommentsCount, &res.Rank,
+			&res.TitleHighlight, &res.ContentHighlight, &key.value); err != nil {
+			return nil, "", fmt.Errorf("failed to scan post: %v", err)
+		}
+		res.TitleHighlight = markHighlights(res.TitleHighlight)
+		res.ContentHighlight = markHighlights(res.ContentHighlight)
+		key.id = strconv.Itoa(res.ID)
+		results = append(results, res)
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	results, next := trimPage(query.Page, results, keys)
+
+	return results, next, nil
+}
+
+// likeEscaper escapes the wildcards of LIKE, so text is matched literally
+// with ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchUsers matches users by name and username with trigram similarity,
+// falling back to a plain substring match for short queries.
+func (s *Storage) SearchUsers(ctx context.Context, query storage.SearchQuery) ([]models.UserSearchResult, string, error) {
+	var q listQuery
+	text := q.arg(query.Text)
+	substring := q.arg("%" + likeEscaper.Replace(query.Text) + "%")
+	q.and("(u.username % " + text + " OR u.name % " + text +
+		" OR u.username ILIKE " + substring + " ESCAPE '\\' OR u.name ILIKE " + substring + " ESCAPE '\\')")
+	q.and(notBlocked(&q, query.ViewerID, "u.id"))
+
+	ks := searchKeyset("GREATEST(similarity(u.username, "+text+"), similarity(u.name, "+text+"))",
+		sortColumn{expr: "u.id", cast: "uuid"})
+	page, err := ks.paginate(&q, query.Page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	stmt := `SELECT u.id, u.name, u.username, u.avatar, ` + ks.columns["rank"].expr + `, ` + ks.sortValue(query.Page) + `
+	FROM users u` + q.whereClause() + page
+
+	rows, err := s.reader(query.ViewerID).Query(ctx, stmt, q.args...)
+	if err != nil {
+		s.log.ErrorContext(ctx, "SearchUsers: failed to search users", "err", err)
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	results := []models.UserSearchResult{}
+	var keys []rowKey
+
+	for rows.Next() {
+		var res models.UserSearchResult
+		var key rowKey
+		if err := rows.Scan(&res.UserID, &res.Name, &res.Username, &res.Avatar, &res.Rank, &key.value); err != nil {
+			return nil, "", fmt.Errorf("failed to scan user: %v", err)
+		}
+		res.NameHighlight = highlightSubstring(res.Name, query.Text)
+		res.UsernameHighlight = highlightSubstring(res.Username, query.Text)
+		key.id = res.UserID
+		results = append(results, res)
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	results, next := trimPage(query.Page, results, keys)
+
+	return results, next, nil
+}
@@ -0,0 +1,347 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+	"github.com/jackc/pgx/v5"
+)
+
+// visiblePostExists checks that the post exists and viewerID may see it.
+// With an empty viewerID only published posts pass.
+func (s *Storage) visiblePostExists(ctx context.Context, postID int, viewerID string) error {
+	_, err := s.GetVisiblePostAuthorID(ctx, postID, viewerID)
+	return err
+}
+
+// AddBookmark bookmarks a post for userID. Bookmarking twice is a no-op.
+func (s *Storage) AddBookmark(ctx context.Context, userID string, postID int) error {
+	if err := s.visiblePostExists(ctx, postID, userID); err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO bookmarks (user_id, post_id) VALUES ($1, $2) ON CONFLICT (user_id, post_id) DO NOTHING`
+	if _, err := s.db.Exec(ctx, stmt, userID, postID); err != nil {
+		s.log.ErrorContext(ctx, "AddBookmark: failed to insert bookmark", "postID", postID, "err", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) RemoveBookmark(ctx context.Context, userID string, postID int) error {
+	stmt := `DELETE FROM bookmarks WHERE user_id = $1 AND post_id = $2`
+	if _, err := s.db.Exec(ctx, stmt, userID, postID); err != nil {
+		s.log.ErrorContext(ctx, "RemoveBookmark: failed to delete bookmark", "postID", postID, "err", err)
+		return err
+	}
+
+	return nil
+}
+
+var bookmarkKeyset = keyset{
+	columns: map[string]sortColumn{
+		"created_at": {expr: "b.created_at", cast: "timestamp"},
+	},
+	id: sortColumn{expr: "b.id", cast: "bigint"},
+}
+
+// GetBookmarks returns the posts bookmarked by userID, ordered by the time they
+// were bookmarked. Posts deleted or unpublished since then are left out.
+func (s *Storage) GetBookmarks(ctx context.Context, userID string, page pagination.Page) ([]models.Post, string, error) {
+	var q listQuery
+	q.and("b.user_id = " + q.arg(userID))
+	q.and("p.deleted_at IS NULL")
+	q.and(visiblePost(&q, userID))
+	q.and(notBlocked(&q, userID, "p.user_id"))
+
+	order, err := bookmarkKeyset.paginate(&q, page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	stmt := `SELECT ` + postColumns + `, b.id, ` + bookmarkKeyset.sortValue(page) + `
+	FROM bookmarks b
+	JOIN posts p ON b.post_id = p.id
+	JOIN users u ON p.user_id = u.id` + q.whereClause() + order
+
+	rows, err := s.db.Query(ctx, stmt, q.args...)
+	if err != nil {
+		s.log.ErrorContext(ctx, "GetBookmarks: failed to fetch bookmarks", "err", err)
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	posts := []models.Post{}
+	var keys []rowKey
+
+	for rows.Next() {
+		var post models.Post
+		var id int64
+		var key rowKey
+		if err := rows.Scan(append(postFields(&post), &id, &key.value)...); err != nil {
+			return nil, "", fmt.Errorf("failed to scan bookmark: %v", err)
+		}
+		key.id = strconv.FormatInt(id, 10)
+		posts = append(posts, post)
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	posts, next := trimPage(page, posts, keys)
+
+	return posts, next, nil
+}
+
+// CreateRepost reposts a published post and fills in the id and time of the
+// repost. It reports false when the user already reposted the post.
+func (s *Storage) CreateRepost(ctx context.Context, repost *models.Repost, userID string) (bool, error) {
+	if err := s.visiblePostExists(ctx, repost.Post.ID, ""); err != nil {
+		return false, err
+	}
+
+	stmt := `INSERT INTO reposts (user_id, post_id, quote) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id, post_id) DO NOTHING
+	RETURNING id, TO_CHAR(created_at, 'YYYY-MM-DD HH24:MI:SS')`
+	err := s.db.QueryRow(ctx, stmt, userID, repost.Post.ID, repost.Quote).Scan(&repost.ID, &repost.CreateAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		s.log.ErrorContext(ctx, "CreateRepost: failed to insert repost", "postID", repost.Post.ID, "err", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// DeleteRepost undoes the repost of a post by userID. It reports false when
+// there was nothing to undo.
+func (s *Storage) DeleteRepost(ctx context.Context, userID string, postID int) (bool, error) {
+	stmt := `DELETE FROM reposts WHERE user_id = $1 AND post_id = $2`
+	res, err := s.db.Exec(ctx, stmt, userID, postID)
+	if err != nil {
+		s.log.ErrorContext(ctx, "DeleteRepost: failed to delete repost", "postID", postID, "err", err)
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
+}
+
+// GetPostShareCounts returns the repost and bookmark counters of the given
+// posts, keyed by post id, with the flags of viewerID.
+func (s *Storage) GetPostShareCounts(ctx context.Context, postIDs []int, viewerID string) (map[int]models.ShareCounts, error) {
+	counts := make(map[int]models.ShareCounts, len(postIDs))
+	if len(postIDs) == 0 {
+		return counts, nil
+	}
+
+	stmt := `SELECT p.id,
+		(SELECT count(*) FROM reposts r WHERE r.post_id = p.id),
+		(SELECT count(*) FROM bookmarks b WHERE b.post_id = p.id),
+		EXISTS (SELECT 1 FROM reposts r WHERE r.post_id = p.id AND r.user_id::text = $2),
+		EXISTS (SELECT 1 FROM bookmarks b WHERE b.post_id = p.id AND b.user_id::text = $2)
+	FROM posts p
+	WHERE p.id = ANY($1)`
+
+	rows, err := s.db.Query(ctx, stmt, postIDs, viewerID)
+	if err != nil {
+		s.log.ErrorContext(ctx, "GetPostShareCounts: failed to fetch counters", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var sc models.ShareCounts
+		if err := rows.Scan(&id, &sc.Reposts, &sc.Bookmarks, &sc.Reposted, &sc.Bookmarked); err != nil {
+			return nil, fmt.Errorf("failed to scan share counts: %v", err)
+		}
+		counts[id] = sc
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	return counts, nil
+}
+
+// feedBranch selects a page of the feed items of one kind that follow the
+// cursor. Each branch is limited before the union, so the (created_at, id)
+// index of its table serves the order and the cursor, and the union holds at
+// most two pages.
+func feedBranch(q *listQuery, query storage.FeedQuery, kind string) (string, error) {
+	sel := `'post' AS kind, p.id AS post_id, NULL::bigint AS repost_id, p.user_id AS actor_id,
+			'' AS quote, p.created_at, p.id::bigint AS item_id`
+	from, item := "posts p", "p"
+	if kind == models.FeedRepost {
+		sel = `'repost', r.post_id, r.id, r.user_id, r.quote, r.created_at, r.id`
+		from, item = "reposts r JOIN posts p ON r.post_id = p.id", "r"
+	}
+	actor := item + ".user_id"
+
+	where := []string{"p.deleted_at IS NULL", visiblePost(q, query.ViewerID), notBlocked(q, query.ViewerID, "p.user_id")}
+	if kind == models.FeedRepost {
+		where = append(where, notBlocked(q, query.ViewerID, actor))
+	}
+	if query.Author != "" {
+		where = append(where, actor+" = (SELECT id FROM users WHERE username = "+q.arg(query.Author)+")")
+	} else {
+		where = append(where, notMuted(q, query.ViewerID, "p.user_id"))
+		if kind == models.FeedRepost {
+			where = append(where, notMuted(q, query.ViewerID, actor))
+		}
+	}
+
+	after, err := feedAfter(q, query.Page, kind, item+".created_at", item+".id")
+	if err != nil {
+		return "", err
+	}
+	if after != "" {
+		where = append(where, after)
+	}
+
+	dir := "ASC"
+	if query.Desc {
+		dir = "DESC"
+	}
+
+	return fmt.Sprintf("(SELECT %s FROM %s WHERE %s ORDER BY %s.created_at %s, %s.id %s LIMIT %d)",
+		sel, from, strings.Join(where, " AND "), item, dir, item, dir, query.Size()+1), nil
+}
+
+// feedAfter is the cursor condition of one branch of the feed. Items are
+// ordered by (created_at, kind, id) and the kind is fixed within a branch, so
+// the comparison is on (created_at, id) for the kind of the cursor and on
+// created_at alone for the other kind.
+func feedAfter(q *listQuery, page pagination.Page, kind, createdAt, id string) (string, error) {
+	c := page.Cursor
+	if c == nil {
+		return "", nil
+	}
+
+	cursorKind, cursorID, err := parseFeedKey(c.ID)
+	if err != nil {
+		return "", err
+	}
+
+	op := ">"
+	if page.Desc {
+		op = "<"
+	}
+
+	at := q.arg(c.Value) + "::timestamp"
+	switch {
+	case kind == cursorKind:
+		return fmt.Sprintf("(%s, %s) %s (%s, %s::bigint)", createdAt, id, op, at, q.arg(cursorID)), nil
+	case (kind > cursorKind) != page.Desc:
+		// Items of this kind created at the cursor time come after it.
+		return fmt.Sprintf("%s %s= %s", createdAt, op, at), nil
+	default:
+		return fmt.Sprintf("%s %s %s", createdAt, op, at), nil
+	}
+}
+
+// parseFeedKey splits the tie-breaker of a feed cursor, the first letter of
+// the item kind followed by its id.
+func parseFeedKey(key string) (string, int64, error) {
+	var kind string
+	switch {
+	case strings.HasPrefix(key, "p"):
+		kind = models.FeedPost
+	case strings.HasPrefix(key, "r"):
+		kind = models.FeedRepost
+	default:
+		return "", 0, pagination.ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(key[1:], 10, 64)
+	if err != nil {
+		return "", 0, pagination.ErrInvalidCursor
+	}
+
+	return kind, id, nil
+}
+
+// GetFeed returns posts and reposts in one timeline. With query.Author set it
+// is the timeline of that user: their own posts and the posts they reposted.
+func (s *Storage) GetFeed(ctx context.Context, query storage.FeedQuery) ([]models.FeedItem, string, error) {
+	if query.Sort != "created_at" {
+		return nil, "", fmt.Errorf("%w: %q", pagination.ErrInvalidSort, query.Sort)
+	}
+
+	var q listQuery
+	posts, err := feedBranch(&q, query, models.FeedPost)
+	if err != nil {
+		return nil, "", err
+	}
+	reposts, err := feedBranch(&q, query, models.FeedRepost)
+	if err != nil {
+		return nil, "", err
+	}
+
+	dir := "ASC"
+	if query.Desc {
+		dir = "DESC"
+	}
+
+	stmt := `WITH f AS (` + posts + `
+		UNION ALL
+		` + reposts + `)
+	SELECT f.kind, COALESCE(f.repost_id, 0), f.quote, a.username, a.avatar,
+		TO_CHAR(f.created_at, 'YYYY-MM-DD HH24:MI:SS'), left(f.kind, 1) || f.item_id,
+		` + postColumns + `, f.created_at::text
+	FROM f
+	JOIN posts p ON f.post_id = p.id
+	JOIN users u ON p.user_id = u.id
+	JOIN users a ON f.actor_id = a.id
+	ORDER BY f.created_at ` + dir + `, f.kind ` + dir + `, f.item_id ` + dir + fmt.Sprintf(` LIMIT %d`, query.Size()+1)
+
+	rows, err := s.reader(query.ViewerID).Query(ctx, stmt, q.args...)
+	if err != nil {
+		s.log.ErrorContext(ctx, "GetFeed: failed to fetch feed", "err", err)
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	items := []models.FeedItem{}
+	var keys []rowKey
+
+	for rows.Next() {
+		var post models.Post
+		var repost models.Repost
+		var kind string
+		var key rowKey
+		dest := []any{&kind, &repost.ID, &repost.Quote, &repost.Username, &repost.Avatar, &repost.CreateAt, &key.id}
+		dest = append(dest, postFields(&post)...)
+		if err := rows.Scan(append(dest, &key.value)...); err != nil {
+			return nil, "", fmt.Errorf("failed to scan feed item: %v", err)
+		}
+
+		item := models.FeedItem{Type: kind, Post: &post}
+		if kind == models.FeedRepost {
+			repost.Post = post
+			item = models.FeedItem{Type: kind, Repost: &repost}
+		}
+		items = append(items, item)
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error while iterating over rows: %v", err)
+	}
+
+	items, next := trimPage(query.Page, items, keys)
+
+	return items, next, nil
+}
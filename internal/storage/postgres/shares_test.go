@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
+)
+
+func TestFeedAfter(t *testing.T) {
+	cursor := func(id string) *pagination.Cursor {
+		return &pagination.Cursor{Sort: "-created_at", Value: "2024-05-01 12:00:00", ID: id}
+	}
+
+	tests := []struct {
+		name    string
+		page    pagination.Page
+		kind    string
+		want    string
+		wantErr error
+	}{
+		{name: "first page", page: pagination.Page{Sort: "created_at"}, kind: models.FeedPost, want: ""},
+		{
+			name: "same kind",
+			page: pagination.Page{Sort: "created_at", Desc: true, Cursor: cursor("p42")},
+			kind: models.FeedPost,
+			want: "(p.created_at, p.id) < ($1::timestamp, $2::bigint)",
+		},
+		{
+			name: "descending, kind sorted before the cursor",
+			page: pagination.Page{Sort: "created_at", Desc: true, Cursor: cursor("r7")},
+			kind: models.FeedPost,
+			want: "p.created_at <= $1::timestamp",
+		},
+		{
+			name: "descending, kind sorted after the cursor",
+			page: pagination.Page{Sort: "created_at", Desc: true, Cursor: cursor("p42")},
+			kind: models.FeedRepost,
+			want: "p.created_at < $1::timestamp",
+		},
+		{
+			name: "ascending, kind sorted after the cursor",
+			page: pagination.Page{Sort: "created_at", Cursor: cursor("p42")},
+			kind: models.FeedRepost,
+			want: "p.created_at >= $1::timestamp",
+		},
+		{
+			name: "ascending, kind sorted before the cursor",
+			page: pagination.Page{Sort: "created_at", Cursor: cursor("r7")},
+			kind: models.FeedPost,
+			want: "p.created_at > $1::timestamp",
+		},
+		{name: "unknown kind", page: pagination.Page{Sort: "created_at", Cursor: cursor("x1")}, kind: models.FeedPost, wantErr: pagination.ErrInvalidCursor},
+		{name: "bad id", page: pagination.Page{Sort: "created_at", Cursor: cursor("p1x")}, kind: models.FeedPost, wantErr: pagination.ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var q listQuery
+			got, err := feedAfter(&q, tt.page, tt.kind, "p.created_at", "p.id")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("feedAfter: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,19 +3,36 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/storage"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"log/slog"
+	"strconv"
 )
 
 type Storage struct {
-	db  PgxPoolIface
-	log *slog.Logger
+	db PgxPoolIface
+	// read serves the read-only queries that may lag behind db.
+	read PgxPoolIface
+	log  *slog.Logger
+}
+
+// reader picks the pool of a listing. What a signed-in viewer sees depends on
+// their own blocks, drafts and hidden content, so their listings are read
+// from the primary to reflect their latest writes; anonymous listings may lag
+// behind on the replica.
+func (s *Storage) reader(viewerID string) PgxPoolIface {
+	if viewerID != "" {
+		return s.db
+	}
+	return s.read
 }
 
 func (s *Storage) Create(ctx context.Context, user *models.User) error {
-	s.log.Debug("starting registration")
+	s.log.DebugContext(ctx, "starting registration")
 
 	stmt := `
 		INSERT INTO users (name, username, email, password, gender, dob, avatar) 
@@ -36,48 +53,42 @@ func (s *Storage) Create(ctx context.Context, user *models.User) error {
 		user.Avatar,
 	)
 
-	s.log.Debug("SQL query:", stmt)
-
-	s.log.Debug("registration ", res)
-
 	if err != nil {
-		s.log.Debug("err:", err, "data:", res)
+		s.log.DebugContext(ctx, "registration failed", "username", user.Username, "err", err)
 		return err
 	}
 
-	s.log.Debug("registration successfully", "username:", user.Username)
+	s.log.DebugContext(ctx, "registration successfully", "username", user.Username, "rows", res.RowsAffected())
 
 	return nil
 }
 
 func (s *Storage) Login(ctx context.Context, username, password string) (string, error) {
-	s.log.Debug("LoginUser", "starting login user")
+	s.log.DebugContext(ctx, "starting login user")
 
 	var UserID string
 	var passwordHash string
 
-	s.log.Debug("Login", "username", username)
-	stmt := `SELECT id, password FROM users WHERE username = $1`
+	s.log.DebugContext(ctx, "Login", "username", username)
+	stmt := `SELECT id, password FROM users WHERE username = $1 AND suspended_at IS NULL`
 	err := s.db.QueryRow(ctx, stmt, username).Scan(&UserID, &passwordHash)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			s.log.Warn(
-				"LoginUser",
-				"пользователь не найден",
+			s.log.WarnContext(ctx,
+				"LoginUser: пользователь не найден",
 				"username", username,
 			)
 			return "", fmt.Errorf("пользователь не найден")
 		}
-		s.log.Error(
-			"LoginUser",
-			"ошибка выполнения SQL-запроса при логине",
+		s.log.ErrorContext(ctx,
+			"LoginUser: ошибка выполнения SQL-запроса при логине",
 			"err", err,
 		)
 		return "", err
 	}
 
 	if !models.CheckPasswordHash(password, passwordHash) {
-		s.log.Warn("LoginUser", "неверный пароль для пользователя", "username", username)
+		s.log.WarnContext(ctx, "LoginUser: неверный пароль для пользователя", "username", username)
 		return "", fmt.Errorf("неверный пароль")
 	}
 
@@ -86,37 +97,71 @@ func (s *Storage) Login(ctx context.Context, username, password string) (string,
 	stmt = `INSERT INTO sessions (user_id, session_id) VALUES ($1, $2)`
 	_, err = s.db.Exec(ctx, stmt, UserID, sessionID)
 	if err != nil {
-		s.log.Error("LoginUser", "ошибка сохранения сессии", "err", err)
+		s.log.ErrorContext(ctx, "LoginUser: ошибка сохранения сессии", "err", err)
 		return "", err
 	}
 
-	s.log.Info("пользователь успешно аутентифицирован", "username", username)
+	s.log.InfoContext(ctx, "пользователь успешно аутентифицирован", "username", username)
 
 	return sessionID, nil
 }
 
-func (s *Storage) GetAllUsers() ([]models.User, error) {
-	var users []models.User
+// time_registration is nullable and a NULL would break the row comparison of
+// the cursor, so users without it sort as registered at the epoch.
+var userKeyset = keyset{
+	columns: map[string]sortColumn{
+		"username":          {expr: "u.username", cast: "text"},
+		"name":              {expr: "u.name", cast: "text"},
+		"time_registration": {expr: "COALESCE(u.time_registration, 'epoch'::timestamp)", cast: "timestamp"},
+	},
+	id: sortColumn{expr: "u.id", cast: "uuid"},
+}
+
+func (s *Storage) GetAllUsers(ctx context.Context, query storage.UserQuery) ([]models.User, string, error) {
+	var q listQuery
+	q.and(notBlocked(&q, query.ViewerID, "u.id"))
+	if query.Gender != "" {
+		q.and("u.gender = " + q.arg(query.Gender))
+	}
+	if !query.RegisteredAfter.IsZero() {
+		q.and("u.time_registration > " + q.arg(query.RegisteredAfter))
+	}
+
+	page, err := userKeyset.paginate(&q, query.Page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	stmt := `SELECT u.id, u.name, u.username, u.gender, u.dob, u.avatar, u.time_registration, ` +
+		userKeyset.sortValue(query.Page) + ` FROM users u` + q.whereClause() + page
 
-	rows, err := s.db.Query(context.Background(), "SELECT id, name, username, email, password, gender, dob, avatar, time_registration FROM users")
+	rows, err := s.reader(query.ViewerID).Query(ctx, stmt, q.args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch users: %v", err)
+		return nil, "", fmt.Errorf("failed to fetch users: %v", err)
 	}
 	defer rows.Close()
 
+	var users []models.User
+	var keys []rowKey
+
 	for rows.Next() {
 		var user models.User
-		if err := rows.Scan(&user.UserID, &user.Name, &user.Username, &user.Email, &user.Password, &user.Gender, &user.Dob, &user.Avatar, &user.Time_registration); err != nil {
-			return nil, fmt.Errorf("failed to scan user: %v", err)
+		var key rowKey
+		if err := rows.Scan(&user.UserID, &user.Name, &user.Username, &user.Gender, &user.Dob, &user.Avatar, &user.Time_registration, &key.value); err != nil {
+			return nil, "", fmt.Errorf("failed to scan user: %v", err)
 		}
+		key.id = user.UserID
 		users = append(users, user)
+		keys = append(keys, key)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error while iterating over rows: %v", err)
+		return nil, "", fmt.Errorf("error while iterating over rows: %v", err)
 	}
 
-	return users, nil
+	users, next := trimPage(query.Page, users, keys)
+
+	return users, next, nil
 }
 
 func (s *Storage) CreateDialog(ctx context.Context, userIDOne, userIDTwo string) (int64, error) {
@@ -129,14 +174,14 @@ func (s *Storage) CreateDialog(ctx context.Context, userIDOne, userIDTwo string)
 
 	err := s.db.QueryRow(ctx, stmt, userIDOne, userIDTwo).Scan(&dialogID)
 	if err != nil {
-		if err.Error() == "sql: no rows in result set" {
+		// The dialog already exists.
+		if errors.Is(err, pgx.ErrNoRows) {
 			return 0, nil
 		}
 
-		s.log.Error(
-			"CreateDialog",
-			"Ошибка добавления диалога в БД",
-			"err:", err,
+		s.log.ErrorContext(ctx,
+			"CreateDialog: ошибка добавления диалога в БД",
+			"err", err,
 		)
 
 		return 0, err
@@ -147,16 +192,16 @@ func (s *Storage) CreateDialog(ctx context.Context, userIDOne, userIDTwo string)
 
 func (s *Storage) GetUserByID(ctx context.Context, sessionID string) (string, error) {
 	if sessionID == "" {
-		fmt.Println("Session ID is missing")
 		return "", fmt.Errorf("Session ID is missing")
 	}
 
 	var userID string
-	stmt := `SELECT user_id FROM sessions WHERE session_id = $1`
+	stmt := `SELECT s.user_id FROM sessions s JOIN users u ON s.user_id = u.id
+	WHERE s.session_id = $1 AND u.suspended_at IS NULL`
 	err := s.db.QueryRow(ctx, stmt, sessionID).Scan(&userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			s.log.Error("Session ID not found")
+			s.log.ErrorContext(ctx, "Session ID not found")
 			return "", fmt.Errorf("Session not found for ID: %s", sessionID)
 		}
 		return "", fmt.Errorf("Error querying session: %v", err)
@@ -165,8 +210,26 @@ func (s *Storage) GetUserByID(ctx context.Context, sessionID string) (string, er
 	return userID, nil
 }
 
-func (s *Storage) GetUserDialogs(ctx context.Context, userID string) ([]models.Dialog, error) {
-	var dialogs []models.Dialog
+var dialogKeyset = keyset{
+	columns: map[string]sortColumn{
+		"id": {expr: "d.id", cast: "int"},
+	},
+	id: sortColumn{expr: "d.id", cast: "int"},
+}
+
+func (s *Storage) GetUserDialogs(ctx context.Context, userID string, query storage.DialogQuery) ([]models.Dialog, string, error) {
+	var q listQuery
+	user := q.arg(userID)
+	q.and("(d.user_id_1 = " + user + " OR d.user_id_2 = " + user + ")")
+	q.and(notBlocked(&q, userID, "(CASE WHEN d.user_id_1 = "+user+" THEN d.user_id_2 ELSE d.user_id_1 END)"))
+	if query.With != "" {
+		q.and("(CASE WHEN d.user_id_1 = " + user + " THEN u2.username ELSE u1.username END) = " + q.arg(query.With))
+	}
+
+	page, err := dialogKeyset.paginate(&q, query.Page)
+	if err != nil {
+		return nil, "", err
+	}
 
 	stmt := `SELECT 
     d.id AS dialog_id, 
@@ -180,122 +243,194 @@ func (s *Storage) GetUserDialogs(ctx context.Context, userID string) ([]models.D
     END AS opponent_avatar
 FROM dialogs d
 JOIN users u1 ON u1.id = d.user_id_1 
-JOIN users u2 ON u2.id = d.user_id_2 
-WHERE d.user_id_1 = $1 OR d.user_id_2 = $1;
-`
+JOIN users u2 ON u2.id = d.user_id_2` + q.whereClause() + page
 
-	rows, err := s.db.Query(ctx, stmt, userID)
+	rows, err := s.db.Query(ctx, stmt, q.args...)
 	if err != nil {
-		s.log.Error("GetUserDialogs", "Failed to fetch user dialogs", "err:", err)
-		return nil, err
+		s.log.ErrorContext(ctx, "GetUserDialogs: failed to fetch user dialogs", "err", err)
+		return nil, "", err
 	}
 	defer rows.Close()
 
+	var dialogs []models.Dialog
+	var keys []rowKey
+
 	for rows.Next() {
 		var dialog models.Dialog
 		if err := rows.Scan(&dialog.DialogID, &dialog.UserTwoUsername, &dialog.Avatar); err != nil {
-			s.log.Error("GetUserDialogs", "Failed to scan dialog row", "err:", err)
-			return nil, err
+			s.log.ErrorContext(ctx, "GetUserDialogs: failed to scan dialog row", "err", err)
+			return nil, "", err
 		}
+		id := strconv.Itoa(dialog.DialogID)
 		dialogs = append(dialogs, dialog)
+		keys = append(keys, rowKey{value: id, id: id})
 	}
 
 	if err := rows.Err(); err != nil {
-		s.log.Error("GetUserDialogs", "Error reading rows", "err:", err)
-		return nil, err
+		s.log.ErrorContext(ctx, "GetUserDialogs: error reading rows", "err", err)
+		return nil, "", err
 	}
 
-	return dialogs, nil
+	dialogs, next := trimPage(query.Page, dialogs, keys)
+
+	return dialogs, next, nil
 }
 
-func (s *Storage) CreatePost(ctx context.Context, post *models.Post, userID string) error {
-	s.log.Debug("Обработка запроса на добавление поста в БД")
+// CreatePost inserts the post with its poll. A published post gets its
+// hashtags and mentions in the same transaction, and the ids of the mentioned
+// users are returned.
+func (s *Storage) CreatePost(ctx context.Context, post *models.Post, userID string, tags, usernames []string) ([]string, error) {
+	s.log.DebugContext(ctx, "Обработка запроса на добавление поста в БД")
 
-	stmt := `INSERT INTO Posts (title, content, user_id) VALUES ($1, $2, $3)`
-	_, err := s.db.Exec(ctx, stmt, post.Title, post.Content, userID)
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		s.log.Error(
-			"PostCreate",
-			"Ошибка при добавлении нового поста",
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	stmt := `INSERT INTO Posts (title, content, content_format, status, publish_at, user_id)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	err = tx.QueryRow(ctx, stmt, post.Title, post.Content, post.Format, post.Status, post.PublishAt, userID).Scan(&post.ID)
+	if err != nil {
+		s.log.ErrorContext(ctx,
+			"PostCreate: ошибка при добавлении нового поста",
+			"err", err,
 		)
-		return err
+		return nil, err
 	}
 
-	s.log.Debug(
-		"PostCreate",
-		"Добавление поста в БД прошло успешно",
-	)
+	if post.Poll != nil {
+		if err := s.createPoll(ctx, tx, post.ID, post.Poll); err != nil {
+			return nil, err
+		}
+	}
 
-	return nil
+	var mentioned []string
+	if post.Status == models.StatusPublished {
+		if mentioned, err = setPostEntities(ctx, tx, post.ID, tags, usernames); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit post: %v", err)
+	}
+
+	s.log.DebugContext(ctx, "PostCreate: добавление поста в БД прошло успешно")
+
+	return mentioned, nil
 }
 
-func (s *Storage) GetAllPosts(ctx context.Context) ([]models.Post, error) {
-	s.log.Debug("Получение всех постов")
+var postKeyset = keyset{
+	columns: map[string]sortColumn{
+		"created_at": {expr: "p.created_at", cast: "timestamp"},
+		"title":      {expr: "p.title", cast: "text"},
+	},
+	id: sortColumn{expr: "p.id", cast: "int"},
+}
 
-	stmt := `SELECT 
-    posts.id,
-    posts.title,
-    posts.content,
-    TO_CHAR(posts.created_at, 'YYYY-MM-DD HH24:MI:SS') AS created_at,
-    users.username,
-    users.avatar
-FROM posts
-JOIN users ON posts.user_id = users.id;
-`
-
-	rows, err := s.db.Query(ctx, stmt)
+func (s *Storage) GetAllPosts(ctx context.Context, query storage.PostQuery) ([]models.Post, string, error) {
+	s.log.DebugContext(ctx, "Получение постов", "sort", query.Sort, "limit", query.Size())
+
+	var q listQuery
+	q.and("p.deleted_at IS NULL")
+	q.and(visiblePost(&q, query.ViewerID))
+	q.and(notBlocked(&q, query.ViewerID, "p.user_id"))
+	if query.Author != "" {
+		q.and("u.username = " + q.arg(query.Author))
+	} else {
+		// Muted users are only hidden from the feed, not from their own page.
+		q.and(notMuted(&q, query.ViewerID, "p.user_id"))
+	}
+	if query.Tag != "" {
+		q.and(`EXISTS (SELECT 1 FROM post_hashtags ph JOIN hashtags h ON ph.hashtag_id = h.id
+			WHERE ph.post_id = p.id AND h.tag = ` + q.arg(query.Tag) + `)`)
+	}
+	if !query.CreatedAfter.IsZero() {
+		q.and("p.created_at > " + q.arg(query.CreatedAfter))
+	}
+	if !query.CreatedBefore.IsZero() {
+		q.and("p.created_at < " + q.arg(query.CreatedBefore))
+	}
+
+	page, err := postKeyset.paginate(&q, query.Page)
 	if err != nil {
-		s.log.Error("GetAllPosts", "Failed to fetch posts", "err:", err)
-		return nil, err
+		return nil, "", err
+	}
+
+	stmt := `SELECT ` + postColumns + `, ` + postKeyset.sortValue(query.Page) + `
+	FROM posts p
+	JOIN users u ON p.user_id = u.id` + q.whereClause() + page
+
+	rows, err := s.reader(query.ViewerID).Query(ctx, stmt, q.args...)
+	if err != nil {
+		s.log.ErrorContext(ctx, "GetAllPosts: failed to fetch posts", "err", err)
+		return nil, "", err
 	}
 	defer rows.Close()
 
 	var posts []models.Post
+	var keys []rowKey
 
 	for rows.Next() {
 		var post models.Post
-		var createdAt string
-		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &createdAt, &post.Username, &post.Avatar); err != nil {
-			s.log.Error("GetAllPosts", "Failed to scan post row", "err:", err)
-			return nil, err
+		var key rowKey
+		if err := rows.Scan(append(postFields(&post), &key.value)...); err != nil {
+			s.log.ErrorContext(ctx, "GetAllPosts: failed to scan post row", "err", err)
+			return nil, "", err
 		}
-		post.CreateAt = createdAt
+		key.id = strconv.Itoa(post.ID)
 		posts = append(posts, post)
+		keys = append(keys, key)
 	}
 
 	if err := rows.Err(); err != nil {
-		s.log.Error("GetAllPosts", "Error reading rows", "err:", err)
-		return nil, err
+		s.log.ErrorContext(ctx, "GetAllPosts: error reading rows", "err", err)
+		return nil, "", err
 	}
 
-	s.log.Debug(
-		"Все посты получены успешно",
-	)
+	posts, next := trimPage(query.Page, posts, keys)
 
-	return posts, nil
+	s.log.DebugContext(ctx, "Посты получены успешно", "count", len(posts))
+
+	return posts, next, nil
+}
+
+// visiblePost is the condition hiding drafts, scheduled posts and posts
+// hidden pending review from everyone but their author.
+func visiblePost(q *listQuery, viewerID string) string {
+	return "((p.status = 'published' AND p.hidden_at IS NULL) OR p.user_id::text = " + q.arg(viewerID) + ")"
 }
 
-func (s *Storage) GetPost(ctx context.Context, post *models.Post) error {
-	s.log.Info("Fetching post", "postID", post.ID)
+func (s *Storage) GetPost(ctx context.Context, post *models.Post, viewerID string) error {
+	s.log.InfoContext(ctx, "Fetching post", "postID", post.ID)
 
-	stmt := `SELECT p.title, p.content, TO_CHAR(p.created_at, 'YYYY-MM-DD HH24:MI:SS') AS created_at, u.username, u.avatar
+	stmt := `SELECT p.title, p.content, p.content_format,
+		TO_CHAR(p.created_at, 'YYYY-MM-DD HH24:MI:SS') AS created_at,
+		TO_CHAR(p.updated_at, 'YYYY-MM-DD HH24:MI:SS') AS updated_at,
+		COALESCE(TO_CHAR(p.edited_at, 'YYYY-MM-DD HH24:MI:SS'), '') AS edited_at,
+		p.status, p.publish_at, p.user_id,
+		u.username, u.avatar,
+		(SELECT count(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL) AS comments_count
 	FROM posts p
 	JOIN users u ON p.user_id = u.id
-	WHERE p.id = $1;`
+	WHERE p.id = $1 AND p.deleted_at IS NULL AND ((p.status = 'published' AND p.hidden_at IS NULL) OR p.user_id::text = $2)
+		AND NOT EXISTS (SELECT 1 FROM user_blocks ub
+			WHERE (ub.user_id::text = $2 AND ub.target_id = p.user_id) OR (ub.user_id = p.user_id AND ub.target_id::text = $2));`
 
-	s.log.Info("Executing query", "query", stmt, "postID", post.ID)
+	s.log.DebugContext(ctx, "Executing query", "query", stmt, "postID", post.ID)
 
-	err := s.db.QueryRow(ctx, stmt, post.ID).Scan(&post.Title, &post.Content, &post.CreateAt, &post.Username, &post.Avatar)
+	err := s.db.QueryRow(ctx, stmt, post.ID, viewerID).Scan(&post.Title, &post.Content, &post.Format, &post.CreateAt, &post.UpdatedAt, &post.EditedAt, &post.Status, &post.PublishAt, &post.AuthorID, &post.Username, &post.Avatar, &post.CommentsCount)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			s.log.Error("Post not found", "postID", post.ID)
-			return fmt.Errorf("post with ID %d not found", post.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			s.log.ErrorContext(ctx, "Post not found", "postID", post.ID)
+			return fmt.Errorf("post with ID %d: %w", post.ID, storage.ErrNotFound)
 		}
-		s.log.Error("Failed to get post", "postID", post.ID, "err", err)
+		s.log.ErrorContext(ctx, "Failed to get post", "postID", post.ID, "err", err)
 		return fmt.Errorf("failed to get post with ID %d: %v", post.ID, err)
 	}
 
-	s.log.Info("Successfully fetched post", "postID", post.ID, "title", post.Title)
+	s.log.InfoContext(ctx, "Successfully fetched post", "postID", post.ID, "title", post.Title)
 
 	return nil
 }
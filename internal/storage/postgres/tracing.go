@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/Fyefhqdishka/deadlock_v.2/internal/storage/postgres")
+
+// maxStatementLen bounds the statement recorded on a span.
+const maxStatementLen = 2000
+
+// queryTracer records a client span for every query run through the pool.
+type queryTracer struct{}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	op := sqlOperation(data.SQL)
+
+	stmt := data.SQL
+	if len(stmt) > maxStatementLen {
+		// Cut on a rune boundary so the attribute stays valid UTF-8.
+		n := maxStatementLen
+		for n > 0 && !utf8.RuneStart(stmt[n]) {
+			n--
+		}
+		stmt = stmt[:n]
+	}
+
+	ctx, _ = tracer.Start(ctx, op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemPostgreSQL,
+		semconv.DBNamespace(conn.Config().Database),
+		semconv.DBOperationName(op),
+		semconv.DBQueryText(stmt),
+	))
+	return ctx
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil && data.Err != pgx.ErrNoRows {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+	}
+	span.End()
+}
+
+// sqlOperation is the first keyword of a statement, such as SELECT or WITH.
+func sqlOperation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(fields[0])
+}
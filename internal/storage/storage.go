@@ -2,18 +2,170 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"github.com/Fyefhqdishka/deadlock_v.2/internal/models"
+	"github.com/Fyefhqdishka/deadlock_v.2/pkg/pagination"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	"time"
 )
 
 type Storage interface {
 	Create(ctx context.Context, user *models.User) error
 	Login(ctx context.Context, username, password string) (string, error)
-	GetAllUsers() ([]models.User, error)
+	GetAllUsers(ctx context.Context, query UserQuery) ([]models.User, string, error)
 	CreateDialog(ctx context.Context, userIDOne, userIDTwo string) (int64, error)
 	GetUserByID(ctx context.Context, sessionID string) (string, error)
-	GetUserDialogs(ctx context.Context, userID string) ([]models.Dialog, error)
-	CreatePost(ctx context.Context, post *models.Post, userID string) error
-	GetAllPosts(ctx context.Context) ([]models.Post, error)
-	GetPost(ctx context.Context, post *models.Post) error
+	GetUserDialogs(ctx context.Context, userID string, query DialogQuery) ([]models.Dialog, string, error)
+	CreatePost(ctx context.Context, post *models.Post, userID string, tags, usernames []string) ([]string, error)
+	GetAllPosts(ctx context.Context, query PostQuery) ([]models.Post, string, error)
+	GetPost(ctx context.Context, post *models.Post, viewerID string) error
+	GetPostAuthorID(ctx context.Context, postID int) (string, error)
+	GetVisiblePostAuthorID(ctx context.Context, postID int, viewerID string) (string, error)
+	UpdatePost(ctx context.Context, post *models.Post, editorID string) error
+	DeletePost(ctx context.Context, postID int) error
+	GetPostRevisions(ctx context.Context, postID int, viewerID string) ([]models.PostRevision, error)
+	GetUserRole(ctx context.Context, userID string) (string, error)
+	CreateComment(ctx context.Context, comment *models.Comment, userID string) error
+	GetCommentAuthorID(ctx context.Context, postID, commentID int) (string, error)
+	UpdateComment(ctx context.Context, comment *models.Comment) error
+	DeleteComment(ctx context.Context, postID, commentID int) error
+	GetPostComments(ctx context.Context, postID int, query CommentQuery) ([]models.Comment, string, error)
+	ReactionTargetExists(ctx context.Context, target string, targetID int, viewerID string) error
+	GetReactionTargetAuthorID(ctx context.Context, target string, targetID int) (string, error)
+	AddReaction(ctx context.Context, target string, targetID int, userID, emoji string) (bool, error)
+	RemoveReaction(ctx context.Context, target string, targetID int, userID, emoji string) (bool, error)
+	GetReactionCounts(ctx context.Context, target string, targetIDs []int, viewerID string) (map[int][]models.ReactionCount, error)
+	GetReactors(ctx context.Context, target string, targetID int, emoji string, viewerID string, page pagination.Page) ([]models.Reactor, string, error)
+	SearchPosts(ctx context.Context, query SearchQuery) ([]models.PostSearchResult, string, error)
+	SearchUsers(ctx context.Context, query SearchQuery) ([]models.UserSearchResult, string, error)
+	SetPostEntities(ctx context.Context, postID int, tags, usernames []string) ([]string, error)
+	GetTrendingTags(ctx context.Context, since time.Time, limit int) ([]models.TrendingTag, error)
+	PublishDuePosts(ctx context.Context, limit int) ([]models.Post, error)
+	AddBookmark(ctx context.Context, userID string, postID int) error
+	RemoveBookmark(ctx context.Context, userID string, postID int) error
+	GetBookmarks(ctx context.Context, userID string, page pagination.Page) ([]models.Post, string, error)
+	CreateRepost(ctx context.Context, repost *models.Repost, userID string) (bool, error)
+	DeleteRepost(ctx context.Context, userID string, postID int) (bool, error)
+	GetPostShareCounts(ctx context.Context, postIDs []int, viewerID string) (map[int]models.ShareCounts, error)
+	GetFeed(ctx context.Context, query FeedQuery) ([]models.FeedItem, string, error)
+	Vote(ctx context.Context, postID int, userID string, optionIDs []int) error
+	GetPolls(ctx context.Context, postIDs []int, viewerID string) (map[int]*models.Poll, error)
+	CreateNotification(ctx context.Context, n *models.Notification) error
+	GetNotificationGroups(ctx context.Context, userID string, query NotificationQuery) ([]models.NotificationGroup, string, error)
+	CountUnreadNotifications(ctx context.Context, userID string, types []string) (int, error)
+	MarkNotificationsRead(ctx context.Context, userID string, ids []int64) (int, error)
+	MarkAllNotificationsRead(ctx context.Context, userID string) (int, error)
+	GetNotificationPreferences(ctx context.Context, userID string) (models.NotificationPreferences, error)
+	SaveNotificationPreferences(ctx context.Context, userID string, prefs models.NotificationPreferences) error
+	DisableDigest(ctx context.Context, userID string) error
+	ClaimDueDigests(ctx context.Context, limit int) ([]models.DigestRecipient, error)
+	ReleaseDigest(ctx context.Context, userID string, lastDigestAt *time.Time) error
+	CreateStreamTicket(ctx context.Context, ticket, userID string, ttl time.Duration) error
+	RedeemStreamTicket(ctx context.Context, ticket string) (string, error)
+	AddRelation(ctx context.Context, kind, userID, targetID string) error
+	RemoveRelation(ctx context.Context, kind, userID, targetID string) error
+	GetRelatedUsers(ctx context.Context, kind, userID string, page pagination.Page) ([]models.RelatedUser, string, error)
+	GetRelation(ctx context.Context, userID, otherID string) (models.Relation, error)
+	CreateReport(ctx context.Context, report *models.Report, hideThreshold int) (bool, error)
+	GetModerationCases(ctx context.Context, query CaseQuery) ([]models.ModerationCase, string, error)
+	GetModerationCase(ctx context.Context, caseID int64) (*models.ModerationCase, error)
+	GetModerationActions(ctx context.Context, page pagination.Page) ([]models.ModerationAction, string, error)
+	ClaimCase(ctx context.Context, caseID int64, moderatorID string) error
+	ResolveCase(ctx context.Context, caseID int64, moderatorID, action, note string) error
+	FlagContent(ctx context.Context, target, targetID, note string) error
 }
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrTooDeep  = errors.New("maximum comment depth exceeded")
+
+	ErrPollClosed   = errors.New("poll is closed")
+	ErrAlreadyVoted = errors.New("already voted in this poll")
+	ErrInvalidVote  = errors.New("invalid poll options")
+
+	ErrCaseClaimed       = errors.New("case is claimed by another moderator")
+	ErrCaseResolved      = errors.New("case is already resolved")
+	ErrInvalidResolution = errors.New("action does not apply to this case")
+)
+
+// UserQuery, PostQuery and DialogQuery describe a single page of a list
+// endpoint. List methods return the rows of the page and the cursor of the
+// next one, which is empty on the last page.
+type UserQuery struct {
+	pagination.Page
+	ViewerID        string
+	Gender          string
+	RegisteredAfter time.Time
+}
+
+// ViewerID is the user the list is built for, empty for anonymous requests.
+type PostQuery struct {
+	pagination.Page
+	ViewerID      string
+	Author        string
+	Tag           string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+type DialogQuery struct {
+	pagination.Page
+	With string
+}
+
+// CommentQuery pages over the root comments of a post. Each root comes with
+// its replies down to Depth levels below it.
+type CommentQuery struct {
+	pagination.Page
+	ViewerID string
+	Depth    int
+}
+
+// FeedQuery pages over posts and reposts. Author narrows the feed to the
+// timeline of one user.
+type FeedQuery struct {
+	pagination.Page
+	ViewerID string
+	Author   string
+}
+
+// NotificationQuery pages over the notification groups of a user. A nil
+// Types means all types.
+type NotificationQuery struct {
+	pagination.Page
+	UnreadOnly bool
+	Types      []string
+	Since      time.Time
+}
+
+// CaseQuery pages over the moderator queue. An empty Status means all
+// unresolved cases; ModeratorID narrows it to the cases claimed by one moderator.
+type CaseQuery struct {
+	pagination.Page
+	Status      string
+	TargetType  string
+	Reason      string
+	ModeratorID string
+}
+
+// SearchQuery is a page of search results ordered by relevance.
+type SearchQuery struct {
+	pagination.Page
+	ViewerID string
+	Text     string
+}
+
+var (
+	UserSorts             = pagination.Sorts{Allowed: []string{"username", "name", "time_registration"}, Default: "username"}
+	PostSorts             = pagination.Sorts{Allowed: []string{"created_at", "title"}, Default: "-created_at"}
+	DialogSorts           = pagination.Sorts{Allowed: []string{"id"}, Default: "-id"}
+	CommentSorts          = pagination.Sorts{Allowed: []string{"id"}, Default: "id"}
+	ReactorSorts          = pagination.Sorts{Allowed: []string{"id"}, Default: "-id"}
+	SearchSorts           = pagination.Sorts{Allowed: []string{"rank"}, Default: "-rank"}
+	FeedSorts             = pagination.Sorts{Allowed: []string{"created_at"}, Default: "-created_at"}
+	BookmarkSorts         = pagination.Sorts{Allowed: []string{"created_at"}, Default: "-created_at"}
+	NotificationSorts     = pagination.Sorts{Allowed: []string{"id"}, Default: "-id"}
+	RelationSorts         = pagination.Sorts{Allowed: []string{"id"}, Default: "-id"}
+	CaseSorts             = pagination.Sorts{Allowed: []string{"id", "reports_count"}, Default: "id"}
+	ModerationActionSorts = pagination.Sorts{Allowed: []string{"id"}, Default: "-id"}
+)
@@ -0,0 +1 @@
+-- The tables predate the migrations and hold existing data, so they are kept.
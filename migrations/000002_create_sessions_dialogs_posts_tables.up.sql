@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS sessions (
+    session_id VARCHAR(36) PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS dialogs (
+    id        SERIAL PRIMARY KEY,
+    user_id_1 UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    user_id_2 UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    UNIQUE (user_id_1, user_id_2)
+);
+
+CREATE TABLE IF NOT EXISTS posts (
+    id         SERIAL PRIMARY KEY,
+    title      VARCHAR(255) NOT NULL,
+    content    TEXT NOT NULL,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS post_revisions;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS edited_at,
+    DROP COLUMN IF EXISTS updated_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS role;
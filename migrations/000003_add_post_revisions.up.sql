@@ -0,0 +1,19 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'user';
+
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS edited_at  TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id             SERIAL PRIMARY KEY,
+    post_id        INT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    editor_id      UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    title_before   VARCHAR(255) NOT NULL,
+    content_before TEXT NOT NULL,
+    title_after    VARCHAR(255) NOT NULL,
+    content_after  TEXT NOT NULL,
+    created_at     TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS post_revisions_post_id_index ON post_revisions (post_id, id);
@@ -0,0 +1,19 @@
+-- Comments form a tree per post. parent_id is the adjacency list, path is the
+-- materialized path of zero-padded ids used to read a thread in display order.
+CREATE TABLE IF NOT EXISTS comments (
+    id         SERIAL PRIMARY KEY,
+    post_id    INT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    parent_id  INT REFERENCES comments (id) ON DELETE CASCADE,
+    root_id    INT,
+    path       TEXT NOT NULL DEFAULT '',
+    depth      INT NOT NULL DEFAULT 0,
+    content    TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    edited_at  TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS comments_post_roots_index ON comments (post_id, id) WHERE parent_id IS NULL;
+CREATE INDEX IF NOT EXISTS comments_root_path_index ON comments (root_id, path);
+CREATE INDEX IF NOT EXISTS comments_post_id_index ON comments (post_id) WHERE deleted_at IS NULL;
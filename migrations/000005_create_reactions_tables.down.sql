@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS reaction_counts;
+DROP TABLE IF EXISTS reactions;
+DROP FUNCTION IF EXISTS reaction_counts_sync();
@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS reactions (
+    id          BIGSERIAL PRIMARY KEY,
+    target_type VARCHAR(16) NOT NULL CHECK (target_type IN ('post', 'comment')),
+    target_id   BIGINT NOT NULL,
+    user_id     UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    emoji       VARCHAR(32) NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT now(),
+    UNIQUE (target_type, target_id, user_id, emoji)
+);
+
+CREATE INDEX IF NOT EXISTS reactions_target_index ON reactions (target_type, target_id, emoji, id);
+
+-- Denormalized counters per target and emoji.
+CREATE TABLE IF NOT EXISTS reaction_counts (
+    target_type VARCHAR(16) NOT NULL,
+    target_id   BIGINT NOT NULL,
+    emoji       VARCHAR(32) NOT NULL,
+    count       INT NOT NULL DEFAULT 0 CHECK (count >= 0),
+    PRIMARY KEY (target_type, target_id, emoji)
+);
+
+-- reaction_counts is kept by a trigger, so reactions removed by ON DELETE
+-- CASCADE along with their user are subtracted as well.
+CREATE OR REPLACE FUNCTION reaction_counts_sync() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO reaction_counts (target_type, target_id, emoji, count)
+        VALUES (NEW.target_type, NEW.target_id, NEW.emoji, 1)
+        ON CONFLICT (target_type, target_id, emoji) DO UPDATE SET count = reaction_counts.count + 1;
+        RETURN NEW;
+    END IF;
+
+    UPDATE reaction_counts SET count = count - 1
+    WHERE target_type = OLD.target_type AND target_id = OLD.target_id AND emoji = OLD.emoji;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS reactions_count_sync ON reactions;
+CREATE TRIGGER reactions_count_sync AFTER INSERT OR DELETE ON reactions
+    FOR EACH ROW EXECUTE FUNCTION reaction_counts_sync();
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS users_name_trgm_index;
+DROP INDEX IF EXISTS users_username_trgm_index;
+DROP INDEX IF EXISTS posts_search_vector_index;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS search_vector;
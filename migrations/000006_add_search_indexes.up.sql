@@ -0,0 +1,14 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Posts are searched with Russian stemming, the title weighs more than the content.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('russian', coalesce(content, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS posts_search_vector_index ON posts USING GIN (search_vector);
+
+-- Users are matched fuzzily by trigram similarity.
+CREATE INDEX IF NOT EXISTS users_username_trgm_index ON users USING GIN (username gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS users_name_trgm_index ON users USING GIN (name gin_trgm_ops);
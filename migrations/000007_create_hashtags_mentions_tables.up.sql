@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS hashtags (
+    id  SERIAL PRIMARY KEY,
+    tag VARCHAR(64) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS post_hashtags (
+    post_id    INT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    hashtag_id INT NOT NULL REFERENCES hashtags (id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, hashtag_id)
+);
+
+CREATE INDEX IF NOT EXISTS post_hashtags_hashtag_id_index ON post_hashtags (hashtag_id, post_id);
+
+CREATE TABLE IF NOT EXISTS post_mentions (
+    post_id INT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, user_id)
+);
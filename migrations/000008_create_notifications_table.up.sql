@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS notifications (
+    id          BIGSERIAL PRIMARY KEY,
+    user_id     UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type        VARCHAR(32) NOT NULL,
+    actor_id    UUID REFERENCES users (id) ON DELETE CASCADE,
+    target_type VARCHAR(16),
+    target_id   BIGINT,
+    created_at  TIMESTAMP NOT NULL DEFAULT now(),
+    read_at     TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS notifications_user_id_index ON notifications (user_id, id);
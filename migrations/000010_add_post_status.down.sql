@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS posts_scheduled_index;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS publish_at,
+    DROP COLUMN IF EXISTS status;
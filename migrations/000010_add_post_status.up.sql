@@ -0,0 +1,7 @@
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'published'
+        CHECK (status IN ('draft', 'scheduled', 'published')),
+    ADD COLUMN IF NOT EXISTS publish_at TIMESTAMPTZ;
+
+-- The publisher picks due posts in publish_at order.
+CREATE INDEX IF NOT EXISTS posts_scheduled_index ON posts (publish_at) WHERE status = 'scheduled';
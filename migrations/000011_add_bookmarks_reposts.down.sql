@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS reposts;
+DROP TABLE IF EXISTS bookmarks;
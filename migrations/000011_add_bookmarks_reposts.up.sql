@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS bookmarks (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    post_id    INT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    UNIQUE (user_id, post_id)
+);
+
+-- GET /api/me/bookmarks pages over (created_at, id) of one user.
+CREATE INDEX IF NOT EXISTS bookmarks_user_created_at_index ON bookmarks (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS bookmarks_post_id_index ON bookmarks (post_id);
+
+-- A user reposts a post at most once, with or without a quote.
+CREATE TABLE IF NOT EXISTS reposts (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    post_id    INT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    quote      TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    UNIQUE (user_id, post_id)
+);
+
+CREATE INDEX IF NOT EXISTS reposts_created_at_index ON reposts (created_at, id);
+CREATE INDEX IF NOT EXISTS reposts_post_id_index ON reposts (post_id);
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS polls (
+    id         SERIAL PRIMARY KEY,
+    post_id    INT NOT NULL UNIQUE REFERENCES posts (id) ON DELETE CASCADE,
+    multiple   BOOLEAN NOT NULL DEFAULT false,
+    closes_at  TIMESTAMPTZ,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- votes is a denormalized counter, updated in the same transaction as poll_votes.
+CREATE TABLE IF NOT EXISTS poll_options (
+    id       SERIAL PRIMARY KEY,
+    poll_id  INT NOT NULL REFERENCES polls (id) ON DELETE CASCADE,
+    position SMALLINT NOT NULL,
+    text     VARCHAR(200) NOT NULL,
+    votes    INT NOT NULL DEFAULT 0 CHECK (votes >= 0),
+    UNIQUE (poll_id, position)
+);
+
+-- A ballot is the single vote of a user in a poll, covering one or more options.
+CREATE TABLE IF NOT EXISTS poll_ballots (
+    poll_id    INT NOT NULL REFERENCES polls (id) ON DELETE CASCADE,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (poll_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS poll_votes (
+    poll_id   INT NOT NULL,
+    option_id INT NOT NULL REFERENCES poll_options (id) ON DELETE CASCADE,
+    user_id   UUID NOT NULL,
+    PRIMARY KEY (option_id, user_id),
+    FOREIGN KEY (poll_id, user_id) REFERENCES poll_ballots (poll_id, user_id) ON DELETE CASCADE
+);
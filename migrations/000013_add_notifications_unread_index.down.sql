@@ -0,0 +1 @@
+DROP INDEX IF EXISTS notifications_unread_index;
@@ -0,0 +1,2 @@
+-- Unread counts and the ?unread=true inbox only touch unread rows.
+CREATE INDEX IF NOT EXISTS notifications_unread_index ON notifications (user_id, id) WHERE read_at IS NULL;
@@ -0,0 +1,11 @@
+-- Users without a row use the default preferences.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id        UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    preferences    JSONB NOT NULL DEFAULT '{}',
+    digest         VARCHAR(8) NOT NULL DEFAULT 'off' CHECK (digest IN ('off', 'daily', 'weekly')),
+    last_digest_at TIMESTAMPTZ,
+    updated_at     TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS notification_preferences_digest_index
+    ON notification_preferences (last_digest_at) WHERE digest <> 'off';
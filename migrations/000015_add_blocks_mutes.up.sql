@@ -0,0 +1,21 @@
+-- user_id blocked target_id. Blocking hides both users from each other.
+CREATE TABLE IF NOT EXISTS user_blocks (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    target_id  UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    UNIQUE (user_id, target_id),
+    CHECK (user_id <> target_id)
+);
+
+CREATE INDEX IF NOT EXISTS user_blocks_target_id_index ON user_blocks (target_id, user_id);
+
+-- user_id muted target_id. Muting only filters the feed of user_id.
+CREATE TABLE IF NOT EXISTS user_mutes (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    target_id  UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    UNIQUE (user_id, target_id),
+    CHECK (user_id <> target_id)
+);
@@ -0,0 +1,47 @@
+-- Content hidden pending review stays visible to its author only.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS hidden_at TIMESTAMP;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS hidden_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMP;
+
+-- One case per reported target collects all of its reports. target_id is
+-- text because users are reported by UUID and everything else by number.
+-- A resolved case is reopened by the next new report.
+CREATE TABLE IF NOT EXISTS moderation_cases (
+    id             BIGSERIAL PRIMARY KEY,
+    target_type    VARCHAR(16) NOT NULL CHECK (target_type IN ('post', 'comment', 'user')),
+    target_id      TEXT NOT NULL,
+    author_id      UUID REFERENCES users (id) ON DELETE SET NULL,
+    status         VARCHAR(16) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'claimed', 'resolved')),
+    reports_count  INT NOT NULL DEFAULT 0,
+    moderator_id   UUID REFERENCES users (id) ON DELETE SET NULL,
+    resolution     VARCHAR(16) CHECK (resolution IN ('dismiss', 'remove_content', 'suspend_user')),
+    created_at     TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMP NOT NULL DEFAULT now(),
+    resolved_at    TIMESTAMP,
+    UNIQUE (target_type, target_id)
+);
+
+CREATE INDEX IF NOT EXISTS moderation_cases_queue_index ON moderation_cases (status, id);
+
+CREATE TABLE IF NOT EXISTS reports (
+    id          BIGSERIAL PRIMARY KEY,
+    case_id     BIGINT NOT NULL REFERENCES moderation_cases (id) ON DELETE CASCADE,
+    reporter_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    reason      VARCHAR(32) NOT NULL,
+    details     TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMP NOT NULL DEFAULT now(),
+    UNIQUE (case_id, reporter_id)
+);
+
+-- Audit trail of every moderation step. moderator_id is NULL for automatic
+-- actions.
+CREATE TABLE IF NOT EXISTS moderation_actions (
+    id           BIGSERIAL PRIMARY KEY,
+    case_id      BIGINT NOT NULL REFERENCES moderation_cases (id) ON DELETE CASCADE,
+    moderator_id UUID REFERENCES users (id) ON DELETE SET NULL,
+    action       VARCHAR(16) NOT NULL,
+    note         TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS moderation_actions_case_id_index ON moderation_actions (case_id, id);
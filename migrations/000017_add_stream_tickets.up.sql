@@ -0,0 +1,10 @@
+-- Single-use tickets authenticating a notification stream. EventSource
+-- cannot set headers, so the client trades its session for a ticket that is
+-- short-lived and spent on first use instead of putting the session in the URL.
+CREATE TABLE IF NOT EXISTS stream_tickets (
+    ticket     VARCHAR(36) PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS stream_tickets_expires_at_index ON stream_tickets (expires_at);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS dialogs_user_id_2_index;
+DROP INDEX IF EXISTS users_time_registration_id_index;
+DROP INDEX IF EXISTS posts_user_id_index;
+DROP INDEX IF EXISTS posts_title_id_index;
+DROP INDEX IF EXISTS posts_created_at_id_index;
@@ -0,0 +1,6 @@
+-- Keyset pagination orders by (sort column, id).
+CREATE INDEX IF NOT EXISTS posts_created_at_id_index ON posts (created_at, id);
+CREATE INDEX IF NOT EXISTS posts_title_id_index ON posts (title, id);
+CREATE INDEX IF NOT EXISTS posts_user_id_index ON posts (user_id);
+CREATE INDEX IF NOT EXISTS users_time_registration_id_index ON users ((COALESCE(time_registration, 'epoch'::timestamp)), id);
+CREATE INDEX IF NOT EXISTS dialogs_user_id_2_index ON dialogs (user_id_2);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS reposts_user_created_at_index;
+DROP INDEX IF EXISTS posts_user_created_at_index;
@@ -0,0 +1,4 @@
+-- Timelines of one user page over their posts and reposts by (created_at, id).
+-- The whole feed uses posts_created_at_id_index and reposts_created_at_index.
+CREATE INDEX IF NOT EXISTS posts_user_created_at_index ON posts (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS reposts_user_created_at_index ON reposts (user_id, created_at, id);
@@ -0,0 +1,9 @@
+// Package migrations embeds the SQL migrations of the schema, so the binary
+// can migrate a database without the files next to it. Migrations are named
+// NNNNNN_name.up.sql and NNNNNN_name.down.sql.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
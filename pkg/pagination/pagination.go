@@ -0,0 +1,120 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidLimit  = errors.New("invalid limit")
+	ErrInvalidSort   = errors.New("invalid sort")
+)
+
+// Cursor points at the last row of the previous page. Value holds the sort key
+// of that row in its text form and ID is the tie-breaker.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"i"`
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort == "" || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// Sorts lists the sort keys accepted by an endpoint. Default may be prefixed
+// with "-" for descending order, the same way clients pass it in ?sort=.
+type Sorts struct {
+	Allowed []string
+	Default string
+}
+
+type Page struct {
+	Limit  int
+	Sort   string
+	Desc   bool
+	Cursor *Cursor
+}
+
+// FromRequest reads limit, sort and cursor from the query string.
+func FromRequest(r *http.Request, sorts Sorts) (Page, error) {
+	q := r.URL.Query()
+
+	page := Page{Limit: DefaultLimit}
+
+	if val := q.Get("limit"); val != "" {
+		limit, err := strconv.Atoi(val)
+		if err != nil || limit < 1 {
+			return Page{}, ErrInvalidLimit
+		}
+		page.Limit = min(limit, MaxLimit)
+	}
+
+	sort := q.Get("sort")
+	if sort == "" {
+		sort = sorts.Default
+	}
+	page.Sort, page.Desc = strings.TrimPrefix(sort, "-"), strings.HasPrefix(sort, "-")
+	if !slices.Contains(sorts.Allowed, page.Sort) {
+		return Page{}, fmt.Errorf("%w: %q, allowed: %s", ErrInvalidSort, page.Sort, strings.Join(sorts.Allowed, ", "))
+	}
+
+	if val := q.Get("cursor"); val != "" {
+		cursor, err := DecodeCursor(val)
+		if err != nil {
+			return Page{}, err
+		}
+		if cursor.Sort != sort {
+			return Page{}, fmt.Errorf("%w: cursor was issued for another sort order", ErrInvalidCursor)
+		}
+		page.Cursor = cursor
+	}
+
+	return page, nil
+}
+
+// Size is the number of rows on the page, falling back to DefaultLimit for
+// pages built by hand.
+func (p Page) Size() int {
+	if p.Limit <= 0 {
+		return DefaultLimit
+	}
+	return min(p.Limit, MaxLimit)
+}
+
+// Next builds the cursor for the page following the one ending at the given row.
+func (p Page) Next(value, id string) string {
+	sort := p.Sort
+	if p.Desc {
+		sort = "-" + sort
+	}
+
+	return Cursor{Sort: sort, Value: value, ID: id}.Encode()
+}
@@ -0,0 +1,118 @@
+package pagination
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []Cursor{
+		{Sort: "created_at", Value: "2024-01-02 03:04:05", ID: "17"},
+		{Sort: "-username", Value: "ünïcode, \"quoted\"", ID: "5b8f4f0e-7c1a-4d5e-9a0b-2f3c4d5e6f70"},
+		{Sort: "id", Value: "", ID: "1"},
+	}
+
+	for _, want := range tests {
+		t.Run(want.Sort, func(t *testing.T) {
+			got, err := DecodeCursor(want.Encode())
+			if err != nil {
+				t.Fatalf("DecodeCursor: %v", err)
+			}
+			if *got != want {
+				t.Errorf("got %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "!!!"},
+		{name: "not json", cursor: "bm90IGpzb24"},
+		{name: "no sort", cursor: Cursor{ID: "1"}.Encode()},
+		{name: "no id", cursor: Cursor{Sort: "id"}.Encode()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("got %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	sorts := Sorts{Allowed: []string{"created_at", "username"}, Default: "-created_at"}
+
+	tests := []struct {
+		name    string
+		query   string
+		want    Page
+		wantErr error
+	}{
+		{name: "defaults", query: "", want: Page{Limit: DefaultLimit, Sort: "created_at", Desc: true}},
+		{name: "ascending", query: "sort=username&limit=5", want: Page{Limit: 5, Sort: "username"}},
+		{name: "limit capped", query: "limit=1000", want: Page{Limit: MaxLimit, Sort: "created_at", Desc: true}},
+		{name: "zero limit", query: "limit=0", wantErr: ErrInvalidLimit},
+		{name: "bad limit", query: "limit=ten", wantErr: ErrInvalidLimit},
+		{name: "unknown sort", query: "sort=password", wantErr: ErrInvalidSort},
+		{
+			name:  "cursor",
+			query: "cursor=" + Cursor{Sort: "-created_at", Value: "v", ID: "3"}.Encode(),
+			want:  Page{Limit: DefaultLimit, Sort: "created_at", Desc: true, Cursor: &Cursor{Sort: "-created_at", Value: "v", ID: "3"}},
+		},
+		{
+			name:    "cursor of another order",
+			query:   "sort=created_at&cursor=" + Cursor{Sort: "-created_at", Value: "v", ID: "3"}.Encode(),
+			wantErr: ErrInvalidCursor,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromRequest(httptest.NewRequest("GET", "/?"+tt.query, nil), sorts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromRequest: %v", err)
+			}
+			if got.Limit != tt.want.Limit || got.Sort != tt.want.Sort || got.Desc != tt.want.Desc {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if (got.Cursor == nil) != (tt.want.Cursor == nil) || got.Cursor != nil && *got.Cursor != *tt.want.Cursor {
+				t.Errorf("got cursor %+v, want %+v", got.Cursor, tt.want.Cursor)
+			}
+		})
+	}
+}
+
+func TestPageNext(t *testing.T) {
+	tests := []struct {
+		page Page
+		want string
+	}{
+		{page: Page{Sort: "id"}, want: "id"},
+		{page: Page{Sort: "id", Desc: true}, want: "-id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			c, err := DecodeCursor(tt.page.Next("v", "9"))
+			if err != nil {
+				t.Fatalf("DecodeCursor: %v", err)
+			}
+			if c.Sort != tt.want || c.Value != "v" || c.ID != "9" {
+				t.Errorf("got %+v, want sort %q", *c, tt.want)
+			}
+		})
+	}
+}
@@ -1,19 +1,61 @@
 package routes
 
 import (
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/content"
 	"github.com/Fyefhqdishka/deadlock_v.2/internal/handlers"
+	"github.com/Fyefhqdishka/deadlock_v.2/internal/notifications"
 	"github.com/gorilla/mux"
 )
 
-func RegisterRoutes(r *mux.Router, h handlers.Handlers) {
-	UserRoutes(r, h)
-	DialogsRoutes(r, h)
-	PostRoutes(r, h)
+// groups are the route groups in registration order. Rate limit policies are
+// configured by their names.
+var groups = []struct {
+	name   string
+	routes func(*mux.Router, handlers.Handlers)
+}{
+	{"auth", AuthRoutes},
+	{"users", UserRoutes},
+	{"dialogs", DialogsRoutes},
+	{"posts", PostRoutes},
+	{"comments", CommentRoutes},
+	{"reactions", ReactionRoutes},
+	{"search", SearchRoutes},
+	{"tags", TagRoutes},
+	{"shares", ShareRoutes},
+	{"notifications", NotificationRoutes},
+	{"relations", RelationRoutes},
+	{"moderation", ModerationRoutes},
 }
 
-func UserRoutes(r *mux.Router, h handlers.Handlers) {
+// Groups returns the names of the route groups.
+func Groups() []string {
+	names := make([]string, len(groups))
+	for i, g := range groups {
+		names[i] = g.name
+	}
+	return names
+}
+
+// RegisterRoutes registers every route group. limit returns the rate
+// limiting middleware of a group by name; with a nil limit nothing is limited.
+func RegisterRoutes(r *mux.Router, h handlers.Handlers, limit func(group string) mux.MiddlewareFunc) {
+	for _, g := range groups {
+		if limit == nil {
+			g.routes(r, h)
+			continue
+		}
+		sub := r.NewRoute().Subrouter()
+		sub.Use(limit(g.name))
+		g.routes(sub, h)
+	}
+}
+
+func AuthRoutes(r *mux.Router, h handlers.Handlers) {
 	r.HandleFunc("/api/register", h.Create).Methods("POST")
 	r.HandleFunc("/api/login", h.Login)
+}
+
+func UserRoutes(r *mux.Router, h handlers.Handlers) {
 	r.HandleFunc("/api/users", h.GetUsers).Methods("GET")
 }
 
@@ -26,4 +68,74 @@ func PostRoutes(r *mux.Router, h handlers.Handlers) {
 	r.HandleFunc("/api/posts", h.CreatePost).Methods("POST")
 	r.HandleFunc("/api/posts", h.GetAllPosts).Methods("GET")
 	r.HandleFunc("/api/posts/{id}", h.GetPost).Methods("GET")
+	r.HandleFunc("/api/posts/{id}", h.UpdatePost).Methods("PUT")
+	r.HandleFunc("/api/posts/{id}", h.DeletePost).Methods("DELETE")
+	r.HandleFunc("/api/posts/{id}/revisions", h.GetPostRevisions).Methods("GET")
+	r.HandleFunc("/api/posts/{id}/poll/votes", h.Vote).Methods("POST")
+	r.HandleFunc(content.ImageProxyPath, h.ProxyImage).Methods("GET")
+}
+
+func CommentRoutes(r *mux.Router, h handlers.Handlers) {
+	r.HandleFunc("/api/posts/{id}/comments", h.CreateComment).Methods("POST")
+	r.HandleFunc("/api/posts/{id}/comments", h.GetComments).Methods("GET")
+	r.HandleFunc("/api/posts/{id}/comments/{commentID}", h.UpdateComment).Methods("PUT")
+	r.HandleFunc("/api/posts/{id}/comments/{commentID}", h.DeleteComment).Methods("DELETE")
+}
+
+func ReactionRoutes(r *mux.Router, h handlers.Handlers) {
+	const target = "/api/reactions/{target:post|comment}/{targetID}"
+
+	r.HandleFunc(target, h.GetReactions).Methods("GET")
+	r.HandleFunc(target+"/{emoji}", h.AddReaction).Methods("PUT")
+	r.HandleFunc(target+"/{emoji}", h.RemoveReaction).Methods("DELETE")
+	r.HandleFunc(target+"/{emoji}/users", h.GetReactors).Methods("GET")
+}
+
+func SearchRoutes(r *mux.Router, h handlers.Handlers) {
+	r.HandleFunc("/api/search", h.Search).Methods("GET")
+}
+
+func TagRoutes(r *mux.Router, h handlers.Handlers) {
+	r.HandleFunc("/api/tags/trending", h.GetTrendingTags).Methods("GET")
+	r.HandleFunc("/api/tags/{tag}/posts", h.GetTagPosts).Methods("GET")
+}
+
+func ShareRoutes(r *mux.Router, h handlers.Handlers) {
+	r.HandleFunc("/api/feed", h.GetFeed).Methods("GET")
+	r.HandleFunc("/api/me/bookmarks", h.GetBookmarks).Methods("GET")
+	r.HandleFunc("/api/posts/{id}/bookmark", h.AddBookmark).Methods("PUT")
+	r.HandleFunc("/api/posts/{id}/bookmark", h.RemoveBookmark).Methods("DELETE")
+	r.HandleFunc("/api/posts/{id}/reposts", h.CreateRepost).Methods("POST")
+	r.HandleFunc("/api/posts/{id}/reposts", h.DeleteRepost).Methods("DELETE")
+}
+
+func NotificationRoutes(r *mux.Router, h handlers.Handlers) {
+	r.HandleFunc("/api/notifications", h.GetNotifications).Methods("GET")
+	r.HandleFunc("/api/notifications/unread_count", h.GetUnreadCount).Methods("GET")
+	r.HandleFunc("/api/notifications/stream", h.StreamNotifications).Methods("GET")
+	r.HandleFunc("/api/notifications/stream/ticket", h.CreateStreamTicket).Methods("POST")
+	r.HandleFunc("/api/notifications/read", h.MarkNotificationsRead).Methods("POST")
+	r.HandleFunc("/api/notifications/read_all", h.MarkAllNotificationsRead).Methods("POST")
+	r.HandleFunc("/api/me/notification_preferences", h.GetNotificationPreferences).Methods("GET")
+	r.HandleFunc("/api/me/notification_preferences", h.UpdateNotificationPreferences).Methods("PUT")
+	r.HandleFunc(notifications.UnsubscribePath, h.ConfirmUnsubscribe).Methods("GET")
+	r.HandleFunc(notifications.UnsubscribePath, h.Unsubscribe).Methods("POST")
+}
+
+func RelationRoutes(r *mux.Router, h handlers.Handlers) {
+	r.HandleFunc("/api/me/blocks", h.GetBlockedUsers).Methods("GET")
+	r.HandleFunc("/api/me/mutes", h.GetMutedUsers).Methods("GET")
+	r.HandleFunc("/api/users/{userID}/block", h.Block).Methods("PUT")
+	r.HandleFunc("/api/users/{userID}/block", h.Unblock).Methods("DELETE")
+	r.HandleFunc("/api/users/{userID}/mute", h.Mute).Methods("PUT")
+	r.HandleFunc("/api/users/{userID}/mute", h.Unmute).Methods("DELETE")
+}
+
+func ModerationRoutes(r *mux.Router, h handlers.Handlers) {
+	r.HandleFunc("/api/reports", h.CreateReport).Methods("POST")
+	r.HandleFunc("/api/moderation/cases", h.GetModerationCases).Methods("GET")
+	r.HandleFunc("/api/moderation/cases/{caseID}", h.GetModerationCase).Methods("GET")
+	r.HandleFunc("/api/moderation/cases/{caseID}/claim", h.ClaimCase).Methods("POST")
+	r.HandleFunc("/api/moderation/cases/{caseID}/resolve", h.ResolveCase).Methods("POST")
+	r.HandleFunc("/api/moderation/actions", h.GetModerationActions).Methods("GET")
 }